@@ -2,7 +2,9 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"os"
+	"strings"
 
 	"github.com/ViBiOh/auth/v2/pkg/ident/basic"
 	authMiddleware "github.com/ViBiOh/auth/v2/pkg/middleware"
@@ -10,8 +12,14 @@ import (
 	"github.com/ViBiOh/fibr/pkg/crud"
 	"github.com/ViBiOh/fibr/pkg/fibr"
 	"github.com/ViBiOh/fibr/pkg/filesystem"
+	"github.com/ViBiOh/fibr/pkg/memory"
+	"github.com/ViBiOh/fibr/pkg/oidc"
+	"github.com/ViBiOh/fibr/pkg/provider"
 	"github.com/ViBiOh/fibr/pkg/renderer"
+	"github.com/ViBiOh/fibr/pkg/s3"
+	"github.com/ViBiOh/fibr/pkg/throttle"
 	"github.com/ViBiOh/fibr/pkg/thumbnail"
+	"github.com/ViBiOh/fibr/pkg/webdav"
 	"github.com/ViBiOh/httputils/v3/pkg/alcotest"
 	"github.com/ViBiOh/httputils/v3/pkg/flags"
 	"github.com/ViBiOh/httputils/v3/pkg/httputils"
@@ -28,6 +36,19 @@ func newLoginApp(basicConfig basicMemory.Config) authMiddleware.App {
 	return authMiddleware.New(basicApp, basicProviderProvider)
 }
 
+func newStorage(storageType string, filesystemConfig filesystem.Config, s3Config s3.Config) (provider.Storage, error) {
+	switch storageType {
+	case "filesystem":
+		return filesystem.New(filesystemConfig)
+	case "s3":
+		return s3.New(s3Config)
+	case "memory":
+		return memory.New(), nil
+	default:
+		return nil, fmt.Errorf("unknown storage type `%s`", storageType)
+	}
+}
+
 func main() {
 	fs := flag.NewFlagSet("fibr", flag.ExitOnError)
 
@@ -37,12 +58,18 @@ func main() {
 	owaspConfig := owasp.Flags(fs, "")
 
 	basicConfig := basicMemory.Flags(fs, "auth")
+	fibrConfig := fibr.Flags(fs, "auth")
+	oidcConfig := oidc.Flags(fs, "oidc")
+	throttleConfig := throttle.Flags(fs, "throttle")
 
 	crudConfig := crud.Flags(fs, "")
 	rendererConfig := renderer.Flags(fs, "")
 
+	storageType := flags.New("", "fibr").Name("Storage").Default("filesystem").Label("Storage backend (filesystem, s3 or memory)").ToString(fs)
 	filesystemConfig := filesystem.Flags(fs, "fs")
+	s3Config := s3.Flags(fs, "s3")
 	thumbnailConfig := thumbnail.Flags(fs, "thumbnail")
+	webdavConfig := webdav.Flags(fs, "webdav")
 
 	disableAuth := flags.New("", "auth").Name("NoAuth").Default(false).Label("Disable basic authentification").ToBool(fs)
 
@@ -50,26 +77,44 @@ func main() {
 
 	alcotest.DoAndExit(alcotestConfig)
 
-	storage, err := filesystem.New(filesystemConfig)
+	prometheusApp := prometheus.New(prometheusConfig)
+
+	storage, err := newStorage(strings.TrimSpace(*storageType), filesystemConfig, s3Config)
+	logger.Fatal(err)
+
+	thumbnailApp, err := thumbnail.New(thumbnailConfig, storage)
 	logger.Fatal(err)
 
-	thumbnailApp := thumbnail.New(thumbnailConfig, storage)
 	rendererApp := renderer.New(rendererConfig, thumbnailApp)
 	crudApp, err := crud.New(crudConfig, storage, rendererApp, thumbnailApp)
 	logger.Fatal(err)
 
 	var middlewareApp authMiddleware.App
+	var oidcApp oidc.App
 	if !*disableAuth {
 		middlewareApp = newLoginApp(basicConfig)
+
+		oidcApp, err = oidc.New(oidcConfig, middlewareApp)
+		logger.Fatal(err)
+
+		if oidcApp.Enabled() {
+			middlewareApp = oidcApp
+		}
 	}
 
-	fibrApp := fibr.New(crudApp, rendererApp, middlewareApp)
+	throttleApp, err := throttle.New(throttleConfig, prometheusApp.Registerer())
+	logger.Fatal(err)
+
+	webdavApp := webdav.New(webdavConfig, storage, crudApp, thumbnailApp)
+
+	fibrApp, err := fibr.New(fibrConfig, storage, crudApp, rendererApp, webdavApp, middlewareApp, oidcApp, throttleApp)
+	logger.Fatal(err)
 
 	go thumbnailApp.Start()
 	go crudApp.Start()
 
 	server := httputils.New(serverConfig)
-	server.Middleware(prometheus.New(prometheusConfig).Middleware)
+	server.Middleware(prometheusApp.Middleware)
 	server.Middleware(owasp.New(owaspConfig).Middleware)
 	server.ListenServeWait(fibrApp.Handler())
 }
@@ -5,6 +5,9 @@ go 1.14
 require (
 	github.com/ViBiOh/auth/v2 v2.5.3
 	github.com/ViBiOh/httputils/v3 v3.21.0
-	golang.org/x/crypto v0.0.0-20200622213623-75b288015ac9
+	github.com/minio/minio-go/v7 v7.0.5
+	github.com/prometheus/client_golang v1.7.1
+	golang.org/x/crypto v0.0.0-20200709230013-948cd5f35899
+	golang.org/x/net v0.0.0-20200707034311-ab3426394381
 	golang.org/x/text v0.3.3
 )
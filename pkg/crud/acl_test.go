@@ -0,0 +1,216 @@
+package crud
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/ViBiOh/fibr/pkg/provider"
+)
+
+func newACLApp(t *testing.T) (*app, provider.Storage, *testRenderer, provider.Request) {
+	crudApp, storage, renderer := newTestApp(t)
+
+	for _, pathname := range []string{"/marketing", "/finance", "/finance/public", "/photos"} {
+		if err := storage.CreateDir(pathname); err != nil {
+			t.Fatalf("CreateDir() = %s", err)
+		}
+	}
+
+	for _, pathname := range []string{"/marketing/logo.png", "/finance/salaries.csv", "/finance/public/report.pdf", "/photos/holidays.jpg"} {
+		if err := storage.Store(pathname, ioutil.NopCloser(strings.NewReader(pathname))); err != nil {
+			t.Fatalf("Store() = %s", err)
+		}
+	}
+
+	request := provider.Request{
+		Path:        "/",
+		Permissions: provider.PermissionEdit,
+		ACL: provider.ACL{
+			{Path: "/", Permissions: provider.PermissionEdit},
+			{Path: "/photos", Permissions: provider.PermissionRead},
+			{Path: "/finance"},
+			{Path: "/finance/public", Permissions: provider.PermissionRead},
+		},
+	}
+
+	return crudApp, storage, renderer, request
+}
+
+func TestListACL(t *testing.T) {
+	var cases = []struct {
+		intention string
+		path      string
+		want      []string
+	}{
+		{
+			"root",
+			"/",
+			[]string{"finance", "marketing", "photos"},
+		},
+		{
+			"hidden content",
+			"/finance",
+			[]string{"public"},
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.intention, func(t *testing.T) {
+			crudApp, _, renderer, request := newACLApp(t)
+			request.Path = testCase.path
+
+			crudApp.List(httptest.NewRecorder(), request, nil)
+
+			if names := listedNames(renderer.content); !reflect.DeepEqual(names, testCase.want) {
+				t.Errorf("List() = %#v, want %#v", names, testCase.want)
+			}
+		})
+	}
+}
+
+func TestDownloadACL(t *testing.T) {
+	crudApp, _, _, request := newACLApp(t)
+
+	writer := httptest.NewRecorder()
+	crudApp.Download(writer, request)
+
+	reader, err := zip.NewReader(bytes.NewReader(writer.Body.Bytes()), int64(writer.Body.Len()))
+	if err != nil {
+		t.Fatalf("NewReader() = %s", err)
+	}
+
+	names := make([]string, len(reader.File))
+	for index, file := range reader.File {
+		names[index] = file.Name
+	}
+	sort.Strings(names)
+
+	if want := []string{"finance/public/report.pdf", "marketing/logo.png", "photos/holidays.jpg"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("Download() = %#v, want %#v", names, want)
+	}
+}
+
+func TestThumbnailNeedsDownload(t *testing.T) {
+	var cases = []struct {
+		intention  string
+		path       string
+		permission provider.Permission
+		wantStatus int
+	}{
+		{
+			"file without download",
+			"/photos/holidays.jpg",
+			provider.PermissionList,
+			http.StatusForbidden,
+		},
+		{
+			"directory without download",
+			"/photos/",
+			provider.PermissionList,
+			http.StatusNoContent,
+		},
+		{
+			"directory without list",
+			"/photos/",
+			provider.PermissionDownload,
+			http.StatusForbidden,
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.intention, func(t *testing.T) {
+			crudApp, _, _, _ := newACLApp(t)
+
+			request := provider.Request{Path: testCase.path, Permissions: testCase.permission}
+
+			writer := httptest.NewRecorder()
+			crudApp.Get(writer, httptest.NewRequest(http.MethodGet, testCase.path+"?thumbnail", nil), request)
+
+			if writer.Code != testCase.wantStatus {
+				t.Errorf("Get() = %d, want %d", writer.Code, testCase.wantStatus)
+			}
+		})
+	}
+}
+
+func TestBrowserACL(t *testing.T) {
+	crudApp, storage, renderer, request := newACLApp(t)
+
+	for _, pathname := range []string{"/photos/beach.txt", "/photos/city.txt", "/photos/notes.txt", "/photos/zoo.txt"} {
+		if err := storage.Store(pathname, ioutil.NopCloser(strings.NewReader(pathname))); err != nil {
+			t.Fatalf("Store() = %s", err)
+		}
+	}
+
+	request.ACL = append(request.ACL, provider.Rule{Path: "/photos/beach.txt"}, provider.Rule{Path: "/photos/city.txt", Permissions: provider.PermissionList})
+	request.Path = "/photos/notes.txt"
+
+	file, err := storage.Info("/photos/notes.txt")
+	if err != nil {
+		t.Fatalf("Info() = %s", err)
+	}
+
+	crudApp.Browser(httptest.NewRecorder(), request, file, nil)
+
+	if previous := renderer.content["Previous"].(*provider.StorageItem); previous != nil {
+		t.Errorf("Browser() = %s, want no previous file allowed", previous.Name)
+	}
+
+	if next := renderer.content["Next"].(*provider.StorageItem); next == nil || next.Name != "zoo.txt" {
+		t.Errorf("Browser() = %+v, want zoo.txt as next", next)
+	}
+}
+
+func TestDeleteACL(t *testing.T) {
+	var cases = []struct {
+		intention string
+		name      string
+		want      int
+	}{
+		{
+			"allowed",
+			"marketing",
+			http.StatusFound,
+		},
+		{
+			"read only",
+			"photos/holidays.jpg",
+			http.StatusForbidden,
+		},
+		{
+			"restricted content",
+			"finance",
+			http.StatusForbidden,
+		},
+		{
+			"hidden",
+			"finance/salaries.csv",
+			http.StatusNotFound,
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.intention, func(t *testing.T) {
+			crudApp, storage, _, request := newACLApp(t)
+
+			writer := httptest.NewRecorder()
+			crudApp.Delete(writer, newFormRequest("/", url.Values{"name": {testCase.name}}), request)
+
+			if writer.Code != testCase.want {
+				t.Errorf("Delete() = %d, want %d", writer.Code, testCase.want)
+			}
+
+			if _, err := storage.Info("/" + testCase.name); (err == nil) == (testCase.want == http.StatusFound) {
+				t.Errorf("Info() = `%v`, want deletion only when allowed", err)
+			}
+		})
+	}
+}
@@ -23,6 +23,7 @@ func (a *app) Browser(w http.ResponseWriter, request provider.Request, file prov
 	if err != nil {
 		logger.Error("unable to list neighbors files: %s", err)
 	} else {
+		files = getDownloadableNeighbors(request, files)
 		previous, next = getPreviousAndNext(file, files)
 	}
 
@@ -38,5 +39,31 @@ func (a *app) Browser(w http.ResponseWriter, request provider.Request, file prov
 		"Next":     next,
 	}
 
+	if request.CanShare() {
+		content["Shares"] = a.getShares(request)
+	}
+
+	if canManageVersions(request) && a.versionEnabled() {
+		if versions, err := a.listVersions(file.Pathname); err != nil {
+			logger.Error("unable to list versions: %s", err)
+		} else {
+			content["Versions"] = versions
+		}
+	}
+
 	a.renderer.File(w, request, content, message)
 }
+
+// getDownloadableNeighbors keeps directories and files that request can see and download
+func getDownloadableNeighbors(request provider.Request, files []provider.StorageItem) []provider.StorageItem {
+	visibles := request.FilterVisible(files)
+
+	neighbors := make([]provider.StorageItem, 0, len(visibles))
+	for _, item := range visibles {
+		if item.IsDir || request.PermissionOn(item.Pathname).Has(provider.PermissionDownload) {
+			neighbors = append(neighbors, item)
+		}
+	}
+
+	return neighbors
+}
@@ -0,0 +1,103 @@
+package crud
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/ViBiOh/fibr/pkg/provider"
+)
+
+const (
+	conflictError     = "error"
+	conflictOverwrite = "overwrite"
+	conflictRename    = "rename"
+
+	maxConflictRename = 1000
+)
+
+// conflictOutcome describes what has been done on a conflicting target
+type conflictOutcome struct {
+	pathname    string
+	overwritten bool
+	renamed     bool
+}
+
+// describe gives details to append to success message
+func (o conflictOutcome) describe(name string) string {
+	switch {
+	case o.renamed:
+		return fmt.Sprintf(", saved as %s because %s already exists", path.Base(o.pathname), name)
+	case o.overwritten:
+		return fmt.Sprintf(", %s has been overwritten", name)
+	default:
+		return ""
+	}
+}
+
+func checkConflictPolicy(policy string) error {
+	switch policy {
+	case conflictError, conflictOverwrite, conflictRename:
+		return nil
+	default:
+		return fmt.Errorf("unknown conflict policy `%s`, should be %s, %s or %s", policy, conflictError, conflictOverwrite, conflictRename)
+	}
+}
+
+// getConflictValue gives policy from given value or fallback to query string
+func getConflictValue(r *http.Request, value string) string {
+	if len(value) != 0 {
+		return value
+	}
+
+	return r.URL.Query().Get("conflict")
+}
+
+func (a *app) getConflictPolicy(value string) (string, *provider.Error) {
+	policy := strings.ToLower(strings.TrimSpace(value))
+	if len(policy) == 0 {
+		return a.conflict, nil
+	}
+
+	if err := checkConflictPolicy(policy); err != nil {
+		return "", provider.NewError(http.StatusBadRequest, err)
+	}
+
+	return policy, nil
+}
+
+// resolveConflict gives pathname to use for writing according to policy if target already exists
+func (a *app) resolveConflict(pathname, policy string) (conflictOutcome, *provider.Error) {
+	if _, err := a.storage.Info(pathname); err != nil {
+		if provider.IsNotExist(err) {
+			return conflictOutcome{pathname: pathname}, nil
+		}
+
+		return conflictOutcome{}, provider.NewError(http.StatusInternalServerError, err)
+	}
+
+	switch policy {
+	case conflictOverwrite:
+		return conflictOutcome{pathname: pathname, overwritten: true}, nil
+	case conflictRename:
+		extension := path.Ext(pathname)
+		base := strings.TrimSuffix(pathname, extension)
+
+		for i := 1; i <= maxConflictRename; i++ {
+			candidate := fmt.Sprintf("%s_%d%s", base, i, extension)
+
+			if _, err := a.storage.Info(candidate); err != nil {
+				if provider.IsNotExist(err) {
+					return conflictOutcome{pathname: candidate, renamed: true}, nil
+				}
+
+				return conflictOutcome{}, provider.NewError(http.StatusInternalServerError, err)
+			}
+		}
+
+		return conflictOutcome{}, provider.NewError(http.StatusConflict, fmt.Errorf("unable to find a free name for %s", path.Base(pathname)))
+	default:
+		return conflictOutcome{}, provider.NewError(http.StatusConflict, fmt.Errorf("%s already exists", path.Base(pathname)))
+	}
+}
@@ -0,0 +1,150 @@
+package crud
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/ViBiOh/fibr/pkg/provider"
+)
+
+func TestResolveConflict(t *testing.T) {
+	crudApp, storage, _ := newTestApp(t)
+
+	for _, name := range []string{"/photo.jpg", "/photo_1.jpg", "/archive"} {
+		if err := storage.Store(name, ioutil.NopCloser(strings.NewReader(name))); err != nil {
+			t.Fatalf("Store() = %s", err)
+		}
+	}
+
+	var cases = []struct {
+		intention string
+		pathname  string
+		policy    string
+		want      conflictOutcome
+		wantErr   int
+	}{
+		{
+			"no conflict",
+			"/beach.jpg",
+			conflictError,
+			conflictOutcome{pathname: "/beach.jpg"},
+			0,
+		},
+		{
+			"error",
+			"/photo.jpg",
+			conflictError,
+			conflictOutcome{},
+			http.StatusConflict,
+		},
+		{
+			"overwrite",
+			"/photo.jpg",
+			conflictOverwrite,
+			conflictOutcome{pathname: "/photo.jpg", overwritten: true},
+			0,
+		},
+		{
+			"rename",
+			"/photo.jpg",
+			conflictRename,
+			conflictOutcome{pathname: "/photo_2.jpg", renamed: true},
+			0,
+		},
+		{
+			"rename without extension",
+			"/archive",
+			conflictRename,
+			conflictOutcome{pathname: "/archive_1", renamed: true},
+			0,
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.intention, func(t *testing.T) {
+			result, err := crudApp.resolveConflict(testCase.pathname, testCase.policy)
+
+			failed := false
+
+			if err != nil && err.Status != testCase.wantErr {
+				failed = true
+			} else if err == nil && testCase.wantErr != 0 {
+				failed = true
+			} else if result != testCase.want {
+				failed = true
+			}
+
+			if failed {
+				t.Errorf("resolveConflict(`%s`, `%s`) = (%+v, `%v`), want (%+v, %d)", testCase.pathname, testCase.policy, result, err, testCase.want, testCase.wantErr)
+			}
+		})
+	}
+}
+
+func TestUploadConflict(t *testing.T) {
+	crudApp, storage, renderer := newTestApp(t)
+	request := provider.Request{Path: "/", Permissions: provider.PermissionEdit}
+
+	for _, content := range []string{"first", "second"} {
+		writer := httptest.NewRecorder()
+		crudApp.Post(writer, newUploadRequest(t, "/?conflict=rename", "photo.jpg", content), request)
+
+		if writer.Code != http.StatusFound || renderer.err != nil {
+			t.Fatalf("Post() = %d, `%v`", writer.Code, renderer.err)
+		}
+	}
+
+	if _, err := storage.Info("/photo_1.jpg"); err != nil {
+		t.Errorf("Post() = `%s`, want second upload renamed", err)
+	}
+
+	writer := httptest.NewRecorder()
+	crudApp.Post(writer, newUploadRequest(t, "/", "photo.jpg", "third"), request)
+
+	if writer.Code != http.StatusConflict {
+		t.Errorf("Post() = %d, want conflict by default policy", writer.Code)
+	}
+
+	writer = httptest.NewRecorder()
+	crudApp.Post(writer, newUploadRequest(t, "/?conflict=overwrite", "photo.jpg", "fourth"), request)
+
+	if location := writer.Header().Get("Location"); writer.Code != http.StatusFound || !strings.Contains(location, "overwritten") {
+		t.Errorf("Post() = (%d, `%s`), want overwrite reported", writer.Code, location)
+	}
+
+	reader, err := storage.ReaderFrom("/photo.jpg")
+	if err != nil {
+		t.Fatalf("ReaderFrom() = %s", err)
+	}
+
+	if content, err := ioutil.ReadAll(reader); err != nil || string(content) != "fourth" {
+		t.Errorf("ReadAll() = (`%s`, `%v`), want overwritten content", content, err)
+	}
+}
+
+func TestCreateConflict(t *testing.T) {
+	crudApp, storage, _ := newTestApp(t)
+	request := provider.Request{Path: "/", Permissions: provider.PermissionEdit}
+
+	if err := storage.CreateDir("/photos"); err != nil {
+		t.Fatalf("CreateDir() = %s", err)
+	}
+
+	writer := httptest.NewRecorder()
+	crudApp.Create(writer, newFormRequest("/", url.Values{"name": {"photos"}, "conflict": {conflictRename}}), request)
+
+	if location := writer.Header().Get("Location"); !strings.HasPrefix(location, "/photos_1/") {
+		t.Errorf("Create() = `%s`, want renamed directory", location)
+	}
+
+	writer = httptest.NewRecorder()
+	crudApp.Create(writer, newFormRequest("/", url.Values{"name": {"photos"}, "conflict": {"unknown"}}), request)
+
+	if writer.Code != http.StatusBadRequest {
+		t.Errorf("Create() = %d, want bad request for unknown policy", writer.Code)
+	}
+}
@@ -0,0 +1,191 @@
+package crud
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ViBiOh/fibr/pkg/provider"
+	"github.com/ViBiOh/httputils/v3/pkg/logger"
+)
+
+var (
+	// ErrShareLimitReached occurs when a share has exhausted its downloads or bytes
+	ErrShareLimitReached = errors.New("this share has reached its download limit")
+)
+
+// countingWriter counts bytes written to the response
+type countingWriter struct {
+	http.ResponseWriter
+	written int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.ResponseWriter.Write(p)
+	c.written += int64(n)
+
+	return n, err
+}
+
+// isNewDownload checks if request starts a download, resumed or partial requests being counted in bytes only
+func isNewDownload(r *http.Request) bool {
+	if r.Method == http.MethodHead {
+		return false
+	}
+
+	rangeHeader := r.Header.Get("Range")
+	return len(rangeHeader) == 0 || strings.HasPrefix(rangeHeader, "bytes=0-")
+}
+
+// getRangeSize gives bytes asked by `Range` header of request for content of given size, whole size if absent or invalid
+func getRangeSize(r *http.Request, size int64) int64 {
+	rangeHeader := r.Header.Get("Range")
+	if !strings.HasPrefix(rangeHeader, "bytes=") {
+		return size
+	}
+
+	var total int64
+
+	for _, part := range strings.Split(strings.TrimPrefix(rangeHeader, "bytes="), ",") {
+		bounds := strings.SplitN(strings.TrimSpace(part), "-", 2)
+		if len(bounds) != 2 {
+			return size
+		}
+
+		if len(bounds[0]) == 0 {
+			suffix, err := strconv.ParseInt(bounds[1], 10, 64)
+			if err != nil || suffix < 0 {
+				return size
+			}
+
+			if suffix > size {
+				suffix = size
+			}
+
+			total += suffix
+			continue
+		}
+
+		start, err := strconv.ParseInt(bounds[0], 10, 64)
+		if err != nil || start < 0 {
+			return size
+		}
+
+		end := size - 1
+		if len(bounds[1]) != 0 {
+			if end, err = strconv.ParseInt(bounds[1], 10, 64); err != nil {
+				return size
+			}
+
+			if end >= size {
+				end = size - 1
+			}
+		}
+
+		if end >= start {
+			total += end - start + 1
+		}
+	}
+
+	return total
+}
+
+// updateShare applies given change on stored share of request, persistence being deferred to flushShareCounters.
+// Stored share is replaced by an updated copy, never changed in place
+func (a *app) updateShare(request provider.Request, update func(*provider.Share) *provider.Error) *provider.Error {
+	if request.Share == nil {
+		return nil
+	}
+
+	a.metadataLock.Lock()
+	defer a.metadataLock.Unlock()
+
+	for index, stored := range a.metadatas {
+		if stored.ID != request.Share.ID {
+			continue
+		}
+
+		share := *stored
+		if httpErr := update(&share); httpErr != nil {
+			return httpErr
+		}
+
+		a.metadatas[index] = &share
+		a.metadataDirty = true
+
+		return nil
+	}
+
+	return nil
+}
+
+// flushShareCounters persists share counters changed since last save
+func (a *app) flushShareCounters() {
+	if !a.metadataEnabled {
+		return
+	}
+
+	a.metadataLock.Lock()
+	defer a.metadataLock.Unlock()
+
+	if !a.metadataDirty {
+		return
+	}
+
+	if err := a.saveMetadata(); err != nil {
+		logger.Error("unable to save share counters: %s", err)
+	}
+}
+
+// countShareView increments views of share, if any
+func (a *app) countShareView(r *http.Request, request provider.Request) {
+	if r.Method == http.MethodHead {
+		return
+	}
+
+	_ = a.updateShare(request, func(share *provider.Share) *provider.Error {
+		share.Views++
+		return nil
+	})
+}
+
+// startShareDownload checks limits of share for serving the range requested of given size, and counts a new download
+func (a *app) startShareDownload(r *http.Request, request provider.Request, size int64) *provider.Error {
+	return a.startShareTransfer(r, request, getRangeSize(r, size), isNewDownload(r))
+}
+
+// startShareTransfer checks limits of share for serving given size, and counts a new download if asked
+func (a *app) startShareTransfer(r *http.Request, request provider.Request, size int64, newDownload bool) *provider.Error {
+	if request.Share == nil || r.Method == http.MethodHead {
+		return nil
+	}
+
+	return a.updateShare(request, func(share *provider.Share) *provider.Error {
+		if newDownload && share.MaxDownloads != 0 && share.Downloads >= share.MaxDownloads {
+			return provider.NewError(http.StatusGone, ErrShareLimitReached)
+		}
+
+		if share.MaxBytes != 0 && (share.Bytes >= share.MaxBytes || share.Bytes+size > share.MaxBytes) {
+			return provider.NewError(http.StatusGone, ErrShareLimitReached)
+		}
+
+		if newDownload {
+			share.Downloads++
+		}
+
+		return nil
+	})
+}
+
+// endShareDownload adds bytes served to share
+func (a *app) endShareDownload(request provider.Request, written int64) {
+	if written == 0 {
+		return
+	}
+
+	_ = a.updateShare(request, func(share *provider.Share) *provider.Error {
+		share.Bytes += written
+		return nil
+	})
+}
@@ -0,0 +1,287 @@
+package crud
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ViBiOh/fibr/pkg/provider"
+)
+
+func newSharedFile(t *testing.T, values url.Values) (*app, *testRenderer, provider.Request) {
+	crudApp, storage, renderer := newTestApp(t)
+
+	if err := storage.CreateDir("/public"); err != nil {
+		t.Fatalf("CreateDir() = %s", err)
+	}
+
+	if err := storage.Store("/public/report.txt", ioutil.NopCloser(strings.NewReader("0123456789"))); err != nil {
+		t.Fatalf("Store() = %s", err)
+	}
+
+	crudApp.CreateShare(httptest.NewRecorder(), newFormRequest("/public/", values), provider.Request{Path: "/public", Permissions: provider.PermissionAll})
+	if renderer.err != nil {
+		t.Fatalf("CreateShare() = `%v`", renderer.err)
+	}
+
+	share := crudApp.GetShare(crudApp.metadatas[0].ID)
+
+	return crudApp, renderer, provider.Request{Path: "/report.txt", Permissions: share.Permissions, Share: share}
+}
+
+func TestIsNewDownload(t *testing.T) {
+	var cases = []struct {
+		intention string
+		method    string
+		rangeExpr string
+		want      bool
+	}{
+		{
+			"simple",
+			http.MethodGet,
+			"",
+			true,
+		},
+		{
+			"head",
+			http.MethodHead,
+			"",
+			false,
+		},
+		{
+			"first range",
+			http.MethodGet,
+			"bytes=0-1023",
+			true,
+		},
+		{
+			"resume",
+			http.MethodGet,
+			"bytes=1024-",
+			false,
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.intention, func(t *testing.T) {
+			r := httptest.NewRequest(testCase.method, "/report.txt", nil)
+			if len(testCase.rangeExpr) != 0 {
+				r.Header.Set("Range", testCase.rangeExpr)
+			}
+
+			if result := isNewDownload(r); result != testCase.want {
+				t.Errorf("isNewDownload() = %t, want %t", result, testCase.want)
+			}
+		})
+	}
+}
+
+func TestGetRangeSize(t *testing.T) {
+	var cases = []struct {
+		intention string
+		rangeExpr string
+		want      int64
+	}{
+		{
+			"no range",
+			"",
+			100,
+		},
+		{
+			"bounded",
+			"bytes=10-19",
+			10,
+		},
+		{
+			"resume",
+			"bytes=60-",
+			40,
+		},
+		{
+			"suffix",
+			"bytes=-30",
+			30,
+		},
+		{
+			"several",
+			"bytes=0-9, 90-",
+			20,
+		},
+		{
+			"beyond size",
+			"bytes=90-200",
+			10,
+		},
+		{
+			"invalid",
+			"bytes=a-b",
+			100,
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.intention, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/report.txt", nil)
+			if len(testCase.rangeExpr) != 0 {
+				r.Header.Set("Range", testCase.rangeExpr)
+			}
+
+			if result := getRangeSize(r, 100); result != testCase.want {
+				t.Errorf("getRangeSize() = %d, want %d", result, testCase.want)
+			}
+		})
+	}
+}
+
+func TestShareDownloadLimit(t *testing.T) {
+	crudApp, _, request := newSharedFile(t, url.Values{"maxDownloads": {"2"}})
+
+	for i := 0; i < 2; i++ {
+		writer := httptest.NewRecorder()
+		crudApp.Get(writer, httptest.NewRequest(http.MethodGet, "/report.txt", nil), request)
+
+		if writer.Code != http.StatusOK {
+			t.Fatalf("Get() = %d, want download %d allowed", writer.Code, i+1)
+		}
+	}
+
+	writer := httptest.NewRecorder()
+	crudApp.Get(writer, httptest.NewRequest(http.MethodGet, "/report.txt", nil), request)
+
+	if writer.Code != http.StatusGone {
+		t.Errorf("Get() = %d, want gone once limit reached", writer.Code)
+	}
+
+	crudApp.Get(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil), provider.Request{Path: "/", Permissions: request.Permissions, Share: request.Share})
+
+	if share := crudApp.metadatas[0]; share.Downloads != 2 || share.Bytes != 20 || share.Views != 1 {
+		t.Errorf("Get() = %+v, want 2 downloads, 20 bytes and 1 view", share)
+	}
+
+	if content := readStorage(t, crudApp.storage, metadataFilename); strings.Contains(content, `"downloads": 2`) {
+		t.Errorf("saveMetadata() = %s, want counters persisted on flush only", content)
+	}
+
+	crudApp.flushShareCounters()
+
+	if err := crudApp.loadMetadata(); err != nil || crudApp.metadatas[0].Downloads != 2 {
+		t.Errorf("loadMetadata() = (%+v, `%v`), want counters persisted", crudApp.metadatas, err)
+	}
+}
+
+func TestShareDownloadLimitRange(t *testing.T) {
+	crudApp, _, request := newSharedFile(t, url.Values{"maxDownloads": {"1"}})
+
+	crudApp.Get(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/report.txt", nil), request)
+
+	r := httptest.NewRequest(http.MethodGet, "/report.txt", nil)
+	r.Header.Set("Range", "bytes=5-")
+
+	writer := httptest.NewRecorder()
+	crudApp.Get(writer, r, request)
+
+	if writer.Code != http.StatusPartialContent {
+		t.Errorf("Get() = %d, want seek allowed within a download", writer.Code)
+	}
+
+	if share := crudApp.metadatas[0]; share.Downloads != 1 || share.Bytes != 15 {
+		t.Errorf("Get() = %+v, want 1 download and 15 bytes", share)
+	}
+}
+
+func TestShareSnapshot(t *testing.T) {
+	crudApp, _, request := newSharedFile(t, url.Values{})
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	go func() {
+		defer wg.Done()
+		crudApp.Get(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/report.txt", nil), request)
+	}()
+
+	go func() {
+		defer wg.Done()
+		_ = crudApp.moveShares("/public", "/archive")
+	}()
+
+	wg.Wait()
+
+	if request.Share.Downloads != 0 || request.Share.Path != "/public" {
+		t.Errorf("Get() = %+v, want snapshot of request untouched", request.Share)
+	}
+
+	if share := crudApp.metadatas[0]; share.Downloads != 1 || share.Path != "/archive" {
+		t.Errorf("Get() = %+v, want stored share updated", share)
+	}
+}
+
+func TestShareBytesLimit(t *testing.T) {
+	crudApp, _, request := newSharedFile(t, url.Values{"maxBytes": {"15"}})
+
+	writer := httptest.NewRecorder()
+	crudApp.Get(writer, httptest.NewRequest(http.MethodGet, "/report.txt", nil), request)
+
+	if writer.Code != http.StatusOK {
+		t.Fatalf("Get() = %d, want first download allowed", writer.Code)
+	}
+
+	writer = httptest.NewRecorder()
+	crudApp.Get(writer, httptest.NewRequest(http.MethodGet, "/report.txt", nil), request)
+
+	if writer.Code != http.StatusGone {
+		t.Errorf("Get() = %d, want gone when bandwidth would be exceeded", writer.Code)
+	}
+}
+
+func TestShareBytesLimitRange(t *testing.T) {
+	crudApp, _, request := newSharedFile(t, url.Values{"maxBytes": {"15"}})
+
+	writer := httptest.NewRecorder()
+	crudApp.Get(writer, httptest.NewRequest(http.MethodGet, "/report.txt", nil), request)
+
+	if writer.Code != http.StatusOK {
+		t.Fatalf("Get() = %d, want first download allowed", writer.Code)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/report.txt", nil)
+	r.Header.Set("Range", "bytes=5-")
+
+	writer = httptest.NewRecorder()
+	crudApp.Get(writer, r, request)
+
+	if writer.Code != http.StatusPartialContent || crudApp.metadatas[0].Bytes != 15 {
+		t.Errorf("Get() = (%d, %d bytes), want resume charged for its range only", writer.Code, crudApp.metadatas[0].Bytes)
+	}
+}
+
+func TestShareBytesLimitZip(t *testing.T) {
+	crudApp, _, request := newSharedFile(t, url.Values{"maxBytes": {"5"}})
+	request.Path = "/"
+
+	writer := httptest.NewRecorder()
+	crudApp.Get(writer, httptest.NewRequest(http.MethodGet, "/?download", nil), request)
+
+	if writer.Code != http.StatusGone {
+		t.Errorf("Get() = %d, want gone when zip would exceed bandwidth", writer.Code)
+	}
+}
+
+func TestCreateShareInvalidLimit(t *testing.T) {
+	crudApp, storage, _ := newTestApp(t)
+
+	if err := storage.CreateDir("/public"); err != nil {
+		t.Fatalf("CreateDir() = %s", err)
+	}
+
+	writer := httptest.NewRecorder()
+	crudApp.CreateShare(writer, newFormRequest("/public/", url.Values{"maxBytes": {"-1"}}), provider.Request{Path: "/public", Permissions: provider.PermissionAll})
+
+	if writer.Code != http.StatusBadRequest {
+		t.Errorf("CreateShare() = %d, want bad request", writer.Code)
+	}
+}
@@ -7,11 +7,12 @@ import (
 	"path"
 
 	"github.com/ViBiOh/fibr/pkg/provider"
+	"github.com/ViBiOh/httputils/v3/pkg/httpjson"
 )
 
 // Create creates given path directory to filesystem
 func (a *app) Create(w http.ResponseWriter, r *http.Request, request provider.Request) {
-	if !request.CanEdit {
+	if !request.CanCreate() {
 		a.renderer.Error(w, request, provider.NewError(http.StatusForbidden, ErrNotAuthorized))
 		return
 	}
@@ -28,12 +29,59 @@ func (a *app) Create(w http.ResponseWriter, r *http.Request, request provider.Re
 		return
 	}
 
+	policy, httpErr := a.getConflictPolicy(r.FormValue("conflict"))
+	if httpErr != nil {
+		a.renderer.Error(w, request, httpErr)
+		return
+	}
+
 	pathname := request.GetFilepath(name)
 
-	if err := a.storage.CreateDir(pathname); err != nil {
+	if httpErr := checkPermissionOn(request, pathname, provider.PermissionCreate); httpErr != nil {
+		a.renderer.Error(w, request, httpErr)
+		return
+	}
+
+	outcome, httpErr := a.resolveConflict(pathname, policy)
+	if httpErr != nil {
+		a.renderer.Error(w, request, httpErr)
+		return
+	}
+
+	if outcome.overwritten {
+		if existing, err := a.storage.Info(pathname); err == nil && !existing.IsDir {
+			if httpErr := checkPermissionOn(request, pathname, provider.PermissionDelete); httpErr != nil {
+				a.renderer.Error(w, request, httpErr)
+				return
+			}
+
+			if err := a.replaceItem(existing, false); err != nil {
+				a.renderer.Error(w, request, provider.NewError(http.StatusInternalServerError, err))
+				return
+			}
+		}
+	}
+
+	if err := a.storage.CreateDir(outcome.pathname); err != nil {
 		a.renderer.Error(w, request, provider.NewError(http.StatusInternalServerError, err))
 		return
 	}
 
-	http.Redirect(w, r, fmt.Sprintf("%s/?message=%s&messageLevel=success", request.GetURI(name), url.QueryEscape(fmt.Sprintf("Directory %s successfully created", path.Base(pathname)))), http.StatusMovedPermanently)
+	message := fmt.Sprintf("Directory %s successfully created%s", path.Base(pathname), outcome.describe(path.Base(pathname)))
+
+	if request.JSON {
+		info, err := a.storage.Info(outcome.pathname)
+		if err != nil {
+			a.renderer.Error(w, request, provider.NewError(http.StatusInternalServerError, err))
+			return
+		}
+
+		item := a.newAPIItem(request, info)
+		item.Message = message
+
+		httpjson.ResponseJSON(w, http.StatusCreated, item, false)
+		return
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("%s/?message=%s&messageLevel=success", request.GetPathnameURI(outcome.pathname), url.QueryEscape(message)), http.StatusMovedPermanently)
 }
@@ -3,11 +3,13 @@ package crud
 import (
 	"errors"
 	"flag"
+	"fmt"
 	"mime/multipart"
 	"net/http"
 	"regexp"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/ViBiOh/fibr/pkg/provider"
 	"github.com/ViBiOh/fibr/pkg/thumbnail"
@@ -21,6 +23,14 @@ var (
 
 	// ErrEmptyName error returned when user does not provide a name
 	ErrEmptyName = errors.New("provided name is empty")
+
+	// ErrRestrictedContent error returned when an action would reach content hidden or protected by access control list
+	ErrRestrictedContent = errors.New("this contains content you're not allowed to manage")
+)
+
+const (
+	purgeInterval        = time.Hour
+	counterFlushInterval = 10 * time.Second
 )
 
 // App of package
@@ -34,28 +44,64 @@ type App interface {
 	Get(http.ResponseWriter, *http.Request, provider.Request)
 	Post(http.ResponseWriter, *http.Request, provider.Request)
 	Create(http.ResponseWriter, *http.Request, provider.Request)
-	Upload(http.ResponseWriter, *http.Request, provider.Request, *multipart.Part)
+	Upload(http.ResponseWriter, *http.Request, provider.Request, map[string]string, *multipart.Part, *multipart.Reader)
+	Tus(http.ResponseWriter, *http.Request, provider.Request)
 	Rename(http.ResponseWriter, *http.Request, provider.Request)
 	Delete(http.ResponseWriter, *http.Request, provider.Request)
 
+	RemoveItem(provider.StorageItem) error
+	RenameItem(provider.StorageItem, string) (provider.StorageItem, error)
+	OverwriteItem(provider.Request, provider.StorageItem) (provider.WriteAborter, error)
+
 	GetShare(string) *provider.Share
 	CreateShare(http.ResponseWriter, *http.Request, provider.Request)
 	DeleteShare(http.ResponseWriter, *http.Request, provider.Request)
+
+	GetToken(string) *provider.Token
+	CreateToken(http.ResponseWriter, *http.Request, provider.Request)
+	DeleteToken(http.ResponseWriter, *http.Request, provider.Request)
+
+	RestoreTrash(http.ResponseWriter, *http.Request, provider.Request)
+	DeleteTrash(http.ResponseWriter, *http.Request, provider.Request)
+
+	RestoreVersion(http.ResponseWriter, *http.Request, provider.Request)
 }
 
 // Config of package
 type Config struct {
-	metadata        *bool
-	ignore          *string
-	sanitizeOnStart *bool
+	metadata         *bool
+	ignore           *string
+	sanitizeOnStart  *bool
+	uploadExpiration *string
+	conflict         *string
+	trashRetention   *string
+	versionCount     *uint
+	versionAge       *string
 }
 
 type app struct {
 	metadataEnabled bool
 	metadatas       []*provider.Share
 	metadataLock    sync.Mutex
+	metadataDirty   bool
 	sanitizeOnStart bool
 
+	uploads          map[string]bool
+	uploadsLock      sync.Mutex
+	uploadExpiration time.Duration
+
+	conflict string
+
+	tokens     []provider.Token
+	tokensLock sync.Mutex
+
+	trash          []provider.TrashItem
+	trashLock      sync.Mutex
+	trashRetention time.Duration
+
+	versionCount uint
+	versionAge   time.Duration
+
 	storage   provider.Storage
 	renderer  provider.Renderer
 	thumbnail thumbnail.App
@@ -64,19 +110,51 @@ type app struct {
 // Flags adds flags for configuring package
 func Flags(fs *flag.FlagSet, prefix string) Config {
 	return Config{
-		metadata:        flags.New(prefix, "crud").Name("Metadata").Default(true).Label("Enable metadata storage").ToBool(fs),
-		ignore:          flags.New(prefix, "crud").Name("IgnorePattern").Default("").Label("Ignore pattern when listing files or directory").ToString(fs),
-		sanitizeOnStart: flags.New(prefix, "crud").Name("SanitizeOnStart").Default(false).Label("Sanitize name on start").ToBool(fs),
+		metadata:         flags.New(prefix, "crud").Name("Metadata").Default(true).Label("Enable metadata storage").ToBool(fs),
+		ignore:           flags.New(prefix, "crud").Name("IgnorePattern").Default("").Label("Ignore pattern when listing files or directory").ToString(fs),
+		sanitizeOnStart:  flags.New(prefix, "crud").Name("SanitizeOnStart").Default(false).Label("Sanitize name on start").ToBool(fs),
+		conflict:         flags.New(prefix, "crud").Name("Conflict").Default(conflictError).Label("Default policy when target already exists (error, overwrite or rename)").ToString(fs),
+		uploadExpiration: flags.New(prefix, "crud").Name("UploadExpiration").Default("24h").Label("Duration after which an unfinished resumable upload is removed").ToString(fs),
+		trashRetention:   flags.New(prefix, "crud").Name("TrashRetention").Default("720h").Label("Duration during which deleted items are kept in trash, 0 to delete permanently").ToString(fs),
+		versionCount:     flags.New(prefix, "crud").Name("VersionCount").Default(uint(5)).Label("Number of previous versions kept when a file is overwritten, 0 to disable").ToUint(fs),
+		versionAge:       flags.New(prefix, "crud").Name("VersionAge").Default("720h").Label("Duration during which previous versions are kept, 0 for no limit").ToString(fs),
 	}
 }
 
 // New creates new App from Config
 func New(config Config, storage provider.Storage, renderer provider.Renderer, thumbnail thumbnail.App) (App, error) {
+	uploadExpiration, err := time.ParseDuration(strings.TrimSpace(*config.uploadExpiration))
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse upload expiration: %w", err)
+	}
+
+	trashRetention, err := time.ParseDuration(strings.TrimSpace(*config.trashRetention))
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse trash retention: %w", err)
+	}
+
+	versionAge, err := time.ParseDuration(strings.TrimSpace(*config.versionAge))
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse version age: %w", err)
+	}
+
+	conflict := strings.ToLower(strings.TrimSpace(*config.conflict))
+	if err := checkConflictPolicy(conflict); err != nil {
+		return nil, err
+	}
+
 	app := &app{
 		metadataEnabled: *config.metadata,
 		metadataLock:    sync.Mutex{},
 		sanitizeOnStart: *config.sanitizeOnStart,
 
+		uploads:          make(map[string]bool),
+		uploadExpiration: uploadExpiration,
+		conflict:         conflict,
+		trashRetention:   trashRetention,
+		versionCount:     *config.versionCount,
+		versionAge:       versionAge,
+
 		storage:   storage,
 		renderer:  renderer,
 		thumbnail: thumbnail,
@@ -84,6 +162,11 @@ func New(config Config, storage provider.Storage, renderer provider.Renderer, th
 
 	if app.metadataEnabled {
 		logger.Fatal(app.loadMetadata())
+		logger.Fatal(app.loadTokens())
+
+		if app.trashRetention > 0 {
+			logger.Fatal(app.loadTrash())
+		}
 	}
 
 	var ignorePattern *regexp.Regexp
@@ -114,6 +197,12 @@ func New(config Config, storage provider.Storage, renderer provider.Renderer, th
 }
 
 func (a *app) Start() {
+	go func() {
+		for range time.Tick(counterFlushInterval) {
+			a.flushShareCounters()
+		}
+	}()
+
 	err := a.storage.Walk("", func(item provider.StorageItem, _ error) error {
 		if name, err := provider.SanitizeName(item.Pathname, false); err != nil {
 			logger.Error("unable to sanitize name %s: %s", item.Pathname, err)
@@ -128,7 +217,7 @@ func (a *app) Start() {
 			}
 		}
 
-		if thumbnail.CanHaveThumbnail(item) && !a.thumbnail.HasThumbnail(item) {
+		if thumbnail.CanHaveThumbnail(item) && !a.thumbnail.HasAllSizes(item) {
 			a.thumbnail.GenerateThumbnail(item)
 		}
 
@@ -138,15 +227,33 @@ func (a *app) Start() {
 	if err != nil {
 		logger.Error("%s", err)
 	}
+
+	a.purge()
+	for range time.Tick(purgeInterval) {
+		a.purge()
+	}
+}
+
+// purge removes expired shares, tokens, uploads, trash items and versions
+func (a *app) purge() {
+	a.purgeShares()
+	a.purgeTokens()
+	a.purgeTus()
+	a.purgeTrash()
+	a.purgeVersions()
 }
 
-// GetShare returns share configuration if request path match
+// GetShare returns a copy of share configuration if request path match, request having its own snapshot
 func (a *app) GetShare(requestPath string) *provider.Share {
 	cleanPath := strings.TrimPrefix(requestPath, "/")
 
+	a.metadataLock.Lock()
+	defer a.metadataLock.Unlock()
+
 	for _, share := range a.metadatas {
 		if strings.HasPrefix(cleanPath, share.ID) {
-			return share
+			snapshot := *share
+			return &snapshot
 		}
 	}
 
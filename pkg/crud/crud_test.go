@@ -0,0 +1,317 @@
+package crud
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/ViBiOh/fibr/pkg/memory"
+	"github.com/ViBiOh/fibr/pkg/provider"
+	"github.com/ViBiOh/fibr/pkg/thumbnail"
+)
+
+type testRenderer struct {
+	content map[string]interface{}
+	err     *provider.Error
+}
+
+func (t *testRenderer) Directory(_ http.ResponseWriter, _ provider.Request, content map[string]interface{}, _ *provider.Message) {
+	t.content = content
+}
+
+func (t *testRenderer) File(_ http.ResponseWriter, _ provider.Request, content map[string]interface{}, _ *provider.Message) {
+	t.content = content
+}
+
+func (t *testRenderer) Error(w http.ResponseWriter, _ provider.Request, err *provider.Error) {
+	t.err = err
+	w.WriteHeader(err.Status)
+}
+
+func (t *testRenderer) Sitemap(http.ResponseWriter) {
+}
+
+func (t *testRenderer) SVG(http.ResponseWriter, string, string) {
+}
+
+func newTestApp(t *testing.T) (*app, provider.Storage, *testRenderer) {
+	fs := flag.NewFlagSet("crud-test", flag.ContinueOnError)
+	crudConfig := Flags(fs, "")
+	thumbnailConfig := thumbnail.Flags(fs, "thumbnail")
+
+	if err := fs.Parse([]string{"-thumbnailDisable"}); err != nil {
+		t.Fatalf("unable to parse flags: %s", err)
+	}
+
+	storage := memory.New()
+	renderer := &testRenderer{}
+
+	thumbnailApp, err := thumbnail.New(thumbnailConfig, storage)
+	if err != nil {
+		t.Fatalf("unable to create thumbnail: %s", err)
+	}
+
+	crudApp, err := New(crudConfig, storage, renderer, thumbnailApp)
+	if err != nil {
+		t.Fatalf("unable to create app: %s", err)
+	}
+
+	return crudApp.(*app), storage, renderer
+}
+
+func newFormRequest(target string, values url.Values) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, target, strings.NewReader(values.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	return r
+}
+
+func newUploadRequest(t *testing.T, target, filename, content string) *http.Request {
+	return newMultiUploadRequest(t, target, [][2]string{{filename, content}})
+}
+
+func newMultiUploadRequest(t *testing.T, target string, files [][2]string) *http.Request {
+	body := bytes.Buffer{}
+	writer := multipart.NewWriter(&body)
+
+	if err := writer.WriteField("method", http.MethodPost); err != nil {
+		t.Fatalf("unable to write field: %s", err)
+	}
+
+	for _, file := range files {
+		part, err := writer.CreateFormFile("file", file[0])
+		if err != nil {
+			t.Fatalf("unable to create file part: %s", err)
+		}
+
+		if _, err := part.Write([]byte(file[1])); err != nil {
+			t.Fatalf("unable to write file part: %s", err)
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		t.Fatalf("unable to close multipart: %s", err)
+	}
+
+	r := httptest.NewRequest(http.MethodPost, target, &body)
+	r.Header.Set("Content-Type", writer.FormDataContentType())
+
+	return r
+}
+
+func listedNames(content map[string]interface{}) []string {
+	items := content["Files"].([]provider.RenderItem)
+
+	names := make([]string, len(items))
+	for index, item := range items {
+		names[index] = item.Name
+	}
+
+	return names
+}
+
+func TestUploadThenList(t *testing.T) {
+	crudApp, storage, renderer := newTestApp(t)
+	request := provider.Request{Path: "/", Permissions: provider.PermissionEdit}
+
+	writer := httptest.NewRecorder()
+	crudApp.Post(writer, newUploadRequest(t, "/", "Rapport Annuel.txt", "content"), request)
+
+	if writer.Code != http.StatusFound || renderer.err != nil {
+		t.Fatalf("Post() = %d, `%v`", writer.Code, renderer.err)
+	}
+
+	reader, err := storage.ReaderFrom("/rapport_annuel.txt")
+	if err != nil {
+		t.Fatalf("ReaderFrom() = %s", err)
+	}
+
+	if content, err := ioutil.ReadAll(reader); err != nil || string(content) != "content" {
+		t.Errorf("ReadAll() = (`%s`, `%s`), want `content`", content, err)
+	}
+
+	crudApp.List(httptest.NewRecorder(), request, nil)
+
+	if names := listedNames(renderer.content); len(names) != 1 || names[0] != "rapport_annuel.txt" {
+		t.Errorf("List() = %#v, want uploaded file", names)
+	}
+}
+
+func TestUploadNotAuthorized(t *testing.T) {
+	crudApp, _, renderer := newTestApp(t)
+
+	writer := httptest.NewRecorder()
+	crudApp.Post(writer, newUploadRequest(t, "/", "file.txt", "content"), provider.Request{Path: "/"})
+
+	if writer.Code != http.StatusForbidden || renderer.err == nil || renderer.err.Err != ErrNotAuthorized {
+		t.Errorf("Post() = %d, `%v`, want forbidden", writer.Code, renderer.err)
+	}
+}
+
+func TestShareThenDelete(t *testing.T) {
+	crudApp, storage, renderer := newTestApp(t)
+
+	if err := storage.CreateDir("/photos"); err != nil {
+		t.Fatalf("CreateDir() = %s", err)
+	}
+
+	crudApp.CreateShare(httptest.NewRecorder(), newFormRequest("/photos/", url.Values{"edit": {"true"}}), provider.Request{Path: "/photos", Permissions: provider.PermissionAll})
+	if renderer.err != nil {
+		t.Fatalf("CreateShare() = `%v`", renderer.err)
+	}
+
+	if len(crudApp.metadatas) != 1 || crudApp.metadatas[0].Path != "/photos" || crudApp.metadatas[0].Permissions != provider.PermissionEdit {
+		t.Fatalf("CreateShare() = %+v, want one edit share on /photos", crudApp.metadatas)
+	}
+
+	if share := crudApp.GetShare("/" + crudApp.metadatas[0].ID + "/"); share == nil {
+		t.Errorf("GetShare() = nil, want created share")
+	}
+
+	writer := httptest.NewRecorder()
+	crudApp.Delete(writer, newFormRequest("/", url.Values{"name": {"photos"}}), provider.Request{Path: "/", Permissions: provider.PermissionEdit})
+
+	if writer.Code != http.StatusFound || renderer.err != nil {
+		t.Fatalf("Delete() = %d, `%v`", writer.Code, renderer.err)
+	}
+
+	if _, err := storage.Info("/photos"); !provider.IsNotExist(err) {
+		t.Errorf("Info() = `%s`, want directory deleted", err)
+	}
+
+	if len(crudApp.metadatas) != 0 {
+		t.Errorf("Delete() = %+v, want share deleted with directory", crudApp.metadatas)
+	}
+}
+
+func TestRenameExisting(t *testing.T) {
+	crudApp, storage, renderer := newTestApp(t)
+
+	for _, name := range []string{"/first.txt", "/second.txt"} {
+		if err := storage.Store(name, ioutil.NopCloser(strings.NewReader(name))); err != nil {
+			t.Fatalf("Store() = %s", err)
+		}
+	}
+
+	writer := httptest.NewRecorder()
+	crudApp.Rename(writer, newFormRequest("/", url.Values{"name": {"first.txt"}, "newName": {"second.txt"}}), provider.Request{Path: "/", Permissions: provider.PermissionEdit})
+
+	if writer.Code != http.StatusBadRequest || renderer.err == nil {
+		t.Errorf("Rename() = %d, `%v`, want bad request", writer.Code, renderer.err)
+	}
+}
+
+func TestOverwriteNeedsDelete(t *testing.T) {
+	var cases = []struct {
+		intention  string
+		permission provider.Permission
+		action     func(*app, http.ResponseWriter, provider.Request)
+		wantStatus int
+	}{
+		{
+			"rename without delete",
+			provider.PermissionRead | provider.PermissionRename,
+			func(crudApp *app, w http.ResponseWriter, request provider.Request) {
+				crudApp.Rename(w, newFormRequest("/", url.Values{"name": {"first.txt"}, "newName": {"second.txt"}, "conflict": {"overwrite"}}), request)
+			},
+			http.StatusForbidden,
+		},
+		{
+			"rename with delete",
+			provider.PermissionRead | provider.PermissionRename | provider.PermissionDelete,
+			func(crudApp *app, w http.ResponseWriter, request provider.Request) {
+				crudApp.Rename(w, newFormRequest("/", url.Values{"name": {"first.txt"}, "newName": {"second.txt"}, "conflict": {"overwrite"}}), request)
+			},
+			http.StatusFound,
+		},
+		{
+			"create without delete",
+			provider.PermissionRead | provider.PermissionCreate,
+			func(crudApp *app, w http.ResponseWriter, request provider.Request) {
+				crudApp.Create(w, newFormRequest("/", url.Values{"name": {"second.txt"}, "conflict": {"overwrite"}}), request)
+			},
+			http.StatusForbidden,
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.intention, func(t *testing.T) {
+			crudApp, storage, _ := newTestApp(t)
+
+			for _, name := range []string{"/first.txt", "/second.txt"} {
+				if err := storage.Store(name, ioutil.NopCloser(strings.NewReader(name))); err != nil {
+					t.Fatalf("Store() = %s", err)
+				}
+			}
+
+			share := &provider.Share{ID: "abcdef", Path: "/", Permissions: testCase.permission}
+
+			writer := httptest.NewRecorder()
+			testCase.action(crudApp, writer, provider.Request{Path: "/", Permissions: share.Permissions, Share: share})
+
+			if writer.Code != testCase.wantStatus {
+				t.Errorf("action() = %d, want %d", writer.Code, testCase.wantStatus)
+			}
+
+			if testCase.wantStatus == http.StatusForbidden {
+				if content := readStorage(t, storage, "/second.txt"); content != "/second.txt" {
+					t.Errorf("action() = `%s`, want existing file untouched", content)
+				}
+			}
+		})
+	}
+}
+
+func TestJSON(t *testing.T) {
+	crudApp, storage, renderer := newTestApp(t)
+	request := provider.Request{Path: "/", Permissions: provider.PermissionAll, JSON: true}
+
+	writer := httptest.NewRecorder()
+	crudApp.Create(writer, newFormRequest("/", url.Values{"name": {"photos"}}), request)
+
+	var item provider.APIItem
+	if err := json.NewDecoder(writer.Body).Decode(&item); writer.Code != http.StatusCreated || err != nil || item.URL != "/photos/" || !item.IsDir {
+		t.Errorf("Create() = (%d, %+v, `%v`), want created directory", writer.Code, item, err)
+	}
+
+	writer = httptest.NewRecorder()
+	crudApp.Post(writer, newUploadRequest(t, "/photos/", "beach.txt", "sunny"), provider.Request{Path: "/photos/", Permissions: provider.PermissionEdit, JSON: true})
+
+	var upload provider.APIItem
+	if err := json.NewDecoder(writer.Body).Decode(&upload); writer.Code != http.StatusCreated || err != nil || upload.URL != "/photos/beach.txt" || upload.Size != 5 || len(upload.Message) == 0 {
+		t.Errorf("Upload() = (%d, %+v, `%v`), want uploaded file", writer.Code, upload, err)
+	}
+
+	writer = httptest.NewRecorder()
+	crudApp.CreateShare(writer, newFormRequest("/photos", url.Values{"password": {"secret"}}), provider.Request{Path: "/photos", Permissions: provider.PermissionAll, JSON: true})
+
+	var share provider.APIShare
+	if err := json.NewDecoder(writer.Body).Decode(&share); writer.Code != http.StatusCreated || err != nil || !share.PasswordProtected || share.Path != "/photos" {
+		t.Errorf("CreateShare() = (%d, %+v, `%v`), want created share", writer.Code, share, err)
+	}
+
+	writer = httptest.NewRecorder()
+	crudApp.DeleteShare(writer, newFormRequest("/", url.Values{"id": {"unknown"}}), request)
+
+	if writer.Code != http.StatusNotFound || renderer.err == nil {
+		t.Errorf("DeleteShare() = %d, want not found", writer.Code)
+	}
+
+	writer = httptest.NewRecorder()
+	crudApp.Delete(writer, newFormRequest("/photos/", url.Values{"name": {"beach.txt"}}), provider.Request{Path: "/photos/", Permissions: provider.PermissionEdit, JSON: true})
+
+	if writer.Code != http.StatusOK {
+		t.Errorf("Delete() = %d, want %d", writer.Code, http.StatusOK)
+	}
+
+	if _, err := storage.Info("/photos/beach.txt"); !provider.IsNotExist(err) {
+		t.Errorf("Delete() did not remove file: %s", err)
+	}
+}
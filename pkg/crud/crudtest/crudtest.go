@@ -4,6 +4,7 @@ import (
 	"mime/multipart"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/ViBiOh/fibr/pkg/provider"
 	"golang.org/x/crypto/bcrypt"
@@ -12,23 +13,55 @@ import (
 var (
 	// PasswordLessShare instance
 	PasswordLessShare = &provider.Share{
-		ID:       "a1b2c3d4f5",
-		Edit:     false,
-		RootName: "public",
-		File:     false,
-		Path:     "/public",
+		ID:          "a1b2c3d4f5",
+		Permissions: provider.PermissionRead,
+		RootName:    "public",
+		File:        false,
+		Path:        "/public",
 	}
 
 	passwordHash, _ = bcrypt.GenerateFromPassword([]byte("password"), 12)
 
 	// PasswordShare instance
 	PasswordShare = &provider.Share{
-		ID:       "f5d4c3b2a1",
-		Edit:     true,
-		RootName: "private",
-		File:     false,
-		Path:     "/private",
-		Password: string(passwordHash),
+		ID:          "f5d4c3b2a1",
+		Permissions: provider.PermissionEdit,
+		RootName:    "private",
+		File:        false,
+		Path:        "/private",
+		Password:    string(passwordHash),
+	}
+
+	// ExpiredShare instance
+	ExpiredShare = &provider.Share{
+		ID:          "e1d2c3b4a5",
+		Permissions: provider.PermissionRead,
+		RootName:    "archive",
+		File:        false,
+		Path:        "/archive",
+		Expiration:  time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+
+	// DropShare instance
+	DropShare = &provider.Share{
+		ID:          "d1b2c3a4f5",
+		Permissions: provider.PermissionUpload,
+		RootName:    "inbox",
+		File:        false,
+		Path:        "/inbox",
+	}
+
+	// TokenSecret is the secret of ScriptToken
+	TokenSecret = provider.TokenPrefix + "0123456789abcdef"
+
+	// ScriptToken instance, restricted to /builds
+	ScriptToken = &provider.Token{
+		ID:          "t1o2k3e4n5",
+		Name:        "ci",
+		Hash:        provider.HashToken(TokenSecret),
+		Owner:       "admin",
+		Path:        "/builds",
+		Permissions: provider.PermissionRead | provider.PermissionUpload,
 	}
 )
 
@@ -70,7 +103,11 @@ func (a App) Create(http.ResponseWriter, *http.Request, provider.Request) {
 }
 
 // Upload mocked implementation
-func (a App) Upload(http.ResponseWriter, *http.Request, provider.Request, *multipart.Part) {
+func (a App) Upload(http.ResponseWriter, *http.Request, provider.Request, map[string]string, *multipart.Part, *multipart.Reader) {
+}
+
+// Tus mocked implementation
+func (a App) Tus(http.ResponseWriter, *http.Request, provider.Request) {
 }
 
 // Rename mocked implementation
@@ -81,6 +118,21 @@ func (a App) Rename(http.ResponseWriter, *http.Request, provider.Request) {
 func (a App) Delete(http.ResponseWriter, *http.Request, provider.Request) {
 }
 
+// RemoveItem mocked implementation
+func (a App) RemoveItem(provider.StorageItem) error {
+	return nil
+}
+
+// RenameItem mocked implementation
+func (a App) RenameItem(item provider.StorageItem, _ string) (provider.StorageItem, error) {
+	return item, nil
+}
+
+// OverwriteItem mocked implementation
+func (a App) OverwriteItem(provider.Request, provider.StorageItem) (provider.WriteAborter, error) {
+	return nil, nil
+}
+
 // GetShare mocked implementation
 func (a App) GetShare(path string) *provider.Share {
 	if strings.HasPrefix(path, "/a1b2c3d4f5") {
@@ -91,6 +143,14 @@ func (a App) GetShare(path string) *provider.Share {
 		return PasswordShare
 	}
 
+	if strings.HasPrefix(path, "/e1d2c3b4a5") {
+		return ExpiredShare
+	}
+
+	if strings.HasPrefix(path, "/d1b2c3a4f5") {
+		return DropShare
+	}
+
 	return nil
 }
 
@@ -101,3 +161,32 @@ func (a App) CreateShare(http.ResponseWriter, *http.Request, provider.Request) {
 // DeleteShare mocked implementation
 func (a App) DeleteShare(http.ResponseWriter, *http.Request, provider.Request) {
 }
+
+// GetToken mocked implementation
+func (a App) GetToken(secret string) *provider.Token {
+	if secret == TokenSecret {
+		return ScriptToken
+	}
+
+	return nil
+}
+
+// CreateToken mocked implementation
+func (a App) CreateToken(http.ResponseWriter, *http.Request, provider.Request) {
+}
+
+// DeleteToken mocked implementation
+func (a App) DeleteToken(http.ResponseWriter, *http.Request, provider.Request) {
+}
+
+// RestoreTrash mocked implementation
+func (a App) RestoreTrash(http.ResponseWriter, *http.Request, provider.Request) {
+}
+
+// DeleteTrash mocked implementation
+func (a App) DeleteTrash(http.ResponseWriter, *http.Request, provider.Request) {
+}
+
+// RestoreVersion mocked implementation
+func (a App) RestoreVersion(http.ResponseWriter, *http.Request, provider.Request) {
+}
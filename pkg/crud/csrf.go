@@ -0,0 +1,34 @@
+package crud
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+
+	"github.com/ViBiOh/fibr/pkg/provider"
+)
+
+var (
+	// ErrInvalidCSRF occurs when a form is submitted without the anti-CSRF token of browser
+	ErrInvalidCSRF = errors.New("invalid anti-CSRF token, reload the page and try again")
+
+	// ErrCrossOrigin occurs when a form is submitted from another site
+	ErrCrossOrigin = errors.New("form submitted from another site")
+)
+
+// checkCSRF verifies that form comes from a page served to the browser, when request expects an anti-CSRF token
+func checkCSRF(r *http.Request, request provider.Request, token string) *provider.Error {
+	if len(request.CSRF) == 0 {
+		return nil
+	}
+
+	if !provider.IsSameOrigin(r) {
+		return provider.NewError(http.StatusForbidden, ErrCrossOrigin)
+	}
+
+	if subtle.ConstantTimeCompare([]byte(token), []byte(request.CSRF)) != 1 {
+		return provider.NewError(http.StatusForbidden, ErrInvalidCSRF)
+	}
+
+	return nil
+}
@@ -0,0 +1,133 @@
+package crud
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/ViBiOh/fibr/pkg/provider"
+)
+
+const testCSRF = "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+func TestCheckCSRF(t *testing.T) {
+	var cases = []struct {
+		intention string
+		csrf      string
+		token     string
+		headers   map[string]string
+		want      error
+	}{
+		{
+			"not expected",
+			"",
+			"",
+			map[string]string{"Origin": "https://evil.example.com"},
+			nil,
+		},
+		{
+			"valid",
+			testCSRF,
+			testCSRF,
+			map[string]string{"Origin": "http://example.com"},
+			nil,
+		},
+		{
+			"missing token",
+			testCSRF,
+			"",
+			nil,
+			ErrInvalidCSRF,
+		},
+		{
+			"other token",
+			testCSRF,
+			"fedcba9876543210fedcba9876543210fedcba9876543210fedcba9876543210",
+			nil,
+			ErrInvalidCSRF,
+		},
+		{
+			"cross origin",
+			testCSRF,
+			testCSRF,
+			map[string]string{"Origin": "https://evil.example.com"},
+			ErrCrossOrigin,
+		},
+		{
+			"opaque origin",
+			testCSRF,
+			testCSRF,
+			map[string]string{"Origin": "null"},
+			ErrCrossOrigin,
+		},
+		{
+			"cross referer",
+			testCSRF,
+			testCSRF,
+			map[string]string{"Referer": "https://evil.example.com/page.html"},
+			ErrCrossOrigin,
+		},
+		{
+			"same referer",
+			testCSRF,
+			testCSRF,
+			map[string]string{"Referer": "http://example.com/photos/"},
+			nil,
+		},
+		{
+			"reverse proxy",
+			testCSRF,
+			testCSRF,
+			map[string]string{"Origin": "https://fibr.example.com", "X-Forwarded-Host": "fibr.example.com"},
+			nil,
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.intention, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/", nil)
+			for key, value := range testCase.headers {
+				r.Header.Set(key, value)
+			}
+
+			err := checkCSRF(r, provider.Request{CSRF: testCase.csrf}, testCase.token)
+
+			if testCase.want == nil && err != nil {
+				t.Errorf("checkCSRF() = `%s`, want nil", err.Err)
+			} else if testCase.want != nil && (err == nil || err.Err != testCase.want || err.Status != http.StatusForbidden) {
+				t.Errorf("checkCSRF() = %+v, want `%s`", err, testCase.want)
+			}
+		})
+	}
+}
+
+func TestPostCSRF(t *testing.T) {
+	crudApp, storage, _ := newTestApp(t)
+	request := provider.Request{Path: "/", Permissions: provider.PermissionEdit, CSRF: testCSRF}
+
+	writer := httptest.NewRecorder()
+	crudApp.Post(writer, newFormRequest("/", url.Values{"type": {"folder"}, "method": {http.MethodPut}, "name": {"forged"}}), request)
+
+	if writer.Code != http.StatusForbidden {
+		t.Errorf("Post() = %d, want %d", writer.Code, http.StatusForbidden)
+	}
+
+	if _, err := storage.Info("/forged"); !provider.IsNotExist(err) {
+		t.Errorf("Info() = `%v`, want forged folder not created", err)
+	}
+
+	writer = httptest.NewRecorder()
+	crudApp.Post(writer, newUploadRequest(t, "/", "forged.txt", "forged"), request)
+
+	if writer.Code != http.StatusForbidden {
+		t.Errorf("Post() = %d, want %d for upload", writer.Code, http.StatusForbidden)
+	}
+
+	writer = httptest.NewRecorder()
+	crudApp.Post(writer, newFormRequest("/", url.Values{"csrf": {testCSRF}, "type": {"folder"}, "method": {http.MethodPut}, "name": {"legit"}}), request)
+
+	if _, err := storage.Info("/legit"); err != nil {
+		t.Errorf("Info() = `%s`, want legit folder created", err)
+	}
+}
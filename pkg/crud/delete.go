@@ -4,14 +4,72 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
-	"strings"
 
 	"github.com/ViBiOh/fibr/pkg/provider"
+	"github.com/ViBiOh/httputils/v3/pkg/httpjson"
+	"github.com/ViBiOh/httputils/v3/pkg/logger"
 )
 
-// Delete given path from filesystem
+// discardItem removes given item, moving it to trash when enabled, along its versions if asked
+func (a *app) discardItem(info provider.StorageItem, versions bool) error {
+	if a.trashEnabled() {
+		if err := a.moveToTrash(info, versions); err != nil {
+			return err
+		}
+	} else if err := a.storage.Remove(info.Pathname); err != nil {
+		return err
+	} else if versions {
+		if err := a.removeVersions(info.Pathname); err != nil {
+			logger.Error("unable to remove versions of %s: %s", info.Pathname, err)
+		}
+	}
+
+	go a.thumbnail.Remove(info)
+
+	return nil
+}
+
+// replaceItem clears given item before something takes its place, removing its shares. A file replaced by a file
+// is kept as a version when versioning is enabled, anything else is discarded along its versions
+func (a *app) replaceItem(existing provider.StorageItem, byFile bool) error {
+	if err := a.replaceContent(existing, byFile); err != nil {
+		return err
+	}
+
+	return a.removeShares(existing.Pathname)
+}
+
+// replaceContent clears given item like replaceItem, but keeps its shares
+func (a *app) replaceContent(existing provider.StorageItem, byFile bool) error {
+	if byFile && !existing.IsDir && a.versionEnabled() {
+		if err := a.saveVersion(existing.Pathname); err != nil {
+			return err
+		}
+
+		if err := a.storage.Remove(existing.Pathname); err != nil {
+			return err
+		}
+
+		go a.thumbnail.Remove(existing)
+
+		return nil
+	}
+
+	return a.discardItem(existing, !byFile)
+}
+
+// RemoveItem deletes given item, its versions and its shares, moving it to trash when enabled
+func (a *app) RemoveItem(info provider.StorageItem) error {
+	if err := a.discardItem(info, true); err != nil {
+		return err
+	}
+
+	return a.removeShares(info.Pathname)
+}
+
+// Delete given path from filesystem, moving it to trash when enabled
 func (a *app) Delete(w http.ResponseWriter, r *http.Request, request provider.Request) {
-	if !request.CanEdit {
+	if !request.CanDelete() {
 		a.renderer.Error(w, request, provider.NewError(http.StatusForbidden, ErrNotAuthorized))
 		return
 	}
@@ -28,28 +86,25 @@ func (a *app) Delete(w http.ResponseWriter, r *http.Request, request provider.Re
 		return
 	}
 
-	if err := a.storage.Remove(info.Pathname); err != nil {
-		a.renderer.Error(w, request, provider.NewError(http.StatusInternalServerError, err))
+	if httpErr := checkPermissionOn(request, info.Pathname, provider.PermissionDelete); httpErr != nil {
+		a.renderer.Error(w, request, httpErr)
 		return
 	}
 
-	a.metadataLock.Lock()
-	defer a.metadataLock.Unlock()
+	if err := a.RemoveItem(info); err != nil {
+		a.renderer.Error(w, request, provider.NewError(http.StatusInternalServerError, err))
+		return
+	}
 
-	newMetas := make([]*provider.Share, 0)
-	for _, metadata := range a.metadatas {
-		if !strings.HasPrefix(metadata.Path, info.Pathname) {
-			newMetas = append(newMetas, metadata)
-		}
+	message := fmt.Sprintf("%s successfully deleted", info.Name)
+	if a.trashEnabled() {
+		message = fmt.Sprintf("%s moved to trash", info.Name)
 	}
 
-	a.metadatas = newMetas
-	if err := a.saveMetadata(); err != nil {
-		a.renderer.Error(w, request, provider.NewError(http.StatusInternalServerError, err))
+	if request.JSON {
+		httpjson.ResponseJSON(w, http.StatusOK, a.newAPIItem(request, info), false)
 		return
 	}
 
-	go a.thumbnail.Remove(info)
-
-	http.Redirect(w, r, fmt.Sprintf("%s/?message=%s&messageLevel=success", request.GetURI(""), url.QueryEscape(fmt.Sprintf("%s successfully deleted", info.Name))), http.StatusFound)
+	http.Redirect(w, r, fmt.Sprintf("%s/?message=%s&messageLevel=success", request.GetURI(""), url.QueryEscape(message)), http.StatusFound)
 }
@@ -0,0 +1,73 @@
+package crud
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+
+	"github.com/ViBiOh/fibr/pkg/provider"
+	"github.com/ViBiOh/fibr/pkg/sha"
+)
+
+const (
+	visitorCookieName   = "fibr_visitor"
+	visitorCookieMaxAge = 365 * 24 * 60 * 60
+)
+
+var (
+	visitorIDPattern = regexp.MustCompile(`^[0-9a-f]{8}$`)
+)
+
+// getVisitorID gives identifier of visitor stored in cookie, creating it if needed
+func getVisitorID(w http.ResponseWriter, r *http.Request, share *provider.Share) (string, error) {
+	if cookie, err := r.Cookie(visitorCookieName); err == nil && visitorIDPattern.MatchString(cookie.Value) {
+		return cookie.Value, nil
+	}
+
+	uuid, err := uuid()
+	if err != nil {
+		return "", fmt.Errorf("unable to generate visitor id: %w", err)
+	}
+
+	id := sha.Sha1(uuid)[:8]
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     visitorCookieName,
+		Value:    id,
+		Path:     fmt.Sprintf("/%s/", share.ID),
+		MaxAge:   visitorCookieMaxAge,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	return id, nil
+}
+
+// getDropRequest scopes an upload-only request to the root of share, or to the folder of visitor
+func getDropRequest(w http.ResponseWriter, r *http.Request, request provider.Request) (provider.Request, *provider.Error) {
+	if !request.UploadOnly() {
+		return request, nil
+	}
+
+	request.Path = "/"
+
+	if request.Share.Subfolder {
+		visitorID, err := getVisitorID(w, r, request.Share)
+		if err != nil {
+			return request, provider.NewError(http.StatusInternalServerError, err)
+		}
+
+		request.Path = fmt.Sprintf("/%s", visitorID)
+	}
+
+	return request, nil
+}
+
+// getDropPolicy prevents visitors of drop box to overwrite or discover existing files
+func getDropPolicy(request provider.Request, policy string) string {
+	if request.UploadOnly() {
+		return conflictRename
+	}
+
+	return policy
+}
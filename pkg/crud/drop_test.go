@@ -0,0 +1,238 @@
+package crud
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/ViBiOh/fibr/pkg/provider"
+)
+
+func newDropShare(t *testing.T, values url.Values) (*app, provider.Storage, *testRenderer, provider.Request) {
+	crudApp, storage, renderer := newTestApp(t)
+
+	if err := storage.CreateDir("/inbox"); err != nil {
+		t.Fatalf("CreateDir() = %s", err)
+	}
+
+	if err := storage.Store("/inbox/report.txt", ioutil.NopCloser(strings.NewReader("secret"))); err != nil {
+		t.Fatalf("Store() = %s", err)
+	}
+
+	values.Set("upload", "true")
+
+	crudApp.CreateShare(httptest.NewRecorder(), newFormRequest("/inbox/", values), provider.Request{Path: "/inbox", Permissions: provider.PermissionAll})
+	if renderer.err != nil {
+		t.Fatalf("CreateShare() = `%v`", renderer.err)
+	}
+
+	return crudApp, storage, renderer, provider.Request{Path: "/", Permissions: crudApp.metadatas[0].Permissions, Share: crudApp.metadatas[0]}
+}
+
+func TestDropShareHidesContent(t *testing.T) {
+	crudApp, _, renderer, request := newDropShare(t, url.Values{})
+
+	crudApp.Get(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil), request)
+
+	if files, _ := renderer.content["Files"].([]provider.RenderItem); len(files) != 0 {
+		t.Errorf("Get() = %+v, want no file listed", files)
+	}
+
+	request.Path = "/report.txt"
+	writer := httptest.NewRecorder()
+	crudApp.Get(writer, httptest.NewRequest(http.MethodGet, "/report.txt", nil), request)
+
+	if strings.Contains(writer.Body.String(), "secret") {
+		t.Errorf("Get() = `%s`, want content not served", writer.Body.String())
+	}
+}
+
+func TestDropShareUpload(t *testing.T) {
+	var cases = []struct {
+		intention string
+		subfolder bool
+		want      string
+	}{
+		{
+			"root",
+			false,
+			"/inbox/report_1.txt",
+		},
+		{
+			"subfolder",
+			true,
+			"/inbox/1234abcd/report.txt",
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.intention, func(t *testing.T) {
+			values := url.Values{}
+			if testCase.subfolder {
+				values.Set("subfolder", "true")
+			}
+
+			crudApp, storage, renderer, request := newDropShare(t, values)
+
+			r := newUploadRequest(t, "/?conflict=overwrite", "report.txt", "dropped")
+			r.AddCookie(&http.Cookie{Name: visitorCookieName, Value: "1234abcd"})
+
+			writer := httptest.NewRecorder()
+			crudApp.Post(writer, r, request)
+
+			if writer.Code != http.StatusFound || renderer.err != nil {
+				t.Fatalf("Post() = %d, `%v`", writer.Code, renderer.err)
+			}
+
+			if content := readStorage(t, storage, "/inbox/report.txt"); content != "secret" {
+				t.Errorf("Post() = `%s`, want existing file untouched", content)
+			}
+
+			if content := readStorage(t, storage, testCase.want); content != "dropped" {
+				t.Errorf("Post() = `%s`, want upload in %s", content, testCase.want)
+			}
+		})
+	}
+}
+
+func TestDropShareUploadHidesConflict(t *testing.T) {
+	var cases = []struct {
+		intention string
+		accept    string
+	}{
+		{
+			"text",
+			"text/plain",
+		},
+		{
+			"json",
+			"application/json",
+		},
+		{
+			"redirect",
+			"",
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.intention, func(t *testing.T) {
+			crudApp, storage, renderer, request := newDropShare(t, url.Values{})
+			request.JSON = testCase.accept == "application/json"
+
+			r := newUploadRequest(t, "/", "report.txt", "dropped")
+			if len(testCase.accept) != 0 {
+				r.Header.Set("Accept", testCase.accept)
+			}
+
+			writer := httptest.NewRecorder()
+			crudApp.Post(writer, r, request)
+
+			if renderer.err != nil {
+				t.Fatalf("Post() = `%v`", renderer.err)
+			}
+
+			if content := readStorage(t, storage, "/inbox/report_1.txt"); content != "dropped" {
+				t.Fatalf("Post() = `%s`, want upload renamed", content)
+			}
+
+			response := fmt.Sprintf("%s %v", writer.Body.String(), writer.Header())
+			if unescaped, err := url.QueryUnescape(response); err == nil {
+				response = unescaped
+			}
+
+			for _, leak := range []string{"report_1", "already exists", "overwritten"} {
+				if strings.Contains(response, leak) {
+					t.Errorf("Post() = `%s`, want no clue of existing file", response)
+				}
+			}
+
+			if !strings.Contains(response, "File report.txt successfully uploaded") {
+				t.Errorf("Post() = `%s`, want neutral message", response)
+			}
+		})
+	}
+}
+
+func TestDropShareTusHidesConflict(t *testing.T) {
+	crudApp, storage, renderer, request := newDropShare(t, url.Values{})
+
+	writer := httptest.NewRecorder()
+	crudApp.Tus(writer, newTusRequest(http.MethodPost, "/", map[string]string{"Upload-Length": "0", "Upload-Metadata": "filename cmVwb3J0LnR4dA=="}, ""), request)
+
+	if writer.Code != http.StatusCreated || renderer.err != nil {
+		t.Fatalf("Tus() = (%d, `%v`)", writer.Code, renderer.err)
+	}
+
+	if _, err := storage.Info("/inbox/report_1.txt"); err != nil {
+		t.Fatalf("Tus() = `%s`, want upload renamed", err)
+	}
+
+	if location := writer.Header().Get("Content-Location"); len(location) != 0 {
+		t.Errorf("Tus() = `%s`, want no Content-Location revealing final name", location)
+	}
+}
+
+func TestDropShareVisitorCookie(t *testing.T) {
+	crudApp, storage, _, request := newDropShare(t, url.Values{"subfolder": {"true"}})
+
+	writer := httptest.NewRecorder()
+	crudApp.Post(writer, newUploadRequest(t, "/", "report.txt", "dropped"), request)
+
+	cookies := writer.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != visitorCookieName || !visitorIDPattern.MatchString(cookies[0].Value) {
+		t.Fatalf("Post() = %+v, want visitor cookie", cookies)
+	}
+
+	if content := readStorage(t, storage, "/inbox/"+cookies[0].Value+"/report.txt"); content != "dropped" {
+		t.Errorf("Post() = `%s`, want upload in visitor folder", content)
+	}
+}
+
+func TestCreateDropShareInvalid(t *testing.T) {
+	var cases = []struct {
+		intention string
+		path      string
+		values    url.Values
+	}{
+		{
+			"edit",
+			"/inbox",
+			url.Values{"upload": {"true"}, "edit": {"true"}},
+		},
+		{
+			"subfolder without upload",
+			"/inbox",
+			url.Values{"subfolder": {"true"}},
+		},
+		{
+			"file",
+			"/inbox/report.txt",
+			url.Values{"upload": {"true"}},
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.intention, func(t *testing.T) {
+			crudApp, storage, _ := newTestApp(t)
+
+			if err := storage.CreateDir("/inbox"); err != nil {
+				t.Fatalf("CreateDir() = %s", err)
+			}
+
+			if err := storage.Store("/inbox/report.txt", ioutil.NopCloser(strings.NewReader("secret"))); err != nil {
+				t.Fatalf("Store() = %s", err)
+			}
+
+			writer := httptest.NewRecorder()
+			crudApp.CreateShare(writer, newFormRequest(testCase.path, testCase.values), provider.Request{Path: testCase.path, Permissions: provider.PermissionAll})
+
+			if writer.Code != http.StatusBadRequest {
+				t.Errorf("CreateShare() = %d, want bad request", writer.Code)
+			}
+		})
+	}
+}
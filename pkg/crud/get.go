@@ -7,6 +7,8 @@ import (
 	"strings"
 
 	"github.com/ViBiOh/fibr/pkg/provider"
+	"github.com/ViBiOh/fibr/pkg/thumbnail"
+	"github.com/ViBiOh/httputils/v3/pkg/logger"
 	"github.com/ViBiOh/httputils/v3/pkg/query"
 )
 
@@ -50,6 +52,16 @@ func (a *app) ServeStatic(w http.ResponseWriter, r *http.Request) bool {
 }
 
 func (a *app) getWithMessage(w http.ResponseWriter, r *http.Request, request provider.Request, message *provider.Message) {
+	if request.UploadOnly() {
+		a.countShareView(r, request)
+		a.renderer.Directory(w, request, map[string]interface{}{
+			"Paths": []string{},
+			"Files": []provider.RenderItem{},
+		}, message)
+
+		return
+	}
+
 	info, err := a.storage.Info(request.GetFilepath(""))
 	if err != nil {
 		if provider.IsNotExist(err) {
@@ -60,30 +72,78 @@ func (a *app) getWithMessage(w http.ResponseWriter, r *http.Request, request pro
 		return
 	}
 
-	if query.GetBool(r, "thumbnail") {
+	if thumbnail.IsRequested(r) {
+		// thumbnails of a directory are only links to thumbnails of its files, which are previews of their content
+		if (info.IsDir && !request.CanList()) || (!info.IsDir && !request.CanDownload()) {
+			a.renderer.Error(w, request, provider.NewError(http.StatusForbidden, ErrNotAuthorized))
+			return
+		}
+
 		if info.IsDir {
-			a.thumbnail.List(w, r, info)
+			items, err := a.storage.List(info.Pathname)
+			if err != nil {
+				a.renderer.Error(w, request, provider.NewError(http.StatusInternalServerError, err))
+				return
+			}
+
+			a.thumbnail.List(w, r, request.FilterVisible(items))
+		} else if httpErr := a.startShareTransfer(r, request, 0, false); httpErr != nil {
+			a.renderer.Error(w, request, httpErr)
 		} else {
-			a.thumbnail.Serve(w, r, info)
+			writer := &countingWriter{ResponseWriter: w}
+			a.thumbnail.Serve(writer, r, info)
+			a.endShareDownload(request, writer.written)
 		}
 
 		return
 	}
 
 	if !info.IsDir {
-		if query.GetBool(r, "browser") {
+		if !request.CanDownload() {
+			a.renderer.Error(w, request, provider.NewError(http.StatusForbidden, ErrNotAuthorized))
+			return
+		}
+
+		if version := r.URL.Query().Get("version"); len(version) != 0 {
+			a.serveVersion(w, r, request, info, version)
+		} else if query.GetBool(r, "browser") || (request.JSON && !query.GetBool(r, "download")) {
+			a.countShareView(r, request)
 			a.Browser(w, request, info, message)
-		} else if file, err := a.storage.ReaderFrom(info.Pathname); err != nil {
-			a.renderer.Error(w, request, provider.NewError(http.StatusInternalServerError, err))
 		} else {
-			http.ServeContent(w, r, info.Name, info.Date, file)
+			a.serveFile(w, r, request, info)
 		}
 
 		return
 	}
 
+	if !request.CanList() {
+		a.renderer.Error(w, request, provider.NewError(http.StatusForbidden, ErrNotAuthorized))
+		return
+	}
+
 	if query.GetBool(r, "download") {
-		a.Download(w, request)
+		if !request.CanDownload() {
+			a.renderer.Error(w, request, provider.NewError(http.StatusForbidden, ErrNotAuthorized))
+			return
+		}
+
+		var size int64
+		if request.Share != nil && request.Share.MaxBytes != 0 {
+			if size, err = a.getZipSize(request); err != nil {
+				a.renderer.Error(w, request, provider.NewError(http.StatusInternalServerError, err))
+				return
+			}
+		}
+
+		if httpErr := a.startShareDownload(r, request, size); httpErr != nil {
+			a.renderer.Error(w, request, httpErr)
+			return
+		}
+
+		writer := &countingWriter{ResponseWriter: w}
+		a.Download(writer, request)
+		a.endShareDownload(request, writer.written)
+
 		return
 	}
 
@@ -92,9 +152,33 @@ func (a *app) getWithMessage(w http.ResponseWriter, r *http.Request, request pro
 		return
 	}
 
+	a.countShareView(r, request)
 	a.List(w, request, message)
 }
 
+func (a *app) serveFile(w http.ResponseWriter, r *http.Request, request provider.Request, info provider.StorageItem) {
+	if httpErr := a.startShareDownload(r, request, info.Size); httpErr != nil {
+		a.renderer.Error(w, request, httpErr)
+		return
+	}
+
+	file, err := a.storage.ReaderFrom(info.Pathname)
+	if err != nil {
+		a.renderer.Error(w, request, provider.NewError(http.StatusInternalServerError, err))
+		return
+	}
+
+	defer func() {
+		if err := file.Close(); err != nil {
+			logger.Error("unable to close file: %s", err)
+		}
+	}()
+
+	writer := &countingWriter{ResponseWriter: w}
+	http.ServeContent(writer, r, info.Name, info.Date, file)
+	a.endShareDownload(request, writer.written)
+}
+
 // Get output content
 func (a *app) Get(w http.ResponseWriter, r *http.Request, request provider.Request) {
 	var message *provider.Message
@@ -5,12 +5,10 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	"os"
 	"path"
 
 	"github.com/ViBiOh/fibr/pkg/provider"
 	"github.com/ViBiOh/fibr/pkg/sha"
-	"github.com/ViBiOh/fibr/pkg/thumbnail"
 	"github.com/ViBiOh/httputils/v3/pkg/logger"
 )
 
@@ -21,10 +19,13 @@ func (a *app) getCover(files []provider.StorageItem) map[string]interface{} {
 		}
 
 		if a.thumbnail.HasThumbnail(file) {
+			cover := a.thumbnail.Cover()
+
 			return map[string]interface{}{
 				"Img":       file,
-				"ImgHeight": thumbnail.Height,
-				"ImgWidth":  thumbnail.Width,
+				"Size":      cover.Name,
+				"ImgHeight": cover.Height,
+				"ImgWidth":  cover.Width,
 			}
 		}
 	}
@@ -40,6 +41,8 @@ func (a *app) List(w http.ResponseWriter, request provider.Request, message *pro
 		return
 	}
 
+	files = request.FilterVisible(files)
+
 	items := make([]provider.RenderItem, len(files))
 	for index, file := range files {
 		items[index] = provider.RenderItem{
@@ -51,11 +54,22 @@ func (a *app) List(w http.ResponseWriter, request provider.Request, message *pro
 	content := map[string]interface{}{
 		"Paths": getPathParts(request.GetURI("")),
 		"Files": items,
-		"Cover": a.getCover(files),
 	}
 
-	if request.CanShare {
-		content["Shares"] = a.metadatas
+	if request.CanDownload() {
+		content["Cover"] = a.getCover(files)
+	}
+
+	if request.CanShare() {
+		content["Shares"] = a.getShares(request)
+	}
+
+	if canManageTokens(request) && a.metadataEnabled {
+		content["Tokens"] = a.getTokens()
+	}
+
+	if canManageTrash(request) && a.trashEnabled() {
+		content["Trash"] = a.getTrash()
 	}
 
 	a.renderer.Directory(w, request, content, message)
@@ -83,17 +97,38 @@ func (a *app) Download(w http.ResponseWriter, request provider.Request) {
 }
 
 func (a *app) zipFiles(request provider.Request, zipWriter *zip.Writer, pathname string) error {
+	return a.walkZipFiles(request, pathname, func(file provider.StorageItem, dirname string) error {
+		return a.addFileToZip(zipWriter, file, dirname)
+	})
+}
+
+// getZipSize gives size of files that a zip of request's directory contains
+func (a *app) getZipSize(request provider.Request) (int64, error) {
+	var size int64
+
+	err := a.walkZipFiles(request, "", func(file provider.StorageItem, _ string) error {
+		size += file.Size
+		return nil
+	})
+
+	return size, err
+}
+
+// walkZipFiles calls given function for every file under pathname that request can download, with its directory relative to request
+func (a *app) walkZipFiles(request provider.Request, pathname string, fn func(provider.StorageItem, string) error) error {
 	files, err := a.storage.List(request.GetFilepath(pathname))
 	if err != nil {
 		return err
 	}
 
-	for _, file := range files {
+	for _, file := range request.FilterVisible(files) {
 		if file.IsDir {
-			if err := a.zipFiles(request, zipWriter, path.Join(pathname, file.Name)); err != nil {
+			if err := a.walkZipFiles(request, path.Join(pathname, file.Name), fn); err != nil {
 				return err
 			}
-		} else if err := a.addFileToZip(zipWriter, file, pathname); err != nil {
+		} else if !request.PermissionOn(file.Pathname).Has(provider.PermissionDownload) {
+			continue
+		} else if err := fn(file, pathname); err != nil {
 			return err
 		}
 	}
@@ -102,14 +137,13 @@ func (a *app) zipFiles(request provider.Request, zipWriter *zip.Writer, pathname
 }
 
 func (a *app) addFileToZip(zipWriter *zip.Writer, file provider.StorageItem, pathname string) error {
-	header, err := zip.FileInfoHeader(file.Info.(os.FileInfo))
-	if err != nil {
-		return err
+	header := &zip.FileHeader{
+		Name:               path.Join(pathname, file.Name),
+		UncompressedSize64: uint64(file.Size),
+		Modified:           file.Date,
+		Method:             zip.Deflate,
 	}
 
-	header.Name = path.Join(pathname, file.Name)
-	header.Method = zip.Deflate
-
 	writer, err := zipWriter.CreateHeader(header)
 	if err != nil {
 		return err
@@ -120,6 +154,12 @@ func (a *app) addFileToZip(zipWriter *zip.Writer, file provider.StorageItem, pat
 		return err
 	}
 
+	defer func() {
+		if err := reader.Close(); err != nil {
+			logger.Error("unable to close zipped file: %s", err)
+		}
+	}()
+
 	_, err = io.Copy(writer, reader)
 	return err
 }
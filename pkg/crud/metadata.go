@@ -1,10 +1,10 @@
 package crud
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"io/ioutil"
 	"path"
 
@@ -66,26 +66,10 @@ func (a *app) saveMetadata() (err error) {
 		return err
 	}
 
-	file, err := a.storage.WriterTo(metadataFilename)
-	if file != nil {
-		defer func() {
-			if closeErr := file.Close(); closeErr != nil {
-				err = fmt.Errorf("%s: %w", err, closeErr)
-			}
-		}()
-	}
-	if err != nil {
+	if err = a.storage.Store(metadataFilename, ioutil.NopCloser(bytes.NewReader(content))); err != nil {
 		return err
 	}
 
-	n, err := file.Write(content)
-	if err != nil {
-		return err
-	}
-
-	if n < len(content) {
-		return io.ErrShortWrite
-	}
-
+	a.metadataDirty = false
 	return nil
 }
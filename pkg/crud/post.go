@@ -11,49 +11,52 @@ import (
 	"github.com/ViBiOh/fibr/pkg/provider"
 )
 
-func parseMultipart(r *http.Request) (string, *multipart.Part, error) {
+// parseMultipart reads form values until first file part, so they have to be sent before files
+func parseMultipart(r *http.Request) (map[string]string, *multipart.Part, *multipart.Reader, error) {
 	reader, err := r.MultipartReader()
 	if err != nil {
-		return "", nil, err
+		return nil, nil, nil, err
 	}
 
-	var (
-		method   string
-		filePart *multipart.Part
-	)
+	values := make(map[string]string)
 
 	for {
 		part, err := reader.NextPart()
 		if err == io.EOF {
-			return method, filePart, nil
+			return values, nil, reader, nil
 		}
 
-		switch part.FormName() {
-		case "method":
-			value, err := ioutil.ReadAll(part)
-			if err != nil {
-				return "", nil, err
-			}
+		if err != nil {
+			return nil, nil, nil, err
+		}
 
-			method = string(value)
+		if part.FormName() == "file" {
+			return values, part, reader, nil
+		}
 
-		case "file":
-			if len(method) != 0 {
-				return method, part, nil
-			}
-			filePart = part
+		value, err := ioutil.ReadAll(part)
+		if err != nil {
+			return nil, nil, nil, err
 		}
+
+		values[part.FormName()] = string(value)
 	}
 }
 
-// Post handle post from form
+// Post handle post from form, checking anti-CSRF token when request expects one
 func (a *app) Post(w http.ResponseWriter, r *http.Request, request provider.Request) {
 	contentType := r.Header.Get("Content-Type")
 
 	if contentType == "application/x-www-form-urlencoded" {
+		if err := checkCSRF(r, request, r.FormValue(provider.CSRFFieldName)); err != nil {
+			a.renderer.Error(w, request, err)
+			return
+		}
+
 		method := r.FormValue("method")
 
-		if r.FormValue("type") == "share" {
+		switch r.FormValue("type") {
+		case "share":
 			switch method {
 			case http.MethodPost:
 				a.CreateShare(w, r, request)
@@ -62,7 +65,32 @@ func (a *app) Post(w http.ResponseWriter, r *http.Request, request provider.Requ
 			default:
 				a.renderer.Error(w, request, provider.NewError(http.StatusMethodNotAllowed, fmt.Errorf("unknown share method `%s` for %s", method, r.URL.Path)))
 			}
-		} else {
+		case "token":
+			switch method {
+			case http.MethodPost:
+				a.CreateToken(w, r, request)
+			case http.MethodDelete:
+				a.DeleteToken(w, r, request)
+			default:
+				a.renderer.Error(w, request, provider.NewError(http.StatusMethodNotAllowed, fmt.Errorf("unknown token method `%s` for %s", method, r.URL.Path)))
+			}
+		case "version":
+			switch method {
+			case http.MethodPatch:
+				a.RestoreVersion(w, r, request)
+			default:
+				a.renderer.Error(w, request, provider.NewError(http.StatusMethodNotAllowed, fmt.Errorf("unknown version method `%s` for %s", method, r.URL.Path)))
+			}
+		case "trash":
+			switch method {
+			case http.MethodPatch:
+				a.RestoreTrash(w, r, request)
+			case http.MethodDelete:
+				a.DeleteTrash(w, r, request)
+			default:
+				a.renderer.Error(w, request, provider.NewError(http.StatusMethodNotAllowed, fmt.Errorf("unknown trash method `%s` for %s", method, r.URL.Path)))
+			}
+		default:
 			switch method {
 			case http.MethodPatch:
 				a.Rename(w, r, request)
@@ -79,18 +107,23 @@ func (a *app) Post(w http.ResponseWriter, r *http.Request, request provider.Requ
 	}
 
 	if strings.HasPrefix(contentType, "multipart/form-data") {
-		method, file, err := parseMultipart(r)
+		values, file, reader, err := parseMultipart(r)
 		if err != nil {
 			a.renderer.Error(w, request, provider.NewError(http.StatusInternalServerError, fmt.Errorf("unable to parse multipart request: %s", err)))
 			return
 		}
 
-		if method != http.MethodPost {
+		if err := checkCSRF(r, request, values[provider.CSRFFieldName]); err != nil {
+			a.renderer.Error(w, request, err)
+			return
+		}
+
+		if method := values["method"]; method != http.MethodPost {
 			a.renderer.Error(w, request, provider.NewError(http.StatusMethodNotAllowed, fmt.Errorf("unknown method `%s` for multipart", method)))
 			return
 		}
 
-		a.Upload(w, r, request, file)
+		a.Upload(w, r, request, values, file, reader)
 		return
 	}
 
@@ -5,10 +5,18 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"path"
 
 	"github.com/ViBiOh/fibr/pkg/provider"
+	"github.com/ViBiOh/httputils/v3/pkg/httpjson"
+	"github.com/ViBiOh/httputils/v3/pkg/logger"
 )
 
+// RenameItem moves given item to a new path, along its shares and versions
+func (a *app) RenameItem(oldItem provider.StorageItem, newPath string) (provider.StorageItem, error) {
+	return a.doRename(oldItem.Pathname, newPath, oldItem)
+}
+
 func (a *app) doRename(oldPath, newPath string, oldItem provider.StorageItem) (provider.StorageItem, error) {
 	if err := a.storage.Rename(oldPath, newPath); err != nil {
 		return provider.StorageItem{}, err
@@ -19,6 +27,14 @@ func (a *app) doRename(oldPath, newPath string, oldItem provider.StorageItem) (p
 		return provider.StorageItem{}, err
 	}
 
+	if err := a.moveVersions(oldPath, newPath); err != nil {
+		logger.Error("unable to move versions of %s: %s", oldPath, err)
+	}
+
+	if err := a.moveShares(oldPath, newPath); err != nil {
+		logger.Error("unable to move shares of %s: %s", oldPath, err)
+	}
+
 	go a.thumbnail.Rename(oldItem, newItem)
 
 	return newItem, nil
@@ -26,7 +42,7 @@ func (a *app) doRename(oldPath, newPath string, oldItem provider.StorageItem) (p
 
 // Rename rename given path to a new one
 func (a *app) Rename(w http.ResponseWriter, r *http.Request, request provider.Request) {
-	if !request.CanEdit {
+	if !request.CanRename() {
 		a.renderer.Error(w, request, provider.NewError(http.StatusForbidden, ErrNotAuthorized))
 		return
 	}
@@ -49,17 +65,27 @@ func (a *app) Rename(w http.ResponseWriter, r *http.Request, request provider.Re
 		return
 	}
 
+	policy, httpErr := a.getConflictPolicy(r.FormValue("conflict"))
+	if httpErr != nil {
+		a.renderer.Error(w, request, httpErr)
+		return
+	}
+
 	oldPath := request.GetFilepath(oldName)
 	newPath := request.GetFilepath(newSafeName)
 
-	if _, err := a.storage.Info(newPath); err == nil {
-		a.renderer.Error(w, request, provider.NewError(http.StatusBadRequest, errors.New("new name already exist")))
-		return
-	} else if !provider.IsNotExist(err) {
-		a.renderer.Error(w, request, provider.NewError(http.StatusInternalServerError, err))
+	if oldPath == newPath {
+		a.renderer.Error(w, request, provider.NewError(http.StatusBadRequest, errors.New("new name is the same as current one")))
 		return
 	}
 
+	for _, pathname := range []string{oldPath, newPath} {
+		if httpErr := checkPermissionOn(request, pathname, provider.PermissionRename); httpErr != nil {
+			a.renderer.Error(w, request, httpErr)
+			return
+		}
+	}
+
 	oldItem, err := a.storage.Info(oldPath)
 	if err != nil {
 		if !provider.IsNotExist(err) {
@@ -71,11 +97,52 @@ func (a *app) Rename(w http.ResponseWriter, r *http.Request, request provider.Re
 		return
 	}
 
-	newItem, err := a.doRename(oldPath, newPath, oldItem)
+	if policy == conflictError {
+		if _, err := a.storage.Info(newPath); err == nil {
+			a.renderer.Error(w, request, provider.NewError(http.StatusBadRequest, errors.New("new name already exist")))
+			return
+		}
+	}
+
+	outcome, httpErr := a.resolveConflict(newPath, policy)
+	if httpErr != nil {
+		a.renderer.Error(w, request, httpErr)
+		return
+	}
+
+	if outcome.overwritten {
+		if httpErr := checkPermissionOn(request, newPath, provider.PermissionDelete); httpErr != nil {
+			a.renderer.Error(w, request, httpErr)
+			return
+		}
+
+		existing, err := a.storage.Info(newPath)
+		if err != nil {
+			a.renderer.Error(w, request, provider.NewError(http.StatusInternalServerError, err))
+			return
+		}
+
+		if err := a.replaceItem(existing, !oldItem.IsDir); err != nil {
+			a.renderer.Error(w, request, provider.NewError(http.StatusInternalServerError, err))
+			return
+		}
+	}
+
+	newItem, err := a.doRename(oldPath, outcome.pathname, oldItem)
 	if err != nil {
 		a.renderer.Error(w, request, provider.NewError(http.StatusInternalServerError, err))
 		return
 	}
 
-	http.Redirect(w, r, fmt.Sprintf("%s/?message=%s&messageLevel=success", request.GetURI(""), url.QueryEscape(fmt.Sprintf("%s successfully renamed to %s", oldItem.Name, newItem.Name))), http.StatusFound)
+	message := fmt.Sprintf("%s successfully renamed to %s%s", oldItem.Name, path.Base(newPath), outcome.describe(path.Base(newPath)))
+
+	if request.JSON {
+		item := a.newAPIItem(request, newItem)
+		item.Message = message
+
+		httpjson.ResponseJSON(w, http.StatusOK, item, false)
+		return
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("%s/?message=%s&messageLevel=success", request.GetURI(""), url.QueryEscape(message)), http.StatusFound)
 }
@@ -2,21 +2,32 @@ package crud
 
 import (
 	"crypto/rand"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
 	"path"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/ViBiOh/fibr/pkg/provider"
 	"github.com/ViBiOh/fibr/pkg/sha"
+	"github.com/ViBiOh/httputils/v3/pkg/httpjson"
+	"github.com/ViBiOh/httputils/v3/pkg/logger"
 	"golang.org/x/crypto/bcrypt"
 )
 
+const (
+	shareDateLayout     = "2006-01-02"
+	shareDateTimeLayout = "2006-01-02T15:04"
+)
+
 func uuid() (string, error) {
 	raw := make([]byte, 16)
-	_, _ = rand.Read(raw)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
 
 	raw[8] = raw[8]&^0xc0 | 0x80
 	raw[6] = raw[6]&^0xf0 | 0x40
@@ -24,20 +35,183 @@ func uuid() (string, error) {
 	return fmt.Sprintf("%x-%x-%x-%x-%x", raw[0:4], raw[4:6], raw[6:8], raw[8:10], raw[10:]), nil
 }
 
+func getFormBool(r *http.Request, name string) (bool, error) {
+	value := strings.TrimSpace(r.FormValue(name))
+	if len(value) == 0 {
+		return false, nil
+	}
+
+	result, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("unable to parse %s: %w", name, err)
+	}
+
+	return result, nil
+}
+
+// getSharePermission gives permission from form, or from edit and upload flags when none is given
+func getSharePermission(r *http.Request) (provider.Permission, error) {
+	if err := r.ParseForm(); err != nil {
+		return 0, err
+	}
+
+	if names := r.Form["permissions"]; len(names) != 0 {
+		permission, err := provider.ParsePermission(names)
+		if err != nil {
+			return 0, err
+		}
+
+		if permission == 0 {
+			return 0, errors.New("a share needs at least one permission")
+		}
+
+		return permission, nil
+	}
+
+	edit, err := getFormBool(r, "edit")
+	if err != nil {
+		return 0, err
+	}
+
+	upload, err := getFormBool(r, "upload")
+	if err != nil {
+		return 0, err
+	}
+
+	switch {
+	case upload && edit:
+		return 0, errors.New("an upload-only share can't have edit right")
+	case upload:
+		return provider.PermissionUpload, nil
+	case edit:
+		return provider.PermissionEdit, nil
+	default:
+		return provider.PermissionRead, nil
+	}
+}
+
+// isShareVisible checks if share can be managed by request: every share for admin, its own shares for user, shares inside its root for a share
+func isShareVisible(request provider.Request, share *provider.Share) bool {
+	if request.Share == nil {
+		return request.Admin || (len(request.User) != 0 && share.Owner == request.User)
+	}
+
+	if share.ID == request.Share.ID {
+		return false
+	}
+
+	root := strings.TrimSuffix(request.Share.Path, "/")
+	return share.Path == root || strings.HasPrefix(share.Path, root+"/")
+}
+
+// getShares gives shares visible by request
+func (a *app) getShares(request provider.Request) []*provider.Share {
+	a.metadataLock.Lock()
+	defer a.metadataLock.Unlock()
+
+	shares := make([]*provider.Share, 0)
+	for _, share := range a.metadatas {
+		if isShareVisible(request, share) {
+			snapshot := *share
+			shares = append(shares, &snapshot)
+		}
+	}
+
+	return shares
+}
+
+// getShareExpiration gives expiration from a duration or an absolute date, zero time if none is given
+func getShareExpiration(duration, date string, now time.Time) (time.Time, error) {
+	if duration = strings.TrimSpace(duration); len(duration) != 0 {
+		value, err := time.ParseDuration(duration)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("unable to parse share duration: %w", err)
+		}
+
+		if value <= 0 {
+			return time.Time{}, errors.New("share duration has to be positive")
+		}
+
+		return now.Add(value), nil
+	}
+
+	date = strings.TrimSpace(date)
+	if len(date) == 0 {
+		return time.Time{}, nil
+	}
+
+	expiration, err := time.Parse(time.RFC3339, date)
+	if err != nil {
+		if expiration, err = time.ParseInLocation(shareDateTimeLayout, date, now.Location()); err != nil {
+			if expiration, err = time.ParseInLocation(shareDateLayout, date, now.Location()); err != nil {
+				return time.Time{}, fmt.Errorf("unable to parse share expiration `%s`", date)
+			}
+
+			expiration = expiration.AddDate(0, 0, 1)
+		}
+	}
+
+	if !expiration.After(now) {
+		return time.Time{}, errors.New("share expiration is in the past")
+	}
+
+	return expiration, nil
+}
+
 // CreateShare create a share for given URL
 func (a *app) CreateShare(w http.ResponseWriter, r *http.Request, request provider.Request) {
-	if !request.CanShare {
+	if !request.CanShare() {
 		a.renderer.Error(w, request, provider.NewError(http.StatusForbidden, ErrNotAuthorized))
 		return
 	}
 
-	var err error
+	permission, err := getSharePermission(r)
+	if err != nil {
+		a.renderer.Error(w, request, provider.NewError(http.StatusBadRequest, err))
+		return
+	}
+
+	if !request.Permissions.Has(permission) {
+		a.renderer.Error(w, request, provider.NewError(http.StatusForbidden, errors.New("a share can't grant more permissions than yours")))
+		return
+	}
 
-	edit := false
-	if editValue := strings.TrimSpace(r.FormValue("edit")); editValue != "" {
-		edit, err = strconv.ParseBool(editValue)
+	if request.ACL.Restricts(request.GetFilepath(""), permission) {
+		a.renderer.Error(w, request, provider.NewError(http.StatusForbidden, ErrRestrictedContent))
+		return
+	}
+
+	// limits are counted per share, a share from a limited one would serve its content without limit
+	if request.Share != nil && request.Share.IsLimited() {
+		a.renderer.Error(w, request, provider.NewError(http.StatusForbidden, errors.New("a share with download limits can't be shared again")))
+		return
+	}
+
+	subfolder, err := getFormBool(r, "subfolder")
+	if err != nil {
+		a.renderer.Error(w, request, provider.NewError(http.StatusBadRequest, err))
+		return
+	}
+
+	if subfolder && !permission.UploadOnly() {
+		a.renderer.Error(w, request, provider.NewError(http.StatusBadRequest, errors.New("visitor subfolder is only available for upload-only share")))
+		return
+	}
+
+	var maxDownloads uint64
+	if maxDownloadsValue := strings.TrimSpace(r.FormValue("maxDownloads")); maxDownloadsValue != "" {
+		maxDownloads, err = strconv.ParseUint(maxDownloadsValue, 10, 32)
 		if err != nil {
-			a.renderer.Error(w, request, provider.NewError(http.StatusBadRequest, err))
+			a.renderer.Error(w, request, provider.NewError(http.StatusBadRequest, fmt.Errorf("unable to parse max downloads: %w", err)))
+			return
+		}
+	}
+
+	var maxBytes int64
+	if maxBytesValue := strings.TrimSpace(r.FormValue("maxBytes")); maxBytesValue != "" {
+		maxBytes, err = strconv.ParseInt(maxBytesValue, 10, 64)
+		if err != nil || maxBytes < 0 {
+			a.renderer.Error(w, request, provider.NewError(http.StatusBadRequest, fmt.Errorf("unable to parse max bytes `%s`", maxBytesValue)))
 			return
 		}
 	}
@@ -60,10 +234,22 @@ func (a *app) CreateShare(w http.ResponseWriter, r *http.Request, request provid
 	}
 	id := sha.Sha1(uuid)[:8]
 
+	expiration, err := getShareExpiration(r.FormValue("duration"), r.FormValue("expiration"), time.Now())
+	if err != nil {
+		a.renderer.Error(w, request, provider.NewError(http.StatusBadRequest, err))
+		return
+	}
+
+	if request.Share != nil && !request.Share.Expiration.IsZero() && (expiration.IsZero() || expiration.After(request.Share.Expiration)) {
+		expiration = request.Share.Expiration
+	}
+
+	sharePath := request.GetFilepath("")
+
 	a.metadataLock.Lock()
 	defer a.metadataLock.Unlock()
 
-	info, err := a.storage.Info(request.Path)
+	info, err := a.storage.Info(sharePath)
 	if err != nil {
 		if provider.IsNotExist(err) {
 			a.renderer.Error(w, request, provider.NewError(http.StatusNotFound, err))
@@ -73,26 +259,48 @@ func (a *app) CreateShare(w http.ResponseWriter, r *http.Request, request provid
 		return
 	}
 
-	a.metadatas = append(a.metadatas, &provider.Share{
-		ID:       id,
-		Path:     request.Path,
-		RootName: path.Base(request.Path),
-		Edit:     edit,
-		Password: password,
-		File:     !info.IsDir,
-	})
+	if !info.IsDir && !permission.Has(provider.PermissionDownload) {
+		a.renderer.Error(w, request, provider.NewError(http.StatusBadRequest, errors.New("a file share needs download permission")))
+		return
+	}
+
+	owner := request.User
+	if request.Share != nil {
+		owner = request.Share.Owner
+	}
+
+	share := provider.Share{
+		ID:          id,
+		Path:        sharePath,
+		RootName:    path.Base(sharePath),
+		Owner:       owner,
+		Permissions: permission,
+		Password:    password,
+		File:        !info.IsDir,
+		Subfolder:   subfolder,
+		Expiration:  expiration,
+
+		MaxDownloads: uint(maxDownloads),
+		MaxBytes:     maxBytes,
+	}
+	a.metadatas = append(a.metadatas, &share)
 
 	if err = a.saveMetadata(); err != nil {
 		a.renderer.Error(w, request, provider.NewError(http.StatusInternalServerError, err))
 		return
 	}
 
+	if request.JSON {
+		httpjson.ResponseJSON(w, http.StatusCreated, provider.NewAPIShare(share), false)
+		return
+	}
+
 	http.Redirect(w, r, fmt.Sprintf("%s/?message=%s&messageLevel=success#share-list", path.Dir(request.GetURI("")), url.QueryEscape(fmt.Sprintf("Share successfully created with ID: %s", id))), http.StatusFound)
 }
 
 // DeleteShare delete a share from given ID
 func (a *app) DeleteShare(w http.ResponseWriter, r *http.Request, request provider.Request) {
-	if !request.CanShare {
+	if !request.CanShare() {
 		a.renderer.Error(w, request, provider.NewError(http.StatusForbidden, ErrNotAuthorized))
 		return
 	}
@@ -102,17 +310,120 @@ func (a *app) DeleteShare(w http.ResponseWriter, r *http.Request, request provid
 	a.metadataLock.Lock()
 	defer a.metadataLock.Unlock()
 
+	var deleted *provider.Share
+
 	for i, metadata := range a.metadatas {
-		if metadata.ID == id {
+		if metadata.ID == id && isShareVisible(request, metadata) {
+			deleted = metadata
 			a.metadatas = append(a.metadatas[:i], a.metadatas[i+1:]...)
 			break
 		}
 	}
 
+	if deleted == nil {
+		a.renderer.Error(w, request, provider.NewError(http.StatusNotFound, fmt.Errorf("share with id %s not found", id)))
+		return
+	}
+
 	if err := a.saveMetadata(); err != nil {
 		a.renderer.Error(w, request, provider.NewError(http.StatusInternalServerError, err))
 		return
 	}
 
+	if request.JSON {
+		httpjson.ResponseJSON(w, http.StatusOK, provider.NewAPIShare(*deleted), false)
+		return
+	}
+
 	http.Redirect(w, r, fmt.Sprintf("%s/?message=%s&messageLevel=success#share-list", request.GetURI(""), url.QueryEscape(fmt.Sprintf("Share with id %s successfully deleted", id))), http.StatusFound)
 }
+
+// purgeShares removes expired shares from metadata
+func (a *app) purgeShares() {
+	if !a.metadataEnabled {
+		return
+	}
+
+	a.metadataLock.Lock()
+	defer a.metadataLock.Unlock()
+
+	now := time.Now()
+	shares := make([]*provider.Share, 0, len(a.metadatas))
+
+	for _, share := range a.metadatas {
+		if share.IsExpired(now) {
+			logger.Info("Removing expired share %s of %s", share.ID, share.Path)
+			continue
+		}
+
+		shares = append(shares, share)
+	}
+
+	if len(shares) == len(a.metadatas) {
+		return
+	}
+
+	a.metadatas = shares
+	if err := a.saveMetadata(); err != nil {
+		logger.Error("unable to save metadata after purging shares: %s", err)
+	}
+}
+
+// isInside checks if pathname is root or inside it
+func isInside(pathname, root string) bool {
+	return pathname == root || strings.HasPrefix(pathname, strings.TrimSuffix(root, "/")+"/")
+}
+
+// removeShares removes shares of given path and its content
+func (a *app) removeShares(pathname string) error {
+	if !a.metadataEnabled {
+		return nil
+	}
+
+	a.metadataLock.Lock()
+	defer a.metadataLock.Unlock()
+
+	shares := make([]*provider.Share, 0, len(a.metadatas))
+	for _, share := range a.metadatas {
+		if !isInside(share.Path, pathname) {
+			shares = append(shares, share)
+		}
+	}
+
+	if len(shares) == len(a.metadatas) {
+		return nil
+	}
+
+	a.metadatas = shares
+	return a.saveMetadata()
+}
+
+// moveShares keeps shares of given path and its content along a renamed item
+func (a *app) moveShares(oldPath, newPath string) error {
+	if !a.metadataEnabled {
+		return nil
+	}
+
+	a.metadataLock.Lock()
+	defer a.metadataLock.Unlock()
+
+	moved := false
+	for index, stored := range a.metadatas {
+		if !isInside(stored.Path, oldPath) {
+			continue
+		}
+
+		share := *stored
+		share.Path = newPath + strings.TrimPrefix(share.Path, oldPath)
+		share.RootName = path.Base(share.Path)
+
+		a.metadatas[index] = &share
+		moved = true
+	}
+
+	if !moved {
+		return nil
+	}
+
+	return a.saveMetadata()
+}
@@ -0,0 +1,378 @@
+package crud
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ViBiOh/fibr/pkg/provider"
+)
+
+func TestGetShareExpiration(t *testing.T) {
+	now := time.Date(2020, 6, 15, 12, 0, 0, 0, time.UTC)
+
+	var cases = []struct {
+		intention string
+		duration  string
+		date      string
+		want      time.Time
+		wantErr   error
+	}{
+		{
+			"none",
+			"",
+			"",
+			time.Time{},
+			nil,
+		},
+		{
+			"duration",
+			"48h",
+			"2020-12-31",
+			now.Add(48 * time.Hour),
+			nil,
+		},
+		{
+			"negative duration",
+			"-1h",
+			"",
+			time.Time{},
+			errors.New("share duration has to be positive"),
+		},
+		{
+			"invalid duration",
+			"1 week",
+			"",
+			time.Time{},
+			errors.New("unable to parse share duration"),
+		},
+		{
+			"date",
+			"",
+			"2020-06-20",
+			time.Date(2020, 6, 21, 0, 0, 0, 0, time.UTC),
+			nil,
+		},
+		{
+			"datetime",
+			"",
+			"2020-06-20T08:30",
+			time.Date(2020, 6, 20, 8, 30, 0, 0, time.UTC),
+			nil,
+		},
+		{
+			"rfc3339",
+			"",
+			"2020-06-20T08:30:00+02:00",
+			time.Date(2020, 6, 20, 6, 30, 0, 0, time.UTC),
+			nil,
+		},
+		{
+			"past",
+			"",
+			"2020-06-01",
+			time.Time{},
+			errors.New("share expiration is in the past"),
+		},
+		{
+			"invalid date",
+			"",
+			"tomorrow",
+			time.Time{},
+			errors.New("unable to parse share expiration"),
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.intention, func(t *testing.T) {
+			result, err := getShareExpiration(testCase.duration, testCase.date, now)
+
+			failed := false
+
+			if testCase.wantErr == nil && err != nil {
+				failed = true
+			} else if testCase.wantErr != nil && err == nil {
+				failed = true
+			} else if testCase.wantErr != nil && !strings.Contains(err.Error(), testCase.wantErr.Error()) {
+				failed = true
+			} else if !result.Equal(testCase.want) {
+				failed = true
+			}
+
+			if failed {
+				t.Errorf("getShareExpiration() = (%s, `%v`), want (%s, `%v`)", result, err, testCase.want, testCase.wantErr)
+			}
+		})
+	}
+}
+
+func TestPurgeShares(t *testing.T) {
+	crudApp, storage, renderer := newTestApp(t)
+
+	for _, name := range []string{"/old", "/new"} {
+		if err := storage.CreateDir(name); err != nil {
+			t.Fatalf("CreateDir() = %s", err)
+		}
+
+		crudApp.CreateShare(httptest.NewRecorder(), newFormRequest(name+"/", url.Values{"duration": {"1h"}}), provider.Request{Path: name, Permissions: provider.PermissionAll})
+		if renderer.err != nil {
+			t.Fatalf("CreateShare() = `%v`", renderer.err)
+		}
+	}
+
+	crudApp.metadatas[0].Expiration = time.Now().Add(-time.Minute)
+
+	crudApp.purgeShares()
+
+	if len(crudApp.metadatas) != 1 || crudApp.metadatas[0].Path != "/new" {
+		t.Errorf("purgeShares() = %+v, want only active share kept", crudApp.metadatas)
+	}
+
+	if err := crudApp.loadMetadata(); err != nil || len(crudApp.metadatas) != 1 {
+		t.Errorf("loadMetadata() = (%+v, `%v`), want purge persisted", crudApp.metadatas, err)
+	}
+}
+
+func TestSharesFollowItem(t *testing.T) {
+	crudApp, storage, renderer := newTestApp(t)
+
+	for _, name := range []string{"/docs/reports", "/documents"} {
+		if err := storage.CreateDir(name); err != nil {
+			t.Fatalf("CreateDir() = %s", err)
+		}
+
+		crudApp.CreateShare(httptest.NewRecorder(), newFormRequest(name+"/", nil), provider.Request{Path: name, Permissions: provider.PermissionAll})
+		if renderer.err != nil {
+			t.Fatalf("CreateShare() = `%v`", renderer.err)
+		}
+	}
+
+	docs, err := storage.Info("/docs")
+	if err != nil {
+		t.Fatalf("Info() = %s", err)
+	}
+
+	if _, err := crudApp.RenameItem(docs, "/archive"); err != nil {
+		t.Fatalf("RenameItem() = %s", err)
+	}
+
+	if share := crudApp.metadatas[0]; share.Path != "/archive/reports" || share.RootName != "reports" {
+		t.Errorf("RenameItem() = %+v, want share moved to /archive/reports", share)
+	}
+
+	if share := crudApp.metadatas[1]; share.Path != "/documents" {
+		t.Errorf("RenameItem() = %+v, want sibling share untouched", share)
+	}
+
+	archive, err := storage.Info("/archive")
+	if err != nil {
+		t.Fatalf("Info() = %s", err)
+	}
+
+	if err := crudApp.RemoveItem(archive); err != nil {
+		t.Fatalf("RemoveItem() = %s", err)
+	}
+
+	if len(crudApp.metadatas) != 1 || crudApp.metadatas[0].Path != "/documents" {
+		t.Errorf("RemoveItem() = %+v, want only /documents share kept", crudApp.metadatas)
+	}
+}
+
+func TestReshare(t *testing.T) {
+	crudApp, storage, renderer := newTestApp(t)
+
+	for _, name := range []string{"/docs/reports", "/private"} {
+		if err := storage.CreateDir(name); err != nil {
+			t.Fatalf("CreateDir() = %s", err)
+		}
+	}
+
+	for _, name := range []string{"/docs", "/private"} {
+		crudApp.CreateShare(httptest.NewRecorder(), newFormRequest(name+"/", url.Values{"permissions": {"list", "download", "share"}}), provider.Request{Path: name, Permissions: provider.PermissionAll})
+		if renderer.err != nil {
+			t.Fatalf("CreateShare() = `%v`", renderer.err)
+		}
+	}
+
+	share := crudApp.metadatas[0]
+	request := provider.Request{Path: "/reports", Permissions: share.Permissions, Share: share}
+
+	writer := httptest.NewRecorder()
+	crudApp.CreateShare(writer, newFormRequest("/reports/", url.Values{"permissions": {"list", "delete"}}), request)
+
+	if writer.Code != http.StatusForbidden {
+		t.Errorf("CreateShare() = %d, want forbidden escalation", writer.Code)
+	}
+
+	renderer.err = nil
+	crudApp.CreateShare(httptest.NewRecorder(), newFormRequest("/reports/", url.Values{"permissions": {"list"}}), request)
+	if renderer.err != nil {
+		t.Fatalf("CreateShare() = `%v`", renderer.err)
+	}
+
+	if reshare := crudApp.metadatas[2]; reshare.Path != "/docs/reports" || reshare.Permissions != provider.PermissionList {
+		t.Errorf("CreateShare() = %+v, want list share of /docs/reports", reshare)
+	}
+
+	if shares := crudApp.getShares(request); len(shares) != 1 || shares[0].ID != crudApp.metadatas[2].ID {
+		t.Errorf("getShares() = %+v, want only shares inside share", shares)
+	}
+
+	writer = httptest.NewRecorder()
+	crudApp.DeleteShare(writer, newFormRequest("/", url.Values{"id": {crudApp.metadatas[1].ID}}), request)
+
+	if writer.Code != http.StatusNotFound || len(crudApp.metadatas) != 3 {
+		t.Errorf("DeleteShare() = %d, want share outside of share not found", writer.Code)
+	}
+}
+
+func TestReshareLimited(t *testing.T) {
+	crudApp, storage, renderer := newTestApp(t)
+
+	if err := storage.CreateDir("/docs"); err != nil {
+		t.Fatalf("CreateDir() = %s", err)
+	}
+
+	crudApp.CreateShare(httptest.NewRecorder(), newFormRequest("/docs/", url.Values{"permissions": {"list", "download", "share"}, "maxDownloads": {"5"}}), provider.Request{Path: "/docs", Permissions: provider.PermissionAll})
+	if renderer.err != nil {
+		t.Fatalf("CreateShare() = `%v`", renderer.err)
+	}
+
+	share := crudApp.metadatas[0]
+
+	writer := httptest.NewRecorder()
+	crudApp.CreateShare(writer, newFormRequest("/", url.Values{"permissions": {"list", "download"}}), provider.Request{Path: "/", Permissions: share.Permissions, Share: share})
+
+	if writer.Code != http.StatusForbidden || len(crudApp.metadatas) != 1 {
+		t.Errorf("CreateShare() = %d, want forbidden share from a limited share", writer.Code)
+	}
+}
+
+func TestSharePermissions(t *testing.T) {
+	crudApp, storage, _ := newTestApp(t)
+
+	if err := storage.CreateDir("/docs"); err != nil {
+		t.Fatalf("CreateDir() = %s", err)
+	}
+
+	if err := storage.Store("/docs/report.txt", ioutil.NopCloser(strings.NewReader("content"))); err != nil {
+		t.Fatalf("Store() = %s", err)
+	}
+
+	var cases = []struct {
+		intention  string
+		permission provider.Permission
+		request    *http.Request
+		want       int
+	}{
+		{
+			"list",
+			provider.PermissionDownload,
+			httptest.NewRequest(http.MethodGet, "/", nil),
+			http.StatusForbidden,
+		},
+		{
+			"download",
+			provider.PermissionList,
+			httptest.NewRequest(http.MethodGet, "/report.txt", nil),
+			http.StatusForbidden,
+		},
+		{
+			"zip",
+			provider.PermissionList,
+			httptest.NewRequest(http.MethodGet, "/?download", nil),
+			http.StatusForbidden,
+		},
+		{
+			"delete",
+			provider.PermissionRead | provider.PermissionRename,
+			newFormRequest("/", url.Values{"method": {http.MethodDelete}, "name": {"report.txt"}}),
+			http.StatusForbidden,
+		},
+		{
+			"rename",
+			provider.PermissionRead | provider.PermissionDelete,
+			newFormRequest("/", url.Values{"method": {http.MethodPatch}, "name": {"report.txt"}, "newName": {"summary.txt"}}),
+			http.StatusForbidden,
+		},
+		{
+			"create",
+			provider.PermissionEdit &^ provider.PermissionCreate,
+			newFormRequest("/", url.Values{"method": {http.MethodPut}, "name": {"archives"}}),
+			http.StatusForbidden,
+		},
+		{
+			"allowed",
+			provider.PermissionDownload,
+			httptest.NewRequest(http.MethodGet, "/report.txt", nil),
+			http.StatusOK,
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.intention, func(t *testing.T) {
+			request := provider.Request{
+				Path:        "/",
+				Permissions: testCase.permission,
+				Share:       &provider.Share{ID: "abcdef", Path: "/docs", Permissions: testCase.permission},
+			}
+
+			writer := httptest.NewRecorder()
+			if testCase.request.Method == http.MethodGet {
+				request.Path = testCase.request.URL.Path
+				crudApp.Get(writer, testCase.request, request)
+			} else {
+				crudApp.Post(writer, testCase.request, request)
+			}
+
+			if writer.Code != testCase.want {
+				t.Errorf("%s = %d, want %d", testCase.intention, writer.Code, testCase.want)
+			}
+		})
+	}
+}
+
+func TestShareOwnership(t *testing.T) {
+	crudApp, storage, renderer := newTestApp(t)
+
+	for _, name := range []string{"/home/alice/photos", "/home/bob/photos"} {
+		if err := storage.CreateDir(name); err != nil {
+			t.Fatalf("CreateDir() = %s", err)
+		}
+	}
+
+	alice := provider.Request{Path: "/photos", Permissions: provider.PermissionAll, User: "alice", Home: "/home/alice"}
+	bob := provider.Request{Path: "/photos", Permissions: provider.PermissionAll, User: "bob", Home: "/home/bob"}
+	admin := provider.Request{Path: "/", Permissions: provider.PermissionAll, User: "admin", Admin: true}
+
+	for _, request := range []provider.Request{alice, bob} {
+		crudApp.CreateShare(httptest.NewRecorder(), newFormRequest("/photos/", url.Values{}), request)
+		if renderer.err != nil {
+			t.Fatalf("CreateShare() = `%v`", renderer.err)
+		}
+	}
+
+	if share := crudApp.metadatas[0]; share.Path != "/home/alice/photos" || share.Owner != "alice" {
+		t.Errorf("CreateShare() = %+v, want share of alice's home", share)
+	}
+
+	if shares := crudApp.getShares(alice); len(shares) != 1 || shares[0].Owner != "alice" {
+		t.Errorf("getShares() = %+v, want only alice's share", shares)
+	}
+
+	if shares := crudApp.getShares(admin); len(shares) != 2 {
+		t.Errorf("getShares() = %+v, want every share for admin", shares)
+	}
+
+	writer := httptest.NewRecorder()
+	crudApp.DeleteShare(writer, newFormRequest("/", url.Values{"id": {crudApp.metadatas[0].ID}}), bob)
+
+	if writer.Code != http.StatusNotFound || len(crudApp.metadatas) != 2 {
+		t.Errorf("DeleteShare() = %d, want alice's share not found for bob", writer.Code)
+	}
+}
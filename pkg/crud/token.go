@@ -0,0 +1,292 @@
+package crud
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/ViBiOh/fibr/pkg/provider"
+	"github.com/ViBiOh/fibr/pkg/sha"
+	"github.com/ViBiOh/httputils/v3/pkg/httpjson"
+	"github.com/ViBiOh/httputils/v3/pkg/logger"
+)
+
+var (
+	tokensFilename = path.Join(provider.MetadataDirectoryName, "tokens.json")
+
+	// ErrTokenNotFound occurs when a token is unknown
+	ErrTokenNotFound = errors.New("token not found")
+)
+
+// canManageTokens checks if request comes from admin, tokens giving access to the whole storage
+func canManageTokens(request provider.Request) bool {
+	return request.Admin
+}
+
+func (a *app) loadTokens() error {
+	a.tokens = make([]provider.Token, 0)
+
+	file, err := a.storage.ReaderFrom(tokensFilename)
+	if err != nil {
+		if provider.IsNotExist(err) {
+			return nil
+		}
+
+		return err
+	}
+
+	defer func() {
+		if closeErr := file.Close(); closeErr != nil {
+			logger.Error("unable to close tokens: %s", closeErr)
+		}
+	}()
+
+	rawTokens, err := ioutil.ReadAll(file)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(rawTokens, &a.tokens)
+}
+
+func (a *app) saveTokens() error {
+	content, err := json.MarshalIndent(a.tokens, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return a.storage.Store(tokensFilename, ioutil.NopCloser(bytes.NewReader(content)))
+}
+
+// getTokens gives API representation of tokens, for rendering
+func (a *app) getTokens() []provider.APIToken {
+	a.tokensLock.Lock()
+	defer a.tokensLock.Unlock()
+
+	tokens := make([]provider.APIToken, len(a.tokens))
+	for index, token := range a.tokens {
+		tokens[index] = provider.NewAPIToken(token)
+	}
+
+	return tokens
+}
+
+// GetToken returns token matching given secret, if not expired
+func (a *app) GetToken(secret string) *provider.Token {
+	if !strings.HasPrefix(secret, provider.TokenPrefix) {
+		return nil
+	}
+
+	hash := []byte(provider.HashToken(secret))
+	now := time.Now()
+
+	a.tokensLock.Lock()
+	defer a.tokensLock.Unlock()
+
+	for _, token := range a.tokens {
+		if subtle.ConstantTimeCompare(hash, []byte(token.Hash)) == 1 && !token.IsExpired(now) {
+			output := token
+			return &output
+		}
+	}
+
+	return nil
+}
+
+func (a *app) addToken(token provider.Token) error {
+	a.tokensLock.Lock()
+	defer a.tokensLock.Unlock()
+
+	a.tokens = append(a.tokens, token)
+
+	if err := a.saveTokens(); err != nil {
+		a.tokens = a.tokens[:len(a.tokens)-1]
+		return err
+	}
+
+	return nil
+}
+
+func generateTokenSecret() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+
+	return provider.TokenPrefix + hex.EncodeToString(raw), nil
+}
+
+// CreateToken creates an API token, its secret being only given in response
+func (a *app) CreateToken(w http.ResponseWriter, r *http.Request, request provider.Request) {
+	if !canManageTokens(request) {
+		a.renderer.Error(w, request, provider.NewError(http.StatusForbidden, ErrNotAuthorized))
+		return
+	}
+
+	if !a.metadataEnabled {
+		a.renderer.Error(w, request, provider.NewError(http.StatusBadRequest, errors.New("tokens need metadata to be enabled")))
+		return
+	}
+
+	name := strings.TrimSpace(r.FormValue("name"))
+	if len(name) == 0 {
+		a.renderer.Error(w, request, provider.NewError(http.StatusBadRequest, ErrEmptyName))
+		return
+	}
+
+	permission, err := provider.ParsePermission(r.Form["permissions"])
+	if err != nil {
+		a.renderer.Error(w, request, provider.NewError(http.StatusBadRequest, err))
+		return
+	}
+
+	if permission == 0 {
+		a.renderer.Error(w, request, provider.NewError(http.StatusBadRequest, errors.New("a token needs at least one permission")))
+		return
+	}
+
+	tokenPath := request.GetFilepath("")
+	if pathValue := strings.TrimSpace(r.FormValue("path")); len(pathValue) != 0 {
+		tokenPath = path.Join("/", pathValue)
+	}
+
+	if _, err := a.storage.Info(tokenPath); err != nil {
+		if provider.IsNotExist(err) {
+			a.renderer.Error(w, request, provider.NewError(http.StatusNotFound, err))
+		} else {
+			a.renderer.Error(w, request, provider.NewError(http.StatusInternalServerError, err))
+		}
+		return
+	}
+
+	expiration, err := getShareExpiration(r.FormValue("duration"), r.FormValue("expiration"), time.Now())
+	if err != nil {
+		a.renderer.Error(w, request, provider.NewError(http.StatusBadRequest, err))
+		return
+	}
+
+	uuid, err := uuid()
+	if err != nil {
+		a.renderer.Error(w, request, provider.NewError(http.StatusInternalServerError, err))
+		return
+	}
+
+	secret, err := generateTokenSecret()
+	if err != nil {
+		a.renderer.Error(w, request, provider.NewError(http.StatusInternalServerError, err))
+		return
+	}
+
+	token := provider.Token{
+		ID:          sha.Sha1(uuid)[:8],
+		Name:        name,
+		Hash:        provider.HashToken(secret),
+		Owner:       request.User,
+		Path:        tokenPath,
+		Permissions: permission,
+		Created:     time.Now(),
+		Expiration:  expiration,
+	}
+
+	if err := a.addToken(token); err != nil {
+		a.renderer.Error(w, request, provider.NewError(http.StatusInternalServerError, err))
+		return
+	}
+
+	apiToken := provider.NewAPIToken(token)
+	apiToken.Secret = secret
+
+	if request.JSON {
+		httpjson.ResponseJSON(w, http.StatusCreated, apiToken, false)
+		return
+	}
+
+	a.List(w, request, &provider.Message{
+		Level:   "success",
+		Content: fmt.Sprintf("Token %s successfully created, copy it now as it won't be shown again: %s", name, secret),
+	})
+}
+
+// DeleteToken revokes a token from given ID
+func (a *app) DeleteToken(w http.ResponseWriter, r *http.Request, request provider.Request) {
+	if !canManageTokens(request) {
+		a.renderer.Error(w, request, provider.NewError(http.StatusForbidden, ErrNotAuthorized))
+		return
+	}
+
+	id := r.FormValue("id")
+
+	a.tokensLock.Lock()
+	defer a.tokensLock.Unlock()
+
+	index := -1
+	for i, token := range a.tokens {
+		if token.ID == id {
+			index = i
+			break
+		}
+	}
+
+	if index == -1 {
+		a.renderer.Error(w, request, provider.NewError(http.StatusNotFound, ErrTokenNotFound))
+		return
+	}
+
+	deleted := a.tokens[index]
+	previous := a.tokens
+	a.tokens = append(append([]provider.Token{}, previous[:index]...), previous[index+1:]...)
+
+	if err := a.saveTokens(); err != nil {
+		a.tokens = previous
+		a.renderer.Error(w, request, provider.NewError(http.StatusInternalServerError, err))
+		return
+	}
+
+	if request.JSON {
+		httpjson.ResponseJSON(w, http.StatusOK, provider.NewAPIToken(deleted), false)
+		return
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("%s/?message=%s&messageLevel=success#token-list", request.GetURI(""), url.QueryEscape(fmt.Sprintf("Token %s successfully revoked", deleted.Name))), http.StatusFound)
+}
+
+// purgeTokens removes expired tokens
+func (a *app) purgeTokens() {
+	if !a.metadataEnabled {
+		return
+	}
+
+	a.tokensLock.Lock()
+	defer a.tokensLock.Unlock()
+
+	now := time.Now()
+	tokens := make([]provider.Token, 0, len(a.tokens))
+
+	for _, token := range a.tokens {
+		if token.IsExpired(now) {
+			logger.Info("Removing expired token %s", token.Name)
+			continue
+		}
+
+		tokens = append(tokens, token)
+	}
+
+	if len(tokens) == len(a.tokens) {
+		return
+	}
+
+	a.tokens = tokens
+	if err := a.saveTokens(); err != nil {
+		logger.Error("unable to save tokens after purge: %s", err)
+	}
+}
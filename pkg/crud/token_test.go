@@ -0,0 +1,174 @@
+package crud
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ViBiOh/fibr/pkg/provider"
+)
+
+func createToken(t *testing.T, crudApp *app, values url.Values) provider.APIToken {
+	writer := httptest.NewRecorder()
+	crudApp.Post(writer, newFormRequest("/", values), provider.Request{Path: "/", Permissions: provider.PermissionAll, User: "admin", Admin: true, JSON: true})
+
+	if writer.Code != http.StatusCreated {
+		t.Fatalf("CreateToken() = %d, `%s`", writer.Code, writer.Body.String())
+	}
+
+	var token provider.APIToken
+	if err := json.Unmarshal(writer.Body.Bytes(), &token); err != nil {
+		t.Fatalf("Unmarshal() = %s", err)
+	}
+
+	return token
+}
+
+func TestCreateToken(t *testing.T) {
+	crudApp, storage, _ := newTestApp(t)
+
+	if err := storage.CreateDir("/builds"); err != nil {
+		t.Fatalf("CreateDir() = %s", err)
+	}
+
+	token := createToken(t, crudApp, url.Values{"type": {"token"}, "method": {http.MethodPost}, "name": {"ci"}, "path": {"builds"}, "permissions": {"list", "upload"}})
+
+	if !strings.HasPrefix(token.Secret, provider.TokenPrefix) || token.Path != "/builds" || token.Owner != "admin" {
+		t.Fatalf("CreateToken() = %+v, want secret for /builds", token)
+	}
+
+	if strings.Contains(crudApp.tokens[0].Hash, token.Secret) {
+		t.Errorf("CreateToken() = %+v, want secret stored hashed", crudApp.tokens[0])
+	}
+
+	found := crudApp.GetToken(token.Secret)
+	if found == nil || found.ID != token.ID || found.Permissions != provider.PermissionList|provider.PermissionUpload {
+		t.Errorf("GetToken() = %+v, want created token", found)
+	}
+
+	if found := crudApp.GetToken(token.Secret + "0"); found != nil {
+		t.Errorf("GetToken() = %+v, want nil for wrong secret", found)
+	}
+
+	if err := crudApp.loadTokens(); err != nil || crudApp.GetToken(token.Secret) == nil {
+		t.Errorf("loadTokens() = `%v`, want token persisted", err)
+	}
+}
+
+func TestCreateTokenInvalid(t *testing.T) {
+	var cases = []struct {
+		intention string
+		request   provider.Request
+		values    url.Values
+		want      int
+	}{
+		{
+			"not admin",
+			provider.Request{Path: "/", Permissions: provider.PermissionAll},
+			url.Values{"name": {"ci"}, "permissions": {"list"}},
+			http.StatusForbidden,
+		},
+		{
+			"no name",
+			provider.Request{Path: "/", Permissions: provider.PermissionAll, Admin: true},
+			url.Values{"permissions": {"list"}},
+			http.StatusBadRequest,
+		},
+		{
+			"no permission",
+			provider.Request{Path: "/", Permissions: provider.PermissionAll, Admin: true},
+			url.Values{"name": {"ci"}, "permissions": {""}},
+			http.StatusBadRequest,
+		},
+		{
+			"unknown path",
+			provider.Request{Path: "/", Permissions: provider.PermissionAll, Admin: true},
+			url.Values{"name": {"ci"}, "permissions": {"list"}, "path": {"/unknown"}},
+			http.StatusNotFound,
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.intention, func(t *testing.T) {
+			crudApp, _, _ := newTestApp(t)
+
+			testCase.values.Set("type", "token")
+			testCase.values.Set("method", http.MethodPost)
+
+			writer := httptest.NewRecorder()
+			crudApp.Post(writer, newFormRequest("/", testCase.values), testCase.request)
+
+			if writer.Code != testCase.want || len(crudApp.tokens) != 0 {
+				t.Errorf("CreateToken() = (%d, %+v), want %d", writer.Code, crudApp.tokens, testCase.want)
+			}
+		})
+	}
+}
+
+func TestDeleteToken(t *testing.T) {
+	crudApp, _, _ := newTestApp(t)
+
+	token := createToken(t, crudApp, url.Values{"type": {"token"}, "method": {http.MethodPost}, "name": {"ci"}, "permissions": {"list"}})
+
+	writer := httptest.NewRecorder()
+	crudApp.Post(writer, newFormRequest("/", url.Values{"type": {"token"}, "method": {http.MethodDelete}, "id": {token.ID}}), provider.Request{Path: "/", Permissions: provider.PermissionAll, Admin: true})
+
+	if writer.Code != http.StatusFound {
+		t.Errorf("DeleteToken() = %d, want %d", writer.Code, http.StatusFound)
+	}
+
+	if found := crudApp.GetToken(token.Secret); found != nil {
+		t.Errorf("GetToken() = %+v, want revoked token", found)
+	}
+}
+
+type failingStoreStorage struct {
+	provider.Storage
+}
+
+func (failingStoreStorage) Store(string, io.ReadCloser) error {
+	return errors.New("storage unavailable")
+}
+
+func TestDeleteTokenSaveFailure(t *testing.T) {
+	crudApp, storage, _ := newTestApp(t)
+
+	first := createToken(t, crudApp, url.Values{"type": {"token"}, "method": {http.MethodPost}, "name": {"ci"}, "permissions": {"list"}})
+	second := createToken(t, crudApp, url.Values{"type": {"token"}, "method": {http.MethodPost}, "name": {"backup"}, "permissions": {"list"}})
+
+	crudApp.storage = failingStoreStorage{storage}
+
+	writer := httptest.NewRecorder()
+	crudApp.Post(writer, newFormRequest("/", url.Values{"type": {"token"}, "method": {http.MethodDelete}, "id": {first.ID}}), provider.Request{Path: "/", Permissions: provider.PermissionAll, Admin: true})
+
+	if writer.Code != http.StatusInternalServerError {
+		t.Errorf("DeleteToken() = %d, want %d", writer.Code, http.StatusInternalServerError)
+	}
+
+	if crudApp.GetToken(first.Secret) == nil || crudApp.GetToken(second.Secret) == nil {
+		t.Errorf("DeleteToken() = %+v, want tokens kept when deletion can't be saved", crudApp.tokens)
+	}
+}
+
+func TestExpiredToken(t *testing.T) {
+	crudApp, _, _ := newTestApp(t)
+
+	token := createToken(t, crudApp, url.Values{"type": {"token"}, "method": {http.MethodPost}, "name": {"ci"}, "permissions": {"list"}, "duration": {"1h"}})
+	crudApp.tokens[0].Expiration = time.Now().Add(-time.Minute)
+
+	if found := crudApp.GetToken(token.Secret); found != nil {
+		t.Errorf("GetToken() = %+v, want nil for expired token", found)
+	}
+
+	crudApp.purgeTokens()
+
+	if len(crudApp.tokens) != 0 {
+		t.Errorf("purgeTokens() = %+v, want expired token removed", crudApp.tokens)
+	}
+}
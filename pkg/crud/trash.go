@@ -0,0 +1,340 @@
+package crud
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"time"
+
+	"github.com/ViBiOh/fibr/pkg/provider"
+	"github.com/ViBiOh/fibr/pkg/sha"
+	"github.com/ViBiOh/fibr/pkg/thumbnail"
+	"github.com/ViBiOh/httputils/v3/pkg/httpjson"
+	"github.com/ViBiOh/httputils/v3/pkg/logger"
+)
+
+var (
+	trashDirname  = path.Join(provider.MetadataDirectoryName, "trash")
+	trashFilename = path.Join(trashDirname, ".json")
+
+	// ErrTrashNotFound occurs when a trash item is unknown
+	ErrTrashNotFound = errors.New("trash item not found")
+)
+
+// canManageTrash checks if request comes from admin, trash holding items of every users and shares
+func canManageTrash(request provider.Request) bool {
+	return request.Admin
+}
+
+func (a *app) trashEnabled() bool {
+	return a.metadataEnabled && a.trashRetention > 0
+}
+
+func getTrashPath(item provider.TrashItem) string {
+	return path.Join(trashDirname, item.ID, item.Name)
+}
+
+func getTrashVersionsDir(item provider.TrashItem) string {
+	return path.Join(trashDirname, "versions", item.ID)
+}
+
+func (a *app) loadTrash() error {
+	a.trash = make([]provider.TrashItem, 0)
+
+	file, err := a.storage.ReaderFrom(trashFilename)
+	if err != nil {
+		if provider.IsNotExist(err) {
+			return nil
+		}
+
+		return err
+	}
+
+	defer func() {
+		if closeErr := file.Close(); closeErr != nil {
+			logger.Error("unable to close trash index: %s", closeErr)
+		}
+	}()
+
+	rawTrash, err := ioutil.ReadAll(file)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(rawTrash, &a.trash)
+}
+
+func (a *app) saveTrash() error {
+	content, err := json.MarshalIndent(a.trash, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := a.storage.CreateDir(trashDirname); err != nil {
+		return err
+	}
+
+	return a.storage.Store(trashFilename, ioutil.NopCloser(bytes.NewReader(content)))
+}
+
+// getTrash gives a copy of trash items, for rendering
+func (a *app) getTrash() []provider.TrashItem {
+	a.trashLock.Lock()
+	defer a.trashLock.Unlock()
+
+	return append(make([]provider.TrashItem, 0, len(a.trash)), a.trash...)
+}
+
+func (a *app) findTrash(id string) int {
+	for index, item := range a.trash {
+		if item.ID == id {
+			return index
+		}
+	}
+
+	return -1
+}
+
+// getTrashItem gives trash item of given ID
+func (a *app) getTrashItem(id string) (provider.TrashItem, bool) {
+	a.trashLock.Lock()
+	defer a.trashLock.Unlock()
+
+	index := a.findTrash(id)
+	if index == -1 {
+		return provider.TrashItem{}, false
+	}
+
+	return a.trash[index], true
+}
+
+// forgetTrash removes trash item of given ID once its content has been restored
+func (a *app) forgetTrash(id string) error {
+	a.trashLock.Lock()
+	defer a.trashLock.Unlock()
+
+	index := a.findTrash(id)
+	if index == -1 {
+		return nil
+	}
+
+	return a.removeTrash(index)
+}
+
+// moveToTrash moves given item into trash, recording its original path, along its versions if asked
+func (a *app) moveToTrash(info provider.StorageItem, versions bool) error {
+	a.trashLock.Lock()
+	defer a.trashLock.Unlock()
+
+	return a.addTrash(info, versions)
+}
+
+// addTrash moves given item into trash, caller must hold the lock
+func (a *app) addTrash(info provider.StorageItem, versions bool) error {
+	uuid, err := uuid()
+	if err != nil {
+		return err
+	}
+
+	item := provider.TrashItem{
+		ID:    sha.Sha1(uuid)[:8],
+		Name:  info.Name,
+		Path:  info.Pathname,
+		IsDir: info.IsDir,
+		Date:  time.Now(),
+	}
+
+	if err := a.storage.CreateDir(path.Dir(getTrashPath(item))); err != nil {
+		return err
+	}
+
+	if err := a.storage.Rename(info.Pathname, getTrashPath(item)); err != nil {
+		return err
+	}
+
+	a.trash = append(a.trash, item)
+
+	if err := a.saveTrash(); err != nil {
+		a.trash = a.trash[:len(a.trash)-1]
+
+		if renameErr := a.storage.Rename(getTrashPath(item), info.Pathname); renameErr != nil {
+			return fmt.Errorf("%s: %w", err, renameErr)
+		}
+
+		return err
+	}
+
+	if versions {
+		if _, err := a.moveVersionsDir(getVersionsDir(info.Pathname), getTrashVersionsDir(item)); err != nil {
+			logger.Error("unable to move versions of %s to trash: %s", info.Pathname, err)
+		}
+	}
+
+	return nil
+}
+
+// removeTrash deletes content of trash item at given index, caller must hold the lock
+func (a *app) removeTrash(index int) error {
+	item := a.trash[index]
+
+	if err := a.storage.Remove(path.Join(trashDirname, item.ID)); err != nil && !provider.IsNotExist(err) {
+		return err
+	}
+
+	if err := a.storage.Remove(getTrashVersionsDir(item)); err != nil && !provider.IsNotExist(err) {
+		return err
+	}
+
+	a.trash = append(a.trash[:index], a.trash[index+1:]...)
+
+	return a.saveTrash()
+}
+
+func (a *app) generateThumbnails(pathname string) {
+	err := a.storage.Walk(pathname, func(item provider.StorageItem, _ error) error {
+		if thumbnail.CanHaveThumbnail(item) {
+			a.thumbnail.GenerateThumbnail(item)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		logger.Error("unable to generate thumbnails of %s: %s", pathname, err)
+	}
+}
+
+// RestoreTrash moves an item from trash to its original path
+func (a *app) RestoreTrash(w http.ResponseWriter, r *http.Request, request provider.Request) {
+	if !canManageTrash(request) {
+		a.renderer.Error(w, request, provider.NewError(http.StatusForbidden, ErrNotAuthorized))
+		return
+	}
+
+	policy, httpErr := a.getConflictPolicy(getConflictValue(r, r.FormValue("conflict")))
+	if httpErr != nil {
+		a.renderer.Error(w, request, httpErr)
+		return
+	}
+
+	item, ok := a.getTrashItem(r.FormValue("id"))
+	if !ok {
+		a.renderer.Error(w, request, provider.NewError(http.StatusNotFound, ErrTrashNotFound))
+		return
+	}
+
+	outcome, httpErr := a.resolveConflict(item.Path, policy)
+	if httpErr != nil {
+		a.renderer.Error(w, request, httpErr)
+		return
+	}
+
+	if outcome.overwritten {
+		// Trash lock isn't held here, the replaced item may itself go to trash
+		if existing, err := a.storage.Info(outcome.pathname); err == nil {
+			if err := a.replaceItem(existing, !item.IsDir); err != nil {
+				a.renderer.Error(w, request, provider.NewError(http.StatusInternalServerError, err))
+				return
+			}
+		}
+	}
+
+	if err := a.storage.CreateDir(path.Dir(outcome.pathname)); err != nil {
+		a.renderer.Error(w, request, provider.NewError(http.StatusInternalServerError, err))
+		return
+	}
+
+	if err := a.storage.Rename(getTrashPath(item), outcome.pathname); err != nil {
+		a.renderer.Error(w, request, provider.NewError(http.StatusInternalServerError, err))
+		return
+	}
+
+	if merged, err := a.moveVersionsDir(getTrashVersionsDir(item), getVersionsDir(outcome.pathname)); err != nil {
+		logger.Error("unable to restore versions of %s: %s", item.Path, err)
+	} else if merged && !item.IsDir {
+		if err := a.pruneVersions(outcome.pathname); err != nil {
+			logger.Error("unable to prune versions of %s: %s", outcome.pathname, err)
+		}
+	}
+
+	if err := a.forgetTrash(item.ID); err != nil {
+		a.renderer.Error(w, request, provider.NewError(http.StatusInternalServerError, err))
+		return
+	}
+
+	info, err := a.storage.Info(outcome.pathname)
+	if err != nil {
+		a.renderer.Error(w, request, provider.NewError(http.StatusInternalServerError, err))
+		return
+	}
+
+	go a.generateThumbnails(info.Pathname)
+
+	if request.JSON {
+		httpjson.ResponseJSON(w, http.StatusOK, a.newAPIItem(request, info), false)
+		return
+	}
+
+	message := fmt.Sprintf("%s successfully restored%s", item.Path, outcome.describe(item.Name))
+	http.Redirect(w, r, fmt.Sprintf("%s/?message=%s&messageLevel=success#trash-list", request.GetURI(""), url.QueryEscape(message)), http.StatusFound)
+}
+
+// DeleteTrash permanently deletes an item from trash
+func (a *app) DeleteTrash(w http.ResponseWriter, r *http.Request, request provider.Request) {
+	if !canManageTrash(request) {
+		a.renderer.Error(w, request, provider.NewError(http.StatusForbidden, ErrNotAuthorized))
+		return
+	}
+
+	a.trashLock.Lock()
+	defer a.trashLock.Unlock()
+
+	index := a.findTrash(r.FormValue("id"))
+	if index == -1 {
+		a.renderer.Error(w, request, provider.NewError(http.StatusNotFound, ErrTrashNotFound))
+		return
+	}
+
+	item := a.trash[index]
+
+	if err := a.removeTrash(index); err != nil {
+		a.renderer.Error(w, request, provider.NewError(http.StatusInternalServerError, err))
+		return
+	}
+
+	if request.JSON {
+		httpjson.ResponseJSON(w, http.StatusOK, item, false)
+		return
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("%s/?message=%s&messageLevel=success#trash-list", request.GetURI(""), url.QueryEscape(fmt.Sprintf("%s permanently deleted", item.Path))), http.StatusFound)
+}
+
+// purgeTrash permanently deletes items older than retention
+func (a *app) purgeTrash() {
+	if !a.trashEnabled() {
+		return
+	}
+
+	a.trashLock.Lock()
+	defer a.trashLock.Unlock()
+
+	expiration := time.Now().Add(-a.trashRetention)
+
+	for index := len(a.trash) - 1; index >= 0; index-- {
+		item := a.trash[index]
+		if item.Date.After(expiration) {
+			continue
+		}
+
+		logger.Info("Purging %s from trash", item.Path)
+		if err := a.removeTrash(index); err != nil {
+			logger.Error("unable to purge %s from trash: %s", item.Path, err)
+		}
+	}
+}
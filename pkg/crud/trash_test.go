@@ -0,0 +1,396 @@
+package crud
+
+import (
+	"flag"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ViBiOh/fibr/pkg/provider"
+	"github.com/ViBiOh/fibr/pkg/thumbnail"
+)
+
+func deleteToTrash(t *testing.T, crudApp *app, renderer *testRenderer, name string) provider.TrashItem {
+	writer := httptest.NewRecorder()
+	crudApp.Delete(writer, newFormRequest("/", url.Values{"name": {name}}), provider.Request{Path: "/", Permissions: provider.PermissionEdit})
+
+	if writer.Code != http.StatusFound || renderer.err != nil {
+		t.Fatalf("Delete() = %d, `%v`", writer.Code, renderer.err)
+	}
+
+	if len(crudApp.trash) == 0 {
+		t.Fatalf("Delete() = %+v, want item in trash", crudApp.trash)
+	}
+
+	return crudApp.trash[len(crudApp.trash)-1]
+}
+
+func TestDeleteToTrash(t *testing.T) {
+	crudApp, storage, renderer := newTestApp(t)
+
+	if err := storage.CreateDir("/photos"); err != nil {
+		t.Fatalf("CreateDir() = %s", err)
+	}
+
+	item := deleteToTrash(t, crudApp, renderer, "photos")
+
+	if item.Path != "/photos" || !item.IsDir {
+		t.Errorf("Delete() = %+v, want /photos directory recorded", item)
+	}
+
+	if _, err := storage.Info("/photos"); !provider.IsNotExist(err) {
+		t.Errorf("Info() = `%v`, want directory moved away", err)
+	}
+
+	if _, err := storage.Info(getTrashPath(item)); err != nil {
+		t.Errorf("Info() = `%s`, want directory kept in trash", err)
+	}
+
+	crudApp.List(httptest.NewRecorder(), provider.Request{Path: "/", Permissions: provider.PermissionAll, Admin: true}, nil)
+
+	if trash, _ := renderer.content["Trash"].([]provider.TrashItem); len(trash) != 1 {
+		t.Errorf("List() = %+v, want trash listed for admin", renderer.content["Trash"])
+	}
+}
+
+func TestDeleteMetadataNames(t *testing.T) {
+	crudApp, storage, renderer := newTestApp(t)
+
+	fs := flag.NewFlagSet("trash-test", flag.ContinueOnError)
+	thumbnailConfig := thumbnail.Flags(fs, "thumbnail")
+
+	thumbnailApp, err := thumbnail.New(thumbnailConfig, storage)
+	if err != nil {
+		t.Fatalf("unable to create thumbnail: %s", err)
+	}
+
+	crudApp.thumbnail = thumbnailApp
+
+	for _, name := range []string{"photos", "trash", "versions", "uploads"} {
+		if err := storage.CreateDir("/" + name); err != nil {
+			t.Fatalf("CreateDir() = %s", err)
+		}
+
+		item := deleteToTrash(t, crudApp, renderer, name)
+
+		// thumbnails are removed asynchronously on delete
+		thumbnailApp.Remove(provider.StorageItem{Pathname: item.Path, Name: item.Name, IsDir: true})
+	}
+
+	if err := crudApp.loadTrash(); err != nil || len(crudApp.trash) != 4 {
+		t.Fatalf("loadTrash() = (%+v, `%v`), want 4 items in trash", crudApp.trash, err)
+	}
+
+	for _, item := range crudApp.trash {
+		if _, err := storage.Info(getTrashPath(item)); err != nil {
+			t.Errorf("Info() = `%s`, want %s kept in trash", err, item.Path)
+		}
+	}
+}
+
+func TestDeleteWithoutTrash(t *testing.T) {
+	crudApp, storage, renderer := newTestApp(t)
+	crudApp.trashRetention = 0
+
+	if err := storage.CreateDir("/photos"); err != nil {
+		t.Fatalf("CreateDir() = %s", err)
+	}
+
+	writer := httptest.NewRecorder()
+	crudApp.Delete(writer, newFormRequest("/", url.Values{"name": {"photos"}}), provider.Request{Path: "/", Permissions: provider.PermissionEdit})
+
+	if writer.Code != http.StatusFound || renderer.err != nil {
+		t.Fatalf("Delete() = %d, `%v`", writer.Code, renderer.err)
+	}
+
+	if len(crudApp.trash) != 0 {
+		t.Errorf("Delete() = %+v, want permanent delete", crudApp.trash)
+	}
+
+	if items, err := storage.List(trashDirname); err == nil && len(items) != 0 {
+		t.Errorf("List() = %+v, want nothing in trash", items)
+	}
+}
+
+func TestRestoreTrash(t *testing.T) {
+	crudApp, storage, renderer := newTestApp(t)
+
+	if err := storage.CreateDir("/photos"); err != nil {
+		t.Fatalf("CreateDir() = %s", err)
+	}
+
+	item := deleteToTrash(t, crudApp, renderer, "photos")
+
+	writer := httptest.NewRecorder()
+	crudApp.RestoreTrash(writer, newFormRequest("/", url.Values{"id": {item.ID}}), provider.Request{Path: "/", Permissions: provider.PermissionEdit})
+
+	if writer.Code != http.StatusForbidden {
+		t.Errorf("RestoreTrash() = %d, want forbidden for non admin", writer.Code)
+	}
+
+	if err := storage.CreateDir("/photos"); err != nil {
+		t.Fatalf("CreateDir() = %s", err)
+	}
+
+	renderer.err = nil
+	writer = httptest.NewRecorder()
+	crudApp.RestoreTrash(writer, newFormRequest("/", url.Values{"id": {item.ID}, "conflict": {conflictRename}}), provider.Request{Path: "/", Permissions: provider.PermissionAll, Admin: true})
+
+	if writer.Code != http.StatusFound || renderer.err != nil {
+		t.Fatalf("RestoreTrash() = %d, `%v`", writer.Code, renderer.err)
+	}
+
+	if _, err := storage.Info("/photos_1"); err != nil {
+		t.Errorf("Info() = `%s`, want restored alongside existing directory", err)
+	}
+
+	if len(crudApp.trash) != 0 {
+		t.Errorf("RestoreTrash() = %+v, want item removed from trash", crudApp.trash)
+	}
+
+	writer = httptest.NewRecorder()
+	crudApp.RestoreTrash(writer, newFormRequest("/", url.Values{"id": {item.ID}}), provider.Request{Path: "/", Permissions: provider.PermissionAll, Admin: true})
+
+	if writer.Code != http.StatusNotFound {
+		t.Errorf("RestoreTrash() = %d, want not found once restored", writer.Code)
+	}
+}
+
+func TestOverwriteToTrash(t *testing.T) {
+	crudApp, storage, renderer := newTestApp(t)
+	crudApp.versionCount = 0
+	admin := provider.Request{Path: "/", Permissions: provider.PermissionAll, Admin: true}
+
+	for _, name := range []string{"/draft.txt", "/report.txt"} {
+		if err := storage.Store(name, ioutil.NopCloser(strings.NewReader(name))); err != nil {
+			t.Fatalf("Store() = %s", err)
+		}
+	}
+
+	crudApp.Rename(httptest.NewRecorder(), newFormRequest("/", url.Values{"name": {"draft.txt"}, "newName": {"report.txt"}, "conflict": {conflictOverwrite}}), admin)
+	if renderer.err != nil {
+		t.Fatalf("Rename() = `%v`", renderer.err)
+	}
+
+	if len(crudApp.trash) != 1 || crudApp.trash[0].Path != "/report.txt" {
+		t.Fatalf("Rename() = %+v, want overwritten file in trash", crudApp.trash)
+	}
+
+	if content := readStorage(t, storage, getTrashPath(crudApp.trash[0])); content != "/report.txt" {
+		t.Errorf("Rename() = `%s`, want overwritten content kept in trash", content)
+	}
+
+	item := crudApp.trash[0]
+
+	crudApp.RestoreTrash(httptest.NewRecorder(), newFormRequest("/", url.Values{"id": {item.ID}, "conflict": {conflictOverwrite}}), admin)
+	if renderer.err != nil {
+		t.Fatalf("RestoreTrash() = `%v`", renderer.err)
+	}
+
+	if content := readStorage(t, storage, "/report.txt"); content != "/report.txt" {
+		t.Errorf("RestoreTrash() = `%s`, want trashed content restored", content)
+	}
+
+	if len(crudApp.trash) != 1 || crudApp.trash[0].ID == item.ID || readStorage(t, storage, getTrashPath(crudApp.trash[0])) != "/draft.txt" {
+		t.Errorf("RestoreTrash() = %+v, want overwritten file in trash", crudApp.trash)
+	}
+}
+
+func TestRestoreTrashReplace(t *testing.T) {
+	crudApp, storage, renderer := newTestApp(t)
+	admin := provider.Request{Path: "/", Permissions: provider.PermissionAll, Admin: true}
+
+	if err := storage.Store("/report.txt", ioutil.NopCloser(strings.NewReader("trashed"))); err != nil {
+		t.Fatalf("Store() = %s", err)
+	}
+
+	crudApp.Delete(httptest.NewRecorder(), newFormRequest("/", url.Values{"name": {"report.txt"}}), admin)
+	if renderer.err != nil || len(crudApp.trash) != 1 {
+		t.Fatalf("Delete() = `%v`", renderer.err)
+	}
+
+	if err := storage.Store("/report.txt", ioutil.NopCloser(strings.NewReader("current"))); err != nil {
+		t.Fatalf("Store() = %s", err)
+	}
+
+	crudApp.CreateShare(httptest.NewRecorder(), newFormRequest("/report.txt", url.Values{}), provider.Request{Path: "/report.txt", Permissions: provider.PermissionAll})
+	if renderer.err != nil || len(crudApp.metadatas) != 1 {
+		t.Fatalf("CreateShare() = `%v`", renderer.err)
+	}
+
+	crudApp.RestoreTrash(httptest.NewRecorder(), newFormRequest("/", url.Values{"id": {crudApp.trash[0].ID}, "conflict": {conflictOverwrite}}), admin)
+	if renderer.err != nil {
+		t.Fatalf("RestoreTrash() = `%v`", renderer.err)
+	}
+
+	if content := readStorage(t, storage, "/report.txt"); content != "trashed" {
+		t.Errorf("RestoreTrash() = `%s`, want trashed content restored", content)
+	}
+
+	if len(crudApp.metadatas) != 0 {
+		t.Errorf("RestoreTrash() = %+v, want share of replaced file removed", crudApp.metadatas)
+	}
+
+	if versions, err := crudApp.listVersions("/report.txt"); err != nil || len(versions) != 1 {
+		t.Errorf("RestoreTrash() = (%+v, `%v`), want replaced file kept as a version", versions, err)
+	}
+
+	if len(crudApp.trash) != 0 {
+		t.Errorf("RestoreTrash() = %+v, want empty trash", crudApp.trash)
+	}
+}
+
+func TestDeleteTrash(t *testing.T) {
+	crudApp, storage, renderer := newTestApp(t)
+
+	if err := storage.CreateDir("/photos"); err != nil {
+		t.Fatalf("CreateDir() = %s", err)
+	}
+
+	item := deleteToTrash(t, crudApp, renderer, "photos")
+
+	writer := httptest.NewRecorder()
+	crudApp.DeleteTrash(writer, newFormRequest("/", url.Values{"id": {item.ID}}), provider.Request{Path: "/", Permissions: provider.PermissionAll, Admin: true})
+
+	if writer.Code != http.StatusFound || renderer.err != nil {
+		t.Fatalf("DeleteTrash() = %d, `%v`", writer.Code, renderer.err)
+	}
+
+	if _, err := storage.Info(getTrashPath(item)); !provider.IsNotExist(err) {
+		t.Errorf("Info() = `%v`, want content purged", err)
+	}
+
+	if err := crudApp.loadTrash(); err != nil || len(crudApp.trash) != 0 {
+		t.Errorf("loadTrash() = (%+v, `%v`), want empty index persisted", crudApp.trash, err)
+	}
+}
+
+func TestPurgeTrash(t *testing.T) {
+	crudApp, storage, renderer := newTestApp(t)
+
+	for _, name := range []string{"old", "new"} {
+		if err := storage.CreateDir("/" + name); err != nil {
+			t.Fatalf("CreateDir() = %s", err)
+		}
+
+		deleteToTrash(t, crudApp, renderer, name)
+	}
+
+	crudApp.trash[0].Date = time.Now().Add(-crudApp.trashRetention - time.Minute)
+	expired := crudApp.trash[0]
+
+	crudApp.purgeTrash()
+
+	if len(crudApp.trash) != 1 || crudApp.trash[0].Path != "/new" {
+		t.Errorf("purgeTrash() = %+v, want only recent item kept", crudApp.trash)
+	}
+
+	if _, err := storage.Info(getTrashPath(expired)); !provider.IsNotExist(err) {
+		t.Errorf("purgeTrash() = `%v`, want expired content removed", err)
+	}
+}
+
+func TestOverwriteToVersion(t *testing.T) {
+	crudApp, storage, renderer := newTestApp(t)
+	admin := provider.Request{Path: "/", Permissions: provider.PermissionAll, Admin: true}
+
+	for _, name := range []string{"/draft.txt", "/report.txt"} {
+		if err := storage.Store(name, ioutil.NopCloser(strings.NewReader(name))); err != nil {
+			t.Fatalf("Store() = %s", err)
+		}
+	}
+
+	crudApp.CreateShare(httptest.NewRecorder(), newFormRequest("/report.txt", nil), provider.Request{Path: "/report.txt", Permissions: provider.PermissionAll})
+	if renderer.err != nil || len(crudApp.metadatas) != 1 {
+		t.Fatalf("CreateShare() = `%v`", renderer.err)
+	}
+
+	crudApp.Rename(httptest.NewRecorder(), newFormRequest("/", url.Values{"name": {"draft.txt"}, "newName": {"report.txt"}, "conflict": {conflictOverwrite}}), admin)
+	if renderer.err != nil {
+		t.Fatalf("Rename() = `%v`", renderer.err)
+	}
+
+	if len(crudApp.trash) != 0 {
+		t.Errorf("Rename() = %+v, want overwritten file kept as version only", crudApp.trash)
+	}
+
+	if versions, err := crudApp.listVersions("/report.txt"); err != nil || len(versions) != 1 || readStorage(t, storage, getVersionPath("/report.txt", versions[0].ID)) != "/report.txt" {
+		t.Errorf("listVersions() = (%+v, `%v`), want overwritten content as version", versions, err)
+	}
+
+	if len(crudApp.metadatas) != 0 {
+		t.Errorf("Rename() = %+v, want share of overwritten file removed", crudApp.metadatas)
+	}
+}
+
+func TestUploadOverwriteToTrash(t *testing.T) {
+	var cases = []struct {
+		intention string
+		upload    func(*app, provider.Request)
+		fileShare bool
+	}{
+		{
+			"upload",
+			func(crudApp *app, request provider.Request) {
+				crudApp.Post(httptest.NewRecorder(), newUploadRequest(t, "/?conflict=overwrite", "report.txt", "uploaded"), request)
+			},
+			false,
+		},
+		{
+			"tus",
+			func(crudApp *app, request provider.Request) {
+				crudApp.Tus(httptest.NewRecorder(), newTusRequest(http.MethodPost, "/", map[string]string{"Upload-Length": "0", "Upload-Metadata": "filename cmVwb3J0LnR4dA==,conflict b3ZlcndyaXRl"}, ""), request)
+			},
+			false,
+		},
+		{
+			"file share",
+			func(crudApp *app, request provider.Request) {
+				share := crudApp.metadatas[0]
+				crudApp.Post(httptest.NewRecorder(), newUploadRequest(t, "/", "other.txt", "uploaded"), provider.Request{Path: "/", Permissions: share.Permissions, Share: share})
+			},
+			true,
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.intention, func(t *testing.T) {
+			crudApp, storage, renderer := newTestApp(t)
+			crudApp.versionCount = 0
+
+			if err := storage.Store("/report.txt", ioutil.NopCloser(strings.NewReader("previous"))); err != nil {
+				t.Fatalf("Store() = %s", err)
+			}
+
+			crudApp.CreateShare(httptest.NewRecorder(), newFormRequest("/report.txt", url.Values{"permissions": {"download", "upload"}}), provider.Request{Path: "/report.txt", Permissions: provider.PermissionAll})
+			if renderer.err != nil || len(crudApp.metadatas) != 1 {
+				t.Fatalf("CreateShare() = `%v`", renderer.err)
+			}
+
+			testCase.upload(crudApp, provider.Request{Path: "/", Permissions: provider.PermissionAll, Admin: true})
+			if renderer.err != nil {
+				t.Fatalf("upload() = `%v`", renderer.err)
+			}
+
+			if len(crudApp.trash) != 1 || readStorage(t, storage, getTrashPath(crudApp.trash[0])) != "previous" {
+				t.Errorf("upload() = %+v, want overwritten content kept in trash", crudApp.trash)
+			}
+
+			if content := readStorage(t, storage, "/report.txt"); content == "previous" {
+				t.Errorf("upload() = `%s`, want new content in place", content)
+			}
+
+			if shared := len(crudApp.metadatas) == 1; shared != testCase.fileShare {
+				t.Errorf("upload() = %+v, want share kept only when uploaded through it", crudApp.metadatas)
+			}
+
+			if items, err := storage.List(uploadsDirname); err == nil && len(items) != 0 {
+				t.Errorf("upload() = %+v, want no staged content left", items)
+			}
+		})
+	}
+}
@@ -0,0 +1,506 @@
+package crud
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ViBiOh/fibr/pkg/provider"
+	"github.com/ViBiOh/fibr/pkg/thumbnail"
+	"github.com/ViBiOh/httputils/v3/pkg/logger"
+)
+
+const (
+	tusVersion      = "1.0.0"
+	tusExtensions   = "creation,expiration,termination"
+	tusContentType  = "application/offset+octet-stream"
+	tusInfoFilename = "info.json"
+	tusChunkPrefix  = "chunk-"
+)
+
+var (
+	uploadsDirname = path.Join(provider.MetadataDirectoryName, "uploads")
+	tusIDPattern   = regexp.MustCompile(`^[0-9a-f-]+$`)
+
+	// ErrUploadNotFound occurs when a resumable upload is unknown or expired
+	ErrUploadNotFound = errors.New("upload not found")
+)
+
+// tusUpload describes a resumable upload in progress
+type tusUpload struct {
+	ID       string    `json:"id"`
+	Pathname string    `json:"pathname"`
+	Length   int64     `json:"length"`
+	Offset   int64     `json:"offset"`
+	Conflict string    `json:"conflict"`
+	Chunks   []string  `json:"chunks"`
+	Expire   time.Time `json:"expire"`
+}
+
+// IsTusRequest checks if request is part of the tus resumable upload protocol
+func IsTusRequest(r *http.Request) bool {
+	return len(r.Header.Get("Tus-Resumable")) != 0
+}
+
+// Tus handles resumable upload requests, following tus.io protocol
+func (a *app) Tus(w http.ResponseWriter, r *http.Request, request provider.Request) {
+	w.Header().Set("Tus-Resumable", tusVersion)
+	w.Header().Set("Tus-Extension", tusExtensions)
+	w.Header().Set("Cache-Control", "no-store")
+
+	if r.Header.Get("Tus-Resumable") != tusVersion {
+		w.Header().Set("Tus-Version", tusVersion)
+		a.renderer.Error(w, request, provider.NewError(http.StatusPreconditionFailed, fmt.Errorf("unsupported tus version `%s`", r.Header.Get("Tus-Resumable"))))
+		return
+	}
+
+	if !request.CanUpload() {
+		a.renderer.Error(w, request, provider.NewError(http.StatusForbidden, ErrNotAuthorized))
+		return
+	}
+
+	request, httpErr := getDropRequest(w, r, request)
+	if httpErr != nil {
+		a.renderer.Error(w, request, httpErr)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		a.createTus(w, r, request)
+		return
+	}
+
+	upload, httpErr := a.getTus(request, r.URL.Query().Get("tus"))
+	if httpErr != nil {
+		a.renderer.Error(w, request, httpErr)
+		return
+	}
+	defer a.unlockTus(upload.ID)
+
+	switch r.Method {
+	case http.MethodHead:
+		w.Header().Set("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+		w.Header().Set("Upload-Length", strconv.FormatInt(upload.Length, 10))
+		w.Header().Set("Upload-Expires", upload.Expire.UTC().Format(http.TimeFormat))
+		w.WriteHeader(http.StatusOK)
+	case http.MethodPatch:
+		a.patchTus(w, r, request, upload)
+	case http.MethodDelete:
+		if err := a.storage.Remove(getTusDir(upload.ID)); err != nil {
+			a.renderer.Error(w, request, provider.NewError(http.StatusInternalServerError, err))
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		a.renderer.Error(w, request, provider.NewError(http.StatusMethodNotAllowed, fmt.Errorf("unknown tus method `%s`", r.Method)))
+	}
+}
+
+func (a *app) createTus(w http.ResponseWriter, r *http.Request, request provider.Request) {
+	length, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+	if err != nil || length < 0 {
+		a.renderer.Error(w, request, provider.NewError(http.StatusBadRequest, errors.New("invalid or missing Upload-Length header")))
+		return
+	}
+
+	metadata := parseTusMetadata(r.Header.Get("Upload-Metadata"))
+
+	policy, httpErr := a.getConflictPolicy(getConflictValue(r, metadata["conflict"]))
+	if httpErr != nil {
+		a.renderer.Error(w, request, httpErr)
+		return
+	}
+
+	policy = getDropPolicy(request, policy)
+
+	var pathname string
+
+	if request.Share != nil && request.Share.File {
+		pathname = request.Share.Path
+		policy = conflictOverwrite
+	} else {
+		filename, err := getUploadPath(metadata["filename"])
+		if err != nil {
+			a.renderer.Error(w, request, provider.NewError(http.StatusBadRequest, err))
+			return
+		}
+
+		pathname = request.GetFilepath(filename)
+
+		if httpErr := checkPermissionOn(request, pathname, provider.PermissionUpload); httpErr != nil {
+			a.renderer.Error(w, request, httpErr)
+			return
+		}
+
+		if _, httpErr := a.resolveConflict(pathname, policy); httpErr != nil {
+			a.renderer.Error(w, request, httpErr)
+			return
+		}
+	}
+
+	id, err := uuid()
+	if err != nil {
+		a.renderer.Error(w, request, provider.NewError(http.StatusInternalServerError, err))
+		return
+	}
+
+	upload := tusUpload{
+		ID:       id,
+		Pathname: pathname,
+		Conflict: policy,
+		Length:   length,
+		Chunks:   make([]string, 0),
+		Expire:   time.Now().Add(a.uploadExpiration),
+	}
+
+	if err := a.storage.CreateDir(getTusDir(id)); err != nil {
+		a.renderer.Error(w, request, provider.NewError(http.StatusInternalServerError, err))
+		return
+	}
+
+	if err := a.saveTus(upload); err != nil {
+		a.renderer.Error(w, request, provider.NewError(http.StatusInternalServerError, err))
+		return
+	}
+
+	if length == 0 {
+		pathname, httpErr := a.completeTus(request, upload)
+		if httpErr != nil {
+			a.renderer.Error(w, request, httpErr)
+			return
+		}
+
+		if !request.UploadOnly() {
+			w.Header().Set("Content-Location", request.GetPathnameURI(pathname))
+		}
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("%s/?tus=%s", strings.TrimSuffix(request.GetURI(""), "/"), id))
+	w.Header().Set("Upload-Expires", upload.Expire.UTC().Format(http.TimeFormat))
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (a *app) patchTus(w http.ResponseWriter, r *http.Request, request provider.Request, upload tusUpload) {
+	if r.Header.Get("Content-Type") != tusContentType {
+		a.renderer.Error(w, request, provider.NewError(http.StatusUnsupportedMediaType, fmt.Errorf("content-type must be %s", tusContentType)))
+		return
+	}
+
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset != upload.Offset {
+		a.renderer.Error(w, request, provider.NewError(http.StatusConflict, fmt.Errorf("upload offset must be %d", upload.Offset)))
+		return
+	}
+
+	chunkPathname := path.Join(getTusDir(upload.ID), fmt.Sprintf("%s%020d", tusChunkPrefix, upload.Offset))
+	written, err := a.writeTusChunk(chunkPathname, io.LimitReader(r.Body, upload.Length-upload.Offset))
+
+	if written > 0 {
+		upload.Chunks = append(upload.Chunks, chunkPathname)
+		upload.Offset += written
+		upload.Expire = time.Now().Add(a.uploadExpiration)
+
+		if saveErr := a.saveTus(upload); saveErr != nil {
+			a.renderer.Error(w, request, provider.NewError(http.StatusInternalServerError, saveErr))
+			return
+		}
+	}
+
+	if err != nil {
+		a.renderer.Error(w, request, provider.NewError(http.StatusInternalServerError, err))
+		return
+	}
+
+	if upload.Offset == upload.Length {
+		pathname, httpErr := a.completeTus(request, upload)
+		if httpErr != nil {
+			a.renderer.Error(w, request, httpErr)
+			return
+		}
+
+		if !request.UploadOnly() {
+			w.Header().Set("Content-Location", request.GetPathnameURI(pathname))
+		}
+	}
+
+	w.Header().Set("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	w.Header().Set("Upload-Expires", upload.Expire.UTC().Format(http.TimeFormat))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeTusChunk stores body as a chunk. Data received before a client disconnection is kept
+// so upload can be resumed from there, whereas a storage failure discards the chunk.
+func (a *app) writeTusChunk(pathname string, body io.Reader) (int64, error) {
+	writer, err := a.storage.WriterTo(pathname)
+	if err != nil {
+		return 0, err
+	}
+
+	reader := &errorReader{Reader: body}
+
+	written, err := io.Copy(writer, reader)
+	if err != nil && reader.err != err {
+		if abortErr := writer.Abort(); abortErr != nil {
+			logger.Error("unable to abort chunk %s: %s", pathname, abortErr)
+		}
+
+		return 0, err
+	}
+
+	if closeErr := writer.Close(); closeErr != nil {
+		return 0, closeErr
+	}
+
+	return written, err
+}
+
+// completeTus moves upload in place, according to its conflict policy, and gives final pathname
+func (a *app) completeTus(request provider.Request, upload tusUpload) (string, *provider.Error) {
+	policy := upload.Conflict
+	if len(policy) == 0 {
+		policy = conflictOverwrite
+	}
+
+	outcome, httpErr := a.resolveConflict(upload.Pathname, policy)
+	if httpErr != nil {
+		return "", httpErr
+	}
+
+	if err := a.storage.CreateDir(path.Dir(outcome.pathname)); err != nil {
+		return "", provider.NewError(http.StatusInternalServerError, err)
+	}
+
+	if httpErr := a.storeUpload(request, outcome, &chunksReader{storage: a.storage, pathnames: upload.Chunks}, getTusDir(upload.ID)); httpErr != nil {
+		return "", httpErr
+	}
+
+	if err := a.storage.Remove(getTusDir(upload.ID)); err != nil {
+		logger.Error("unable to remove upload %s: %s", upload.ID, err)
+	}
+
+	info, err := a.storage.Info(outcome.pathname)
+	if err != nil {
+		return "", provider.NewError(http.StatusInternalServerError, err)
+	}
+
+	if thumbnail.CanHaveThumbnail(info) {
+		a.thumbnail.GenerateThumbnail(info)
+	}
+
+	return outcome.pathname, nil
+}
+
+// getTus retrieves upload and locks it, caller has to unlock it when done
+func (a *app) getTus(request provider.Request, id string) (tusUpload, *provider.Error) {
+	if !tusIDPattern.MatchString(id) {
+		return tusUpload{}, provider.NewError(http.StatusNotFound, ErrUploadNotFound)
+	}
+
+	if !a.lockTus(id) {
+		return tusUpload{}, provider.NewError(http.StatusLocked, fmt.Errorf("upload %s is already in progress", id))
+	}
+
+	upload, err := a.loadTus(id)
+	if err != nil {
+		a.unlockTus(id)
+
+		if provider.IsNotExist(err) {
+			return tusUpload{}, provider.NewError(http.StatusNotFound, ErrUploadNotFound)
+		}
+		return tusUpload{}, provider.NewError(http.StatusInternalServerError, err)
+	}
+
+	if !isInside(upload.Pathname, request.GetFilepath("")) || upload.Expire.Before(time.Now()) {
+		a.unlockTus(id)
+		return tusUpload{}, provider.NewError(http.StatusNotFound, ErrUploadNotFound)
+	}
+
+	return upload, nil
+}
+
+func (a *app) lockTus(id string) bool {
+	a.uploadsLock.Lock()
+	defer a.uploadsLock.Unlock()
+
+	if a.uploads[id] {
+		return false
+	}
+
+	a.uploads[id] = true
+	return true
+}
+
+func (a *app) unlockTus(id string) {
+	a.uploadsLock.Lock()
+	defer a.uploadsLock.Unlock()
+
+	delete(a.uploads, id)
+}
+
+func (a *app) loadTus(id string) (tusUpload, error) {
+	var upload tusUpload
+
+	file, err := a.storage.ReaderFrom(path.Join(getTusDir(id), tusInfoFilename))
+	if err != nil {
+		return upload, err
+	}
+
+	defer func() {
+		if closeErr := file.Close(); closeErr != nil {
+			logger.Error("unable to close upload info: %s", closeErr)
+		}
+	}()
+
+	rawUpload, err := ioutil.ReadAll(file)
+	if err != nil {
+		return upload, err
+	}
+
+	return upload, json.Unmarshal(rawUpload, &upload)
+}
+
+func (a *app) saveTus(upload tusUpload) error {
+	content, err := json.Marshal(upload)
+	if err != nil {
+		return err
+	}
+
+	return a.storage.Store(path.Join(getTusDir(upload.ID), tusInfoFilename), ioutil.NopCloser(bytes.NewReader(content)))
+}
+
+// purgeTus removes abandoned uploads
+func (a *app) purgeTus() {
+	items, err := a.storage.List(uploadsDirname)
+	if err != nil {
+		if !provider.IsNotExist(err) {
+			logger.Error("unable to list uploads: %s", err)
+		}
+		return
+	}
+
+	now := time.Now()
+
+	for _, item := range items {
+		if !item.IsDir {
+			continue
+		}
+
+		expire := item.Date.Add(a.uploadExpiration)
+		if upload, err := a.loadTus(item.Name); err == nil {
+			expire = upload.Expire
+		}
+
+		if expire.After(now) || !a.lockTus(item.Name) {
+			continue
+		}
+
+		logger.Info("Removing expired upload %s", item.Name)
+		if err := a.storage.Remove(item.Pathname); err != nil {
+			logger.Error("unable to remove upload %s: %s", item.Name, err)
+		}
+
+		a.unlockTus(item.Name)
+	}
+}
+
+func getTusDir(id string) string {
+	return path.Join(uploadsDirname, id)
+}
+
+func parseTusMetadata(header string) map[string]string {
+	metadata := make(map[string]string)
+
+	for _, pair := range strings.Split(header, ",") {
+		parts := strings.SplitN(strings.TrimSpace(pair), " ", 2)
+		if len(parts[0]) == 0 {
+			continue
+		}
+
+		if len(parts) == 1 {
+			metadata[parts[0]] = ""
+			continue
+		}
+
+		value, err := base64.StdEncoding.DecodeString(parts[1])
+		if err != nil {
+			continue
+		}
+
+		metadata[parts[0]] = string(value)
+	}
+
+	return metadata
+}
+
+// errorReader remembers error returned by underlying reader
+type errorReader struct {
+	io.Reader
+	err error
+}
+
+func (e *errorReader) Read(p []byte) (int, error) {
+	n, err := e.Reader.Read(p)
+	if err != nil && err != io.EOF {
+		e.err = err
+	}
+
+	return n, err
+}
+
+// chunksReader reads chunks sequentially, opening them only when needed
+type chunksReader struct {
+	storage   provider.Storage
+	pathnames []string
+	current   provider.ReadSeekerCloser
+}
+
+func (c *chunksReader) Read(p []byte) (int, error) {
+	for {
+		if c.current == nil {
+			if len(c.pathnames) == 0 {
+				return 0, io.EOF
+			}
+
+			reader, err := c.storage.ReaderFrom(c.pathnames[0])
+			if err != nil {
+				return 0, err
+			}
+
+			c.current = reader
+			c.pathnames = c.pathnames[1:]
+		}
+
+		n, err := c.current.Read(p)
+		if err != io.EOF {
+			return n, err
+		}
+
+		if closeErr := c.current.Close(); closeErr != nil {
+			return n, closeErr
+		}
+		c.current = nil
+
+		if n > 0 {
+			return n, nil
+		}
+	}
+}
+
+func (c *chunksReader) Close() error {
+	if c.current == nil {
+		return nil
+	}
+
+	return c.current.Close()
+}
@@ -0,0 +1,189 @@
+package crud
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ViBiOh/fibr/pkg/provider"
+)
+
+func newTusRequest(method, target string, headers map[string]string, body string) *http.Request {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	r.Header.Set("Tus-Resumable", tusVersion)
+
+	for key, value := range headers {
+		r.Header.Set(key, value)
+	}
+
+	return r
+}
+
+func TestParseTusMetadata(t *testing.T) {
+	var cases = []struct {
+		intention string
+		input     string
+		want      map[string]string
+	}{
+		{
+			"empty",
+			"",
+			map[string]string{},
+		},
+		{
+			"multiple",
+			"filename YmVhY2guanBn,filetype aW1hZ2UvanBlZw==,is_confidential",
+			map[string]string{
+				"filename":        "beach.jpg",
+				"filetype":        "image/jpeg",
+				"is_confidential": "",
+			},
+		},
+		{
+			"invalid base64",
+			"filename !!!",
+			map[string]string{},
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.intention, func(t *testing.T) {
+			if result := parseTusMetadata(testCase.input); !reflect.DeepEqual(result, testCase.want) {
+				t.Errorf("parseTusMetadata(`%s`) = %#v, want %#v", testCase.input, result, testCase.want)
+			}
+		})
+	}
+}
+
+func TestTus(t *testing.T) {
+	crudApp, storage, _ := newTestApp(t)
+	request := provider.Request{Path: "/", Permissions: provider.PermissionEdit}
+
+	writer := httptest.NewRecorder()
+	crudApp.Tus(writer, newTusRequest(http.MethodPost, "/", map[string]string{"Upload-Length": "11", "Upload-Metadata": "filename YmVhY2gudHh0"}, ""), request)
+
+	location := writer.Header().Get("Location")
+	if writer.Code != http.StatusCreated || !strings.HasPrefix(location, "/?tus=") {
+		t.Fatalf("Tus(POST) = (%d, `%s`), want created", writer.Code, location)
+	}
+
+	patchHeaders := map[string]string{"Content-Type": tusContentType, "Upload-Offset": "0"}
+
+	writer = httptest.NewRecorder()
+	crudApp.Tus(writer, newTusRequest(http.MethodPatch, location, patchHeaders, "hello "), request)
+
+	if writer.Code != http.StatusNoContent || writer.Header().Get("Upload-Offset") != "6" {
+		t.Fatalf("Tus(PATCH) = (%d, `%s`), want offset 6", writer.Code, writer.Header().Get("Upload-Offset"))
+	}
+
+	if _, err := storage.Info("/beach.txt"); !provider.IsNotExist(err) {
+		t.Errorf("Info() = `%v`, want partial upload kept out of place", err)
+	}
+
+	writer = httptest.NewRecorder()
+	crudApp.Tus(writer, newTusRequest(http.MethodPatch, location, patchHeaders, "world"), request)
+
+	if writer.Code != http.StatusConflict {
+		t.Errorf("Tus(PATCH) = %d, want conflict on wrong offset", writer.Code)
+	}
+
+	writer = httptest.NewRecorder()
+	crudApp.Tus(writer, newTusRequest(http.MethodHead, location, nil, ""), request)
+
+	if writer.Code != http.StatusOK || writer.Header().Get("Upload-Offset") != "6" || writer.Header().Get("Upload-Length") != "11" {
+		t.Fatalf("Tus(HEAD) = (%d, %v), want offset 6 of 11", writer.Code, writer.Header())
+	}
+
+	patchHeaders["Upload-Offset"] = "6"
+
+	writer = httptest.NewRecorder()
+	crudApp.Tus(writer, newTusRequest(http.MethodPatch, location, patchHeaders, "world"), request)
+
+	if writer.Code != http.StatusNoContent || writer.Header().Get("Upload-Offset") != "11" {
+		t.Fatalf("Tus(PATCH) = (%d, `%s`), want offset 11", writer.Code, writer.Header().Get("Upload-Offset"))
+	}
+
+	reader, err := storage.ReaderFrom("/beach.txt")
+	if err != nil {
+		t.Fatalf("ReaderFrom() = %s", err)
+	}
+
+	if content, err := ioutil.ReadAll(reader); err != nil || string(content) != "hello world" {
+		t.Errorf("ReadAll() = (`%s`, `%v`), want `hello world`", content, err)
+	}
+
+	writer = httptest.NewRecorder()
+	crudApp.Tus(writer, newTusRequest(http.MethodHead, location, nil, ""), request)
+
+	if writer.Code != http.StatusNotFound {
+		t.Errorf("Tus(HEAD) = %d, want upload removed once completed", writer.Code)
+	}
+}
+
+func TestTusNotAuthorized(t *testing.T) {
+	crudApp, _, _ := newTestApp(t)
+
+	writer := httptest.NewRecorder()
+	crudApp.Tus(writer, newTusRequest(http.MethodPost, "/", map[string]string{"Upload-Length": "11", "Upload-Metadata": "filename YmVhY2gudHh0"}, ""), provider.Request{Path: "/"})
+
+	if writer.Code != http.StatusForbidden {
+		t.Errorf("Tus(POST) = %d, want forbidden", writer.Code)
+	}
+}
+
+func TestTusOutsideDirectory(t *testing.T) {
+	crudApp, storage, _ := newTestApp(t)
+
+	for _, name := range []string{"/docs", "/docs2"} {
+		if err := storage.CreateDir(name); err != nil {
+			t.Fatalf("CreateDir() = %s", err)
+		}
+	}
+
+	writer := httptest.NewRecorder()
+	crudApp.Tus(writer, newTusRequest(http.MethodPost, "/docs2/", map[string]string{"Upload-Length": "11", "Upload-Metadata": "filename YmVhY2gudHh0"}, ""), provider.Request{Path: "/docs2", Permissions: provider.PermissionEdit})
+
+	if writer.Code != http.StatusCreated {
+		t.Fatalf("Tus(POST) = %d, want created", writer.Code)
+	}
+
+	location := strings.Replace(writer.Header().Get("Location"), "/docs2/", "/docs/", 1)
+
+	writer = httptest.NewRecorder()
+	crudApp.Tus(writer, newTusRequest(http.MethodHead, location, nil, ""), provider.Request{Path: "/docs", Permissions: provider.PermissionEdit})
+
+	if writer.Code != http.StatusNotFound {
+		t.Errorf("Tus(HEAD) = %d, want upload of sibling directory not found", writer.Code)
+	}
+}
+
+func TestPurgeTus(t *testing.T) {
+	crudApp, storage, _ := newTestApp(t)
+
+	for _, upload := range []tusUpload{
+		{ID: "expired", Pathname: "/old.txt", Expire: time.Now().Add(-time.Minute)},
+		{ID: "active", Pathname: "/new.txt", Expire: time.Now().Add(time.Minute)},
+	} {
+		if err := storage.CreateDir(getTusDir(upload.ID)); err != nil {
+			t.Fatalf("CreateDir() = %s", err)
+		}
+
+		if err := crudApp.saveTus(upload); err != nil {
+			t.Fatalf("saveTus() = %s", err)
+		}
+	}
+
+	crudApp.purgeTus()
+
+	if _, err := storage.Info(getTusDir("expired")); !provider.IsNotExist(err) {
+		t.Errorf("purgeTus() = `%v`, want expired upload removed", err)
+	}
+
+	if _, err := storage.Info(getTusDir("active")); err != nil {
+		t.Errorf("purgeTus() = `%s`, want active upload kept", err)
+	}
+}
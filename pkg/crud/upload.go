@@ -4,86 +4,413 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"mime"
 	"mime/multipart"
 	"net/http"
 	"net/url"
 	"path"
+	"strings"
 
 	"github.com/ViBiOh/fibr/pkg/provider"
 	"github.com/ViBiOh/fibr/pkg/thumbnail"
+	"github.com/ViBiOh/httputils/v3/pkg/httpjson"
 	"github.com/ViBiOh/httputils/v3/pkg/logger"
 )
 
-func (a *app) saveUploadedFile(request provider.Request, part *multipart.Part) (filename string, err error) {
-	var filePath string
+const (
+	uploadStagingFilename = "content"
+)
+
+var (
+	// ErrInvalidUploadPath occurs when an uploaded file has a relative path escaping its directory
+	ErrInvalidUploadPath = errors.New("upload path is invalid")
+
+	// ErrFileShareUpload occurs when more than one file is uploaded on a share of a single file
+	ErrFileShareUpload = errors.New("only one file can be uploaded on a file share")
+)
+
+// getUploadPath sanitizes each part of given name, keeping directory structure of folder uploads
+func getUploadPath(name string) (string, error) {
+	parts := make([]string, 0)
+
+	for _, part := range strings.Split(strings.Replace(name, "\\", "/", -1), "/") {
+		if len(part) == 0 || part == "." {
+			continue
+		}
+
+		if part == ".." {
+			return "", ErrInvalidUploadPath
+		}
+
+		sanitized, err := provider.SanitizeName(part, true)
+		if err != nil {
+			return "", err
+		}
+
+		if sanitized == provider.MetadataDirectoryName {
+			return "", ErrInvalidUploadPath
+		}
+
+		if len(sanitized) != 0 {
+			parts = append(parts, sanitized)
+		}
+	}
+
+	if len(parts) == 0 {
+		return "", ErrEmptyName
+	}
+
+	return path.Join(parts...), nil
+}
+
+// getPartFilename gives filename of part, including relative path that multipart.Part.FileName strips
+func getPartFilename(part *multipart.Part) string {
+	if _, params, err := mime.ParseMediaType(part.Header.Get("Content-Disposition")); err == nil {
+		if filename := params["filename"]; len(filename) != 0 {
+			return filename
+		}
+	}
+
+	return part.FileName()
+}
+
+func (a *app) saveUploadedFile(request provider.Request, policy string, part *multipart.Part) (provider.StorageItem, string, *provider.Error) {
+	var (
+		filename string
+		outcome  conflictOutcome
+	)
 
 	if request.Share != nil && request.Share.File {
 		filename = path.Base(request.Share.Path)
-		filePath = request.Share.Path
+		outcome = conflictOutcome{pathname: request.Share.Path, overwritten: true}
 	} else {
-		filename, err = provider.SanitizeName(part.FileName(), true)
+		var err error
+
+		filename, err = getUploadPath(getPartFilename(part))
 		if err != nil {
-			return "", err
+			return provider.StorageItem{}, "", provider.NewError(http.StatusBadRequest, err)
 		}
-		filePath = request.GetFilepath(filename)
-	}
 
-	hostFile, err := a.storage.WriterTo(filePath)
-	if hostFile != nil {
-		defer func() {
-			if err := hostFile.Close(); err != nil {
-				logger.Error("unable to close host file: %s", err)
+		if httpErr := checkPermissionOn(request, request.GetFilepath(filename), provider.PermissionUpload); httpErr != nil {
+			return provider.StorageItem{}, "", httpErr
+		}
+
+		var httpErr *provider.Error
+		outcome, httpErr = a.resolveConflict(request.GetFilepath(filename), policy)
+		if httpErr != nil {
+			return provider.StorageItem{}, "", httpErr
+		}
+
+		if strings.Contains(filename, "/") || request.UploadOnly() {
+			if err := a.storage.CreateDir(path.Dir(outcome.pathname)); err != nil {
+				return provider.StorageItem{}, "", provider.NewError(http.StatusInternalServerError, err)
 			}
-		}()
+		}
 	}
 
-	if err != nil {
-		return "", err
+	var stagingDir string
+	if outcome.overwritten {
+		id, err := uuid()
+		if err != nil {
+			return provider.StorageItem{}, "", provider.NewError(http.StatusInternalServerError, err)
+		}
+
+		stagingDir = getTusDir(id)
+		defer a.removeStaging(stagingDir)
 	}
 
-	copyBuffer := make([]byte, 32*1024)
-	if _, err = io.CopyBuffer(hostFile, part, copyBuffer); err != nil {
-		return "", err
+	if httpErr := a.storeUpload(request, outcome, ioutil.NopCloser(part), stagingDir); httpErr != nil {
+		return provider.StorageItem{}, "", httpErr
 	}
 
-	info, err := a.storage.Info(filePath)
+	info, err := a.storage.Info(outcome.pathname)
 	if err != nil {
-		return "", err
+		return provider.StorageItem{}, "", provider.NewError(http.StatusInternalServerError, err)
 	}
 
 	if thumbnail.CanHaveThumbnail(info) {
 		a.thumbnail.GenerateThumbnail(info)
 	}
 
-	return filename, nil
+	if request.UploadOnly() {
+		return info, fmt.Sprintf("File %s successfully uploaded", filename), nil
+	}
+
+	return info, fmt.Sprintf("File %s successfully uploaded%s", filename, outcome.describe(filename)), nil
+}
+
+// storeUpload writes content at pathname of outcome, replacing existing item if overwritten, content being staged in given directory
+func (a *app) storeUpload(request provider.Request, outcome conflictOutcome, content io.ReadCloser, stagingDir string) *provider.Error {
+	var err error
+
+	if existing, infoErr := a.storage.Info(outcome.pathname); outcome.overwritten && infoErr == nil {
+		err = a.overwriteItem(existing, content, stagingDir, isShareFile(request, outcome.pathname))
+	} else if infoErr != nil && !provider.IsNotExist(infoErr) {
+		err = infoErr
+	} else {
+		err = a.storage.Store(outcome.pathname, content)
+	}
+
+	if err != nil {
+		return provider.NewError(http.StatusInternalServerError, err)
+	}
+
+	return nil
+}
+
+// overwriteItem stores content in place of existing item, kept as a version or in trash like any replaced item.
+// Content is staged in given directory first, so a failed upload leaves existing item untouched.
+func (a *app) overwriteItem(existing provider.StorageItem, content io.ReadCloser, stagingDir string, keepShares bool) error {
+	if !a.metadataEnabled {
+		// nothing is kept without metadata and storage overwrites atomically
+		return a.storage.Store(existing.Pathname, content)
+	}
+
+	if err := a.storage.CreateDir(stagingDir); err != nil {
+		return err
+	}
+
+	stagingPath := path.Join(stagingDir, uploadStagingFilename)
+	if err := a.storage.Store(stagingPath, content); err != nil {
+		return err
+	}
+
+	return a.commitOverwrite(existing, stagingPath, keepShares)
+}
+
+// commitOverwrite replaces existing item by content staged at given path
+func (a *app) commitOverwrite(existing provider.StorageItem, stagingPath string, keepShares bool) error {
+	replace := a.replaceItem
+	if keepShares {
+		replace = a.replaceContent
+	}
+
+	if err := replace(existing, true); err != nil {
+		return err
+	}
+
+	return a.storage.Rename(stagingPath, existing.Pathname)
+}
+
+// OverwriteItem gives a writer whose content replaces existing item on Close, like an upload overwriting it
+func (a *app) OverwriteItem(request provider.Request, existing provider.StorageItem) (provider.WriteAborter, error) {
+	if !a.metadataEnabled {
+		return a.storage.WriterTo(existing.Pathname)
+	}
+
+	id, err := uuid()
+	if err != nil {
+		return nil, err
+	}
+
+	stagingDir := getTusDir(id)
+	if err := a.storage.CreateDir(stagingDir); err != nil {
+		return nil, err
+	}
+
+	stagingPath := path.Join(stagingDir, uploadStagingFilename)
+
+	writer, err := a.storage.WriterTo(stagingPath)
+	if err != nil {
+		a.removeStaging(stagingDir)
+		return nil, err
+	}
+
+	return &overwriteWriter{
+		WriteAborter: writer,
+		app:          a,
+		existing:     existing,
+		stagingDir:   stagingDir,
+		stagingPath:  stagingPath,
+		keepShares:   isShareFile(request, existing.Pathname),
+	}, nil
+}
+
+// overwriteWriter stages written content, replacing existing item only once fully written
+type overwriteWriter struct {
+	provider.WriteAborter
+	app         *app
+	existing    provider.StorageItem
+	stagingDir  string
+	stagingPath string
+	keepShares  bool
+}
+
+func (o *overwriteWriter) Close() error {
+	defer o.app.removeStaging(o.stagingDir)
+
+	if err := o.WriteAborter.Close(); err != nil {
+		return err
+	}
+
+	return o.app.commitOverwrite(o.existing, o.stagingPath, o.keepShares)
+}
+
+func (o *overwriteWriter) Abort() error {
+	defer o.app.removeStaging(o.stagingDir)
+
+	return o.WriteAborter.Abort()
+}
+
+func (a *app) removeStaging(stagingDir string) {
+	if err := a.storage.Remove(stagingDir); err != nil && !provider.IsNotExist(err) {
+		logger.Error("unable to remove staging upload %s: %s", stagingDir, err)
+	}
+}
+
+// isShareFile checks if pathname is the file shared by request, whose share must survive an upload replacing it
+func isShareFile(request provider.Request, pathname string) bool {
+	return request.Share != nil && request.Share.File && request.Share.Path == pathname
+}
+
+func (a *app) uploadPart(request provider.Request, policy string, part *multipart.Part) provider.APIUpload {
+	result := provider.APIUpload{
+		Name: getPartFilename(part),
+	}
+
+	info, message, httpErr := a.saveUploadedFile(request, policy, part)
+	if httpErr != nil {
+		result.Status = httpErr.Status
+		result.Error = httpErr.Err.Error()
+
+		return result
+	}
+
+	result.Status = http.StatusCreated
+	result.Message = message
+
+	// visitor of a drop box can't know where its file has been saved, it would reveal existing ones
+	if !request.UploadOnly() {
+		item := a.newAPIItem(request, info)
+		result.Item = &item
+	}
+
+	return result
 }
 
 // Upload saves form files to filesystem
-func (a *app) Upload(w http.ResponseWriter, r *http.Request, request provider.Request, part *multipart.Part) {
-	if !request.CanEdit {
+func (a *app) Upload(w http.ResponseWriter, r *http.Request, request provider.Request, values map[string]string, part *multipart.Part, reader *multipart.Reader) {
+	if !request.CanUpload() {
 		a.renderer.Error(w, request, provider.NewError(http.StatusForbidden, ErrNotAuthorized))
 		return
 	}
 
+	request, httpErr := getDropRequest(w, r, request)
+	if httpErr != nil {
+		a.renderer.Error(w, request, httpErr)
+		return
+	}
+
 	if part == nil {
 		a.renderer.Error(w, request, provider.NewError(http.StatusBadRequest, errors.New("no file provided for save")))
 		return
 	}
 
-	filename, err := a.saveUploadedFile(request, part)
-	if err != nil {
-		a.renderer.Error(w, request, provider.NewError(http.StatusInternalServerError, err))
+	policy, httpErr := a.getConflictPolicy(getConflictValue(r, values["conflict"]))
+	if httpErr != nil {
+		a.renderer.Error(w, request, httpErr)
+		return
+	}
+
+	policy = getDropPolicy(request, policy)
+
+	results := make([]provider.APIUpload, 0)
+
+	for part != nil {
+		if part.FormName() == "file" {
+			if len(results) != 0 && request.Share != nil && request.Share.File {
+				results = append(results, provider.APIUpload{
+					Name:   getPartFilename(part),
+					Status: http.StatusBadRequest,
+					Error:  ErrFileShareUpload.Error(),
+				})
+				break
+			}
+
+			results = append(results, a.uploadPart(request, policy, part))
+		}
+
+		next, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			results = append(results, provider.APIUpload{
+				Status: http.StatusBadRequest,
+				Error:  fmt.Sprintf("unable to read next file: %s", err),
+			})
+			break
+		}
+
+		part = next
+	}
+
+	a.renderUploads(w, r, request, results)
+}
+
+func (a *app) renderUploads(w http.ResponseWriter, r *http.Request, request provider.Request, results []provider.APIUpload) {
+	messages := make([]string, 0)
+	failed := make([]provider.APIUpload, 0)
+
+	for _, result := range results {
+		if len(result.Error) != 0 {
+			failed = append(failed, result)
+			messages = append(messages, fmt.Sprintf("%s: %s", result.Name, result.Error))
+		} else {
+			messages = append(messages, result.Message)
+		}
+	}
+
+	if len(results) == 1 && len(failed) == 1 {
+		a.renderer.Error(w, request, provider.NewError(failed[0].Status, errors.New(failed[0].Error)))
 		return
 	}
 
-	content := fmt.Sprintf("File %s successfully uploaded", filename)
+	status := http.StatusCreated
+	if len(failed) != 0 {
+		status = http.StatusMultiStatus
+	}
+
+	if request.JSON {
+		if len(results) == 1 && results[0].Item != nil {
+			item := *results[0].Item
+			item.Message = results[0].Message
+
+			httpjson.ResponseJSON(w, status, item, false)
+			return
+		}
+
+		httpjson.ResponseJSON(w, status, results, false)
+		return
+	}
 
 	if r.Header.Get("Accept") == "text/plain" {
-		w.WriteHeader(http.StatusOK)
-		provider.SafeWrite(w, content)
+		if status == http.StatusCreated {
+			status = http.StatusOK
+		}
+
+		w.WriteHeader(status)
+		provider.SafeWrite(w, strings.Join(messages, "\n"))
 
 		return
 	}
 
-	http.Redirect(w, r, fmt.Sprintf("%s/?message=%s&messageLevel=success", request.GetURI(""), url.QueryEscape(content)), http.StatusFound)
+	content := messages[0]
+	level := "success"
+
+	if len(results) > 1 {
+		content = fmt.Sprintf("%d files successfully uploaded", len(results)-len(failed))
+	}
+
+	if len(failed) != 0 {
+		level = "error"
+		content = fmt.Sprintf("%d of %d files failed to upload: %s", len(failed), len(results), failed[0].Name)
+		if len(failed) > 1 {
+			content += ", ..."
+		}
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("%s/?message=%s&messageLevel=%s", request.GetURI(""), url.QueryEscape(content), level), http.StatusFound)
 }
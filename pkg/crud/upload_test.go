@@ -0,0 +1,134 @@
+package crud
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ViBiOh/fibr/pkg/provider"
+)
+
+func TestGetUploadPath(t *testing.T) {
+	var cases = []struct {
+		intention string
+		input     string
+		want      string
+		wantErr   error
+	}{
+		{
+			"simple",
+			"Rapport Annuel.pdf",
+			"rapport_annuel.pdf",
+			nil,
+		},
+		{
+			"folder",
+			"Vacances/Été 2020/plage.jpg",
+			"vacances/ete_2020/plage.jpg",
+			nil,
+		},
+		{
+			"windows separator",
+			"vacances\\plage.jpg",
+			"vacances/plage.jpg",
+			nil,
+		},
+		{
+			"cleaned",
+			"/vacances//./plage.jpg",
+			"vacances/plage.jpg",
+			nil,
+		},
+		{
+			"relative",
+			"vacances/../../etc/passwd",
+			"",
+			ErrInvalidUploadPath,
+		},
+		{
+			"metadata",
+			".fibr/.json",
+			"",
+			ErrInvalidUploadPath,
+		},
+		{
+			"empty",
+			"/",
+			"",
+			ErrEmptyName,
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.intention, func(t *testing.T) {
+			if result, err := getUploadPath(testCase.input); result != testCase.want || err != testCase.wantErr {
+				t.Errorf("getUploadPath(`%s`) = (`%s`, `%v`), want (`%s`, `%v`)", testCase.input, result, err, testCase.want, testCase.wantErr)
+			}
+		})
+	}
+}
+
+func TestUploadMultiple(t *testing.T) {
+	crudApp, storage, _ := newTestApp(t)
+
+	r := newMultiUploadRequest(t, "/?conflict=error", [][2]string{
+		{"Vacances/plage.jpg", "beach"},
+		{"Vacances/Montagne/chalet.jpg", "mountain"},
+		{"../escape.txt", "escape"},
+		{"notes.txt", "notes"},
+	})
+
+	writer := httptest.NewRecorder()
+	crudApp.Post(writer, r, provider.Request{Path: "/", Permissions: provider.PermissionEdit, JSON: true})
+
+	var results []provider.APIUpload
+	if err := json.NewDecoder(writer.Body).Decode(&results); err != nil {
+		t.Fatalf("Decode() = %s", err)
+	}
+
+	if writer.Code != http.StatusMultiStatus || len(results) != 4 {
+		t.Fatalf("Upload() = (%d, %+v), want report of 4 files", writer.Code, results)
+	}
+
+	for index, want := range []int{http.StatusCreated, http.StatusCreated, http.StatusBadRequest, http.StatusCreated} {
+		if results[index].Status != want {
+			t.Errorf("Upload()[%d] = %+v, want status %d", index, results[index], want)
+		}
+	}
+
+	for _, pathname := range []string{"/vacances/plage.jpg", "/vacances/montagne/chalet.jpg", "/notes.txt"} {
+		if _, err := storage.Info(pathname); err != nil {
+			t.Errorf("Info(`%s`) = %s", pathname, err)
+		}
+	}
+}
+
+func TestUploadFileShare(t *testing.T) {
+	crudApp, storage, _ := newTestApp(t)
+
+	if err := storage.Store("/report.txt", ioutil.NopCloser(strings.NewReader("first"))); err != nil {
+		t.Fatalf("Store() = %s", err)
+	}
+
+	share := &provider.Share{ID: "a1b2c3", Path: "/report.txt", File: true, Permissions: provider.PermissionEdit}
+	r := newMultiUploadRequest(t, "/", [][2]string{{"second.txt", "second"}, {"third.txt", "third"}})
+
+	writer := httptest.NewRecorder()
+	crudApp.Post(writer, r, provider.Request{Path: "/", Permissions: share.Permissions, Share: share, JSON: true})
+
+	var results []provider.APIUpload
+	if err := json.NewDecoder(writer.Body).Decode(&results); err != nil {
+		t.Fatalf("Decode() = %s", err)
+	}
+
+	if writer.Code != http.StatusMultiStatus || len(results) != 2 || results[0].Status != http.StatusCreated || results[1].Status != http.StatusBadRequest {
+		t.Errorf("Upload() = (%d, %+v), want second file rejected", writer.Code, results)
+	}
+
+	if content := readStorage(t, storage, "/report.txt"); content != "second" {
+		t.Errorf("Upload() = `%s`, want shared file written once", content)
+	}
+}
@@ -2,11 +2,23 @@ package crud
 
 import (
 	"net/http"
+	"os"
 	"strings"
 
 	"github.com/ViBiOh/fibr/pkg/provider"
+	"github.com/ViBiOh/fibr/pkg/sha"
+	"github.com/ViBiOh/fibr/pkg/thumbnail"
 )
 
+func (a *app) newAPIItem(request provider.Request, item provider.StorageItem) provider.APIItem {
+	renderItem := provider.RenderItem{
+		ID:          sha.Sha1(item.Name),
+		StorageItem: item,
+	}
+
+	return provider.NewAPIItem(request, renderItem, thumbnail.CanHaveThumbnail(item) && a.thumbnail.HasThumbnail(item))
+}
+
 func getPreviousAndNext(file provider.StorageItem, files []provider.StorageItem) (*provider.StorageItem, *provider.StorageItem) {
 	var (
 		found    bool
@@ -35,6 +47,23 @@ func getPreviousAndNext(file provider.StorageItem, files []provider.StorageItem)
 	return previous, nil
 }
 
+// checkPermissionOn checks that request has given permission on pathname and on its content, according to access control list
+func checkPermissionOn(request provider.Request, pathname string, permission provider.Permission) *provider.Error {
+	if !request.IsVisible(pathname) {
+		return provider.NewError(http.StatusNotFound, os.ErrNotExist)
+	}
+
+	if !request.PermissionOn(pathname).Has(permission) {
+		return provider.NewError(http.StatusForbidden, ErrNotAuthorized)
+	}
+
+	if request.ACL.Restricts(pathname, permission) {
+		return provider.NewError(http.StatusForbidden, ErrRestrictedContent)
+	}
+
+	return nil
+}
+
 func checkFormName(r *http.Request, formName string) (string, *provider.Error) {
 	name := strings.TrimSpace(r.FormValue(formName))
 	if name == "" {
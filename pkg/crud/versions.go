@@ -0,0 +1,354 @@
+package crud
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/ViBiOh/fibr/pkg/provider"
+	"github.com/ViBiOh/fibr/pkg/thumbnail"
+	"github.com/ViBiOh/httputils/v3/pkg/httpjson"
+	"github.com/ViBiOh/httputils/v3/pkg/logger"
+)
+
+var (
+	versionsDirname  = path.Join(provider.MetadataDirectoryName, "versions")
+	versionIDPattern = regexp.MustCompile(`^[0-9]+$`)
+
+	// ErrVersionNotFound occurs when a version is unknown or expired
+	ErrVersionNotFound = errors.New("version not found")
+)
+
+// canManageVersions checks if request can read previous contents and overwrite current one
+func canManageVersions(request provider.Request) bool {
+	return request.CanDownload() && request.CanUpload()
+}
+
+func (a *app) versionEnabled() bool {
+	return a.metadataEnabled && a.versionCount > 0
+}
+
+func getVersionsDir(pathname string) string {
+	return path.Join(versionsDirname, pathname)
+}
+
+func getVersionPath(pathname, id string) string {
+	return path.Join(getVersionsDir(pathname), id)
+}
+
+func newVersion(item provider.StorageItem) (provider.Version, bool) {
+	if item.IsDir || !versionIDPattern.MatchString(item.Name) {
+		return provider.Version{}, false
+	}
+
+	timestamp, err := strconv.ParseInt(item.Name, 10, 64)
+	if err != nil {
+		return provider.Version{}, false
+	}
+
+	return provider.Version{
+		ID:   item.Name,
+		Size: item.Size,
+		Date: time.Unix(0, timestamp),
+	}, true
+}
+
+func closeVersion(reader io.Closer) {
+	if err := reader.Close(); err != nil {
+		logger.Error("unable to close version: %s", err)
+	}
+}
+
+// listVersions gives previous versions of given file, most recent first
+func (a *app) listVersions(pathname string) ([]provider.Version, error) {
+	versions := make([]provider.Version, 0)
+
+	items, err := a.storage.List(getVersionsDir(pathname))
+	if err != nil {
+		if provider.IsNotExist(err) {
+			return versions, nil
+		}
+
+		return nil, err
+	}
+
+	for _, item := range items {
+		if version, ok := newVersion(item); ok {
+			versions = append(versions, version)
+		}
+	}
+
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i].Date.After(versions[j].Date)
+	})
+
+	return versions, nil
+}
+
+func (a *app) saveVersion(pathname string) error {
+	if !a.versionEnabled() {
+		return nil
+	}
+
+	if err := a.copyVersion(pathname); err != nil {
+		return err
+	}
+
+	return a.pruneVersions(pathname)
+}
+
+func (a *app) copyVersion(pathname string) error {
+	info, err := a.storage.Info(pathname)
+	if err != nil {
+		if provider.IsNotExist(err) {
+			return nil
+		}
+
+		return err
+	}
+
+	if info.IsDir {
+		return nil
+	}
+
+	if err := a.storage.CreateDir(getVersionsDir(pathname)); err != nil {
+		return err
+	}
+
+	reader, err := a.storage.ReaderFrom(pathname)
+	if err != nil {
+		return err
+	}
+
+	defer closeVersion(reader)
+
+	return a.storage.Store(getVersionPath(pathname, strconv.FormatInt(time.Now().UnixNano(), 10)), reader)
+}
+
+// pruneVersions removes versions of given file above retention count or age
+func (a *app) pruneVersions(pathname string) error {
+	versions, err := a.listVersions(pathname)
+	if err != nil {
+		return err
+	}
+
+	for index, version := range versions {
+		if uint(index) < a.versionCount && !a.isVersionExpired(version) {
+			continue
+		}
+
+		if err := a.storage.Remove(getVersionPath(pathname, version.ID)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (a *app) isVersionExpired(version provider.Version) bool {
+	return a.versionAge > 0 && version.Date.Before(time.Now().Add(-a.versionAge))
+}
+
+// moveVersions keeps versions along a renamed item
+func (a *app) moveVersions(oldPath, newPath string) error {
+	merged, err := a.moveVersionsDir(getVersionsDir(oldPath), getVersionsDir(newPath))
+	if err != nil || !merged {
+		return err
+	}
+
+	return a.pruneVersions(newPath)
+}
+
+// moveVersionsDir moves a directory of versions, merging it into destination if it already exists
+func (a *app) moveVersionsDir(oldDir, newDir string) (bool, error) {
+	oldInfo, err := a.storage.Info(oldDir)
+	if err != nil {
+		if provider.IsNotExist(err) {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	if _, err := a.storage.Info(newDir); err != nil {
+		if !provider.IsNotExist(err) {
+			return false, err
+		}
+
+		if err := a.storage.CreateDir(path.Dir(newDir)); err != nil {
+			return false, err
+		}
+
+		return false, a.storage.Rename(oldDir, newDir)
+	}
+
+	if !oldInfo.IsDir {
+		return false, nil
+	}
+
+	items, err := a.storage.List(oldDir)
+	if err != nil {
+		return false, err
+	}
+
+	for _, item := range items {
+		if _, err := a.moveVersionsDir(item.Pathname, path.Join(newDir, item.Name)); err != nil {
+			return false, err
+		}
+	}
+
+	return true, a.storage.Remove(oldDir)
+}
+
+// removeVersions deletes versions of given item, and of its content for a directory
+func (a *app) removeVersions(pathname string) error {
+	if err := a.storage.Remove(getVersionsDir(pathname)); err != nil && !provider.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+// purgeVersions removes versions older than retention age
+func (a *app) purgeVersions() {
+	if a.versionAge <= 0 {
+		return
+	}
+
+	expired := make([]string, 0)
+
+	err := a.storage.Walk(versionsDirname, func(item provider.StorageItem, err error) error {
+		if err != nil {
+			return nil
+		}
+
+		if version, ok := newVersion(item); ok && a.isVersionExpired(version) {
+			expired = append(expired, item.Pathname)
+		}
+
+		return nil
+	})
+
+	if err != nil && !provider.IsNotExist(err) {
+		logger.Error("unable to walk versions: %s", err)
+	}
+
+	for _, pathname := range expired {
+		if err := a.storage.Remove(pathname); err != nil {
+			logger.Error("unable to remove version %s: %s", pathname, err)
+		}
+	}
+}
+
+// serveVersion sends content of a previous version of given file
+func (a *app) serveVersion(w http.ResponseWriter, r *http.Request, request provider.Request, info provider.StorageItem, id string) {
+	if !canManageVersions(request) {
+		a.renderer.Error(w, request, provider.NewError(http.StatusForbidden, ErrNotAuthorized))
+		return
+	}
+
+	if !versionIDPattern.MatchString(id) {
+		a.renderer.Error(w, request, provider.NewError(http.StatusNotFound, ErrVersionNotFound))
+		return
+	}
+
+	versionPath := getVersionPath(info.Pathname, id)
+
+	versionInfo, err := a.storage.Info(versionPath)
+	if err != nil {
+		if provider.IsNotExist(err) {
+			a.renderer.Error(w, request, provider.NewError(http.StatusNotFound, ErrVersionNotFound))
+		} else {
+			a.renderer.Error(w, request, provider.NewError(http.StatusInternalServerError, err))
+		}
+		return
+	}
+
+	if httpErr := a.startShareDownload(r, request, versionInfo.Size); httpErr != nil {
+		a.renderer.Error(w, request, httpErr)
+		return
+	}
+
+	file, err := a.storage.ReaderFrom(versionPath)
+	if err != nil {
+		a.renderer.Error(w, request, provider.NewError(http.StatusInternalServerError, err))
+		return
+	}
+
+	defer closeVersion(file)
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", info.Name))
+
+	writer := &countingWriter{ResponseWriter: w}
+	http.ServeContent(writer, r, info.Name, versionInfo.Date, file)
+	a.endShareDownload(request, writer.written)
+}
+
+// RestoreVersion replaces content of file by a previous version, current content becoming a version
+func (a *app) RestoreVersion(w http.ResponseWriter, r *http.Request, request provider.Request) {
+	if !canManageVersions(request) {
+		a.renderer.Error(w, request, provider.NewError(http.StatusForbidden, ErrNotAuthorized))
+		return
+	}
+
+	id := r.FormValue("id")
+	if !versionIDPattern.MatchString(id) {
+		a.renderer.Error(w, request, provider.NewError(http.StatusNotFound, ErrVersionNotFound))
+		return
+	}
+
+	pathname := request.GetFilepath("")
+	versionPath := getVersionPath(pathname, id)
+
+	reader, err := a.storage.ReaderFrom(versionPath)
+	if err != nil {
+		if provider.IsNotExist(err) {
+			a.renderer.Error(w, request, provider.NewError(http.StatusNotFound, ErrVersionNotFound))
+		} else {
+			a.renderer.Error(w, request, provider.NewError(http.StatusInternalServerError, err))
+		}
+		return
+	}
+
+	defer closeVersion(reader)
+
+	if err := a.copyVersion(pathname); err != nil {
+		a.renderer.Error(w, request, provider.NewError(http.StatusInternalServerError, err))
+		return
+	}
+
+	if err := a.storage.Store(pathname, reader); err != nil {
+		a.renderer.Error(w, request, provider.NewError(http.StatusInternalServerError, err))
+		return
+	}
+
+	if err := a.storage.Remove(versionPath); err != nil {
+		logger.Error("unable to remove restored version %s: %s", versionPath, err)
+	} else if err := a.pruneVersions(pathname); err != nil {
+		logger.Error("unable to prune versions of %s: %s", pathname, err)
+	}
+
+	info, err := a.storage.Info(pathname)
+	if err != nil {
+		a.renderer.Error(w, request, provider.NewError(http.StatusInternalServerError, err))
+		return
+	}
+
+	if thumbnail.CanHaveThumbnail(info) {
+		go a.thumbnail.GenerateThumbnail(info)
+	}
+
+	if request.JSON {
+		httpjson.ResponseJSON(w, http.StatusOK, a.newAPIItem(request, info), false)
+		return
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("%s?browser&message=%s&messageLevel=success", request.GetURI(""), url.QueryEscape(fmt.Sprintf("%s successfully restored", info.Name))), http.StatusFound)
+}
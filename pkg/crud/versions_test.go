@@ -0,0 +1,275 @@
+package crud
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ViBiOh/fibr/pkg/provider"
+)
+
+func uploadOverwrite(t *testing.T, crudApp *app, renderer *testRenderer, content string) {
+	writer := httptest.NewRecorder()
+	crudApp.Post(writer, newUploadRequest(t, "/?conflict=overwrite", "report.txt", content), provider.Request{Path: "/", Permissions: provider.PermissionEdit})
+
+	if writer.Code != http.StatusFound || renderer.err != nil {
+		t.Fatalf("Post() = %d, `%v`", writer.Code, renderer.err)
+	}
+}
+
+func readStorage(t *testing.T, storage provider.Storage, pathname string) string {
+	reader, err := storage.ReaderFrom(pathname)
+	if err != nil {
+		t.Fatalf("ReaderFrom() = %s", err)
+	}
+	defer reader.Close()
+
+	content, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("ReadAll() = %s", err)
+	}
+
+	return string(content)
+}
+
+func TestUploadKeepsVersions(t *testing.T) {
+	crudApp, storage, renderer := newTestApp(t)
+	crudApp.versionCount = 2
+
+	for _, content := range []string{"v1", "v2", "v3", "v4"} {
+		uploadOverwrite(t, crudApp, renderer, content)
+	}
+
+	versions, err := crudApp.listVersions("/report.txt")
+	if err != nil {
+		t.Fatalf("listVersions() = %s", err)
+	}
+
+	if len(versions) != 2 {
+		t.Fatalf("listVersions() = %+v, want 2 versions kept", versions)
+	}
+
+	var contents []string
+	for _, version := range versions {
+		contents = append(contents, readStorage(t, storage, getVersionPath("/report.txt", version.ID)))
+	}
+
+	if strings.Join(contents, ",") != "v3,v2" {
+		t.Errorf("listVersions() = %v, want most recent previous contents first", contents)
+	}
+
+	crudApp.Browser(httptest.NewRecorder(), provider.Request{Path: "/report.txt", Permissions: provider.PermissionEdit}, provider.StorageItem{Name: "report.txt", Pathname: "/report.txt"}, nil)
+
+	if listed, _ := renderer.content["Versions"].([]provider.Version); len(listed) != 2 {
+		t.Errorf("Browser() = %+v, want versions listed for editor", renderer.content["Versions"])
+	}
+}
+
+func TestRestoreVersion(t *testing.T) {
+	crudApp, storage, renderer := newTestApp(t)
+
+	uploadOverwrite(t, crudApp, renderer, "first")
+	uploadOverwrite(t, crudApp, renderer, "second")
+
+	versions, err := crudApp.listVersions("/report.txt")
+	if err != nil || len(versions) != 1 {
+		t.Fatalf("listVersions() = (%+v, `%v`), want one version", versions, err)
+	}
+
+	writer := httptest.NewRecorder()
+	crudApp.RestoreVersion(writer, newFormRequest("/report.txt", url.Values{"id": {versions[0].ID}}), provider.Request{Path: "/report.txt", Permissions: provider.PermissionRead})
+
+	if writer.Code != http.StatusForbidden {
+		t.Errorf("RestoreVersion() = %d, want forbidden for reader", writer.Code)
+	}
+
+	renderer.err = nil
+	writer = httptest.NewRecorder()
+	crudApp.Post(writer, newFormRequest("/report.txt", url.Values{"type": {"version"}, "method": {http.MethodPatch}, "id": {versions[0].ID}}), provider.Request{Path: "/report.txt", Permissions: provider.PermissionEdit})
+
+	if writer.Code != http.StatusFound || renderer.err != nil {
+		t.Fatalf("RestoreVersion() = %d, `%v`", writer.Code, renderer.err)
+	}
+
+	if content := readStorage(t, storage, "/report.txt"); content != "first" {
+		t.Errorf("RestoreVersion() = `%s`, want `first`", content)
+	}
+
+	versions, err = crudApp.listVersions("/report.txt")
+	if err != nil || len(versions) != 1 || readStorage(t, storage, getVersionPath("/report.txt", versions[0].ID)) != "second" {
+		t.Errorf("listVersions() = (%+v, `%v`), want replaced content kept as version", versions, err)
+	}
+}
+
+func TestServeVersion(t *testing.T) {
+	crudApp, _, renderer := newTestApp(t)
+
+	uploadOverwrite(t, crudApp, renderer, "first")
+	uploadOverwrite(t, crudApp, renderer, "second")
+
+	versions, err := crudApp.listVersions("/report.txt")
+	if err != nil || len(versions) != 1 {
+		t.Fatalf("listVersions() = (%+v, `%v`), want one version", versions, err)
+	}
+
+	var cases = []struct {
+		intention  string
+		version    string
+		permission provider.Permission
+		want       int
+		wantBody   string
+	}{
+		{
+			"reader",
+			versions[0].ID,
+			provider.PermissionRead,
+			http.StatusForbidden,
+			"",
+		},
+		{
+			"unknown",
+			"123",
+			provider.PermissionEdit,
+			http.StatusNotFound,
+			"",
+		},
+		{
+			"invalid",
+			"..",
+			provider.PermissionEdit,
+			http.StatusNotFound,
+			"",
+		},
+		{
+			"valid",
+			versions[0].ID,
+			provider.PermissionEdit,
+			http.StatusOK,
+			"first",
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.intention, func(t *testing.T) {
+			writer := httptest.NewRecorder()
+			crudApp.Get(writer, httptest.NewRequest(http.MethodGet, "/report.txt?version="+url.QueryEscape(testCase.version), nil), provider.Request{Path: "/report.txt", Permissions: testCase.permission})
+
+			if writer.Code != testCase.want {
+				t.Errorf("Get() = %d, want %d", writer.Code, testCase.want)
+			}
+
+			if len(testCase.wantBody) != 0 && writer.Body.String() != testCase.wantBody {
+				t.Errorf("Get() = `%s`, want `%s`", writer.Body.String(), testCase.wantBody)
+			}
+		})
+	}
+
+	share := &provider.Share{ID: "a1b2c3d4", Path: "/", MaxDownloads: 1, Downloads: 1}
+	crudApp.metadatas = append(crudApp.metadatas, share)
+
+	writer := httptest.NewRecorder()
+	crudApp.Get(writer, httptest.NewRequest(http.MethodGet, "/report.txt?version="+versions[0].ID, nil), provider.Request{Path: "/report.txt", Permissions: provider.PermissionEdit, Share: share})
+
+	if writer.Code != http.StatusGone {
+		t.Errorf("Get() = %d, want gone once share limit reached", writer.Code)
+	}
+}
+
+func TestRenameMovesVersions(t *testing.T) {
+	crudApp, _, renderer := newTestApp(t)
+
+	uploadOverwrite(t, crudApp, renderer, "first")
+	uploadOverwrite(t, crudApp, renderer, "second")
+
+	writer := httptest.NewRecorder()
+	crudApp.Rename(writer, newFormRequest("/", url.Values{"name": {"report.txt"}, "newName": {"summary.txt"}}), provider.Request{Path: "/", Permissions: provider.PermissionEdit})
+
+	if writer.Code != http.StatusFound || renderer.err != nil {
+		t.Fatalf("Rename() = %d, `%v`", writer.Code, renderer.err)
+	}
+
+	if versions, err := crudApp.listVersions("/summary.txt"); err != nil || len(versions) != 1 {
+		t.Errorf("listVersions() = (%+v, `%v`), want versions following file", versions, err)
+	}
+
+	if versions, err := crudApp.listVersions("/report.txt"); err != nil || len(versions) != 0 {
+		t.Errorf("listVersions() = (%+v, `%v`), want no version left on old name", versions, err)
+	}
+}
+
+func TestDeleteTrashesVersions(t *testing.T) {
+	crudApp, _, renderer := newTestApp(t)
+
+	uploadOverwrite(t, crudApp, renderer, "first")
+	uploadOverwrite(t, crudApp, renderer, "second")
+
+	item := deleteToTrash(t, crudApp, renderer, "report.txt")
+
+	if versions, err := crudApp.listVersions("/report.txt"); err != nil || len(versions) != 0 {
+		t.Errorf("listVersions() = (%+v, `%v`), want no version left on deleted file", versions, err)
+	}
+
+	uploadOverwrite(t, crudApp, renderer, "unrelated")
+
+	if versions, err := crudApp.listVersions("/report.txt"); err != nil || len(versions) != 0 {
+		t.Errorf("listVersions() = (%+v, `%v`), want new file without history of deleted one", versions, err)
+	}
+
+	crudApp.RestoreTrash(httptest.NewRecorder(), newFormRequest("/", url.Values{"id": {item.ID}, "conflict": {conflictRename}}), provider.Request{Path: "/", Permissions: provider.PermissionAll, Admin: true})
+	if renderer.err != nil {
+		t.Fatalf("RestoreTrash() = `%v`", renderer.err)
+	}
+
+	if versions, err := crudApp.listVersions("/report_1.txt"); err != nil || len(versions) != 1 {
+		t.Errorf("listVersions() = (%+v, `%v`), want versions restored along file", versions, err)
+	}
+}
+
+func TestDeleteRemovesVersions(t *testing.T) {
+	crudApp, _, renderer := newTestApp(t)
+	crudApp.trashRetention = 0
+
+	uploadOverwrite(t, crudApp, renderer, "first")
+	uploadOverwrite(t, crudApp, renderer, "second")
+
+	writer := httptest.NewRecorder()
+	crudApp.Delete(writer, newFormRequest("/", url.Values{"name": {"report.txt"}}), provider.Request{Path: "/", Permissions: provider.PermissionEdit})
+
+	if writer.Code != http.StatusFound || renderer.err != nil {
+		t.Fatalf("Delete() = %d, `%v`", writer.Code, renderer.err)
+	}
+
+	uploadOverwrite(t, crudApp, renderer, "unrelated")
+
+	if versions, err := crudApp.listVersions("/report.txt"); err != nil || len(versions) != 0 {
+		t.Errorf("listVersions() = (%+v, `%v`), want versions deleted along file", versions, err)
+	}
+}
+
+func TestPurgeVersions(t *testing.T) {
+	crudApp, storage, _ := newTestApp(t)
+
+	expired := strconv.FormatInt(time.Now().Add(-crudApp.versionAge-time.Minute).UnixNano(), 10)
+	recent := strconv.FormatInt(time.Now().UnixNano(), 10)
+
+	for _, id := range []string{expired, recent} {
+		if err := storage.CreateDir(getVersionsDir("/photos/beach.jpg")); err != nil {
+			t.Fatalf("CreateDir() = %s", err)
+		}
+
+		if err := storage.Store(getVersionPath("/photos/beach.jpg", id), ioutil.NopCloser(strings.NewReader(id))); err != nil {
+			t.Fatalf("Store() = %s", err)
+		}
+	}
+
+	crudApp.purgeVersions()
+
+	if versions, err := crudApp.listVersions("/photos/beach.jpg"); err != nil || len(versions) != 1 || versions[0].ID != recent {
+		t.Errorf("purgeVersions() = (%+v, `%v`), want only recent version kept", versions, err)
+	}
+}
@@ -0,0 +1,79 @@
+package fibr
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path"
+
+	"github.com/ViBiOh/auth/v2/pkg/model"
+	"github.com/ViBiOh/fibr/pkg/provider"
+	"github.com/ViBiOh/httputils/v3/pkg/logger"
+)
+
+var (
+	aclFilename = path.Join(provider.MetadataDirectoryName, provider.ACLFilename)
+)
+
+func loadACL(storage provider.Storage) (provider.ACL, error) {
+	file, err := storage.ReaderFrom(aclFilename)
+	if err != nil {
+		if provider.IsNotExist(err) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	defer func() {
+		if err := file.Close(); err != nil {
+			logger.Error("unable to close access control list: %s", err)
+		}
+	}()
+
+	rawACL, err := ioutil.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var acl provider.ACL
+	if err := json.Unmarshal(rawACL, &acl); err != nil {
+		return nil, fmt.Errorf("unable to parse access control list: %w", err)
+	}
+
+	return acl, nil
+}
+
+func (a app) isRuleApplicable(r *http.Request, user model.User, rule provider.Rule) bool {
+	for _, login := range rule.Users {
+		if login == user.Login {
+			return true
+		}
+	}
+
+	for _, profile := range rule.Profiles {
+		if a.loginApp.HasProfile(r.Context(), user, profile) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// getACL gives rules applying to user, on top of its base permission given for the whole storage
+func (a app) getACL(r *http.Request, user model.User, base provider.Permission) provider.ACL {
+	var acl provider.ACL
+
+	for _, rule := range a.acl {
+		if a.isRuleApplicable(r, user, rule) {
+			acl = append(acl, rule)
+		}
+	}
+
+	if len(acl) == 0 {
+		return nil
+	}
+
+	return append(provider.ACL{{Path: "/", Permissions: base}}, acl...)
+}
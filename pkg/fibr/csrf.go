@@ -0,0 +1,49 @@
+package fibr
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/ViBiOh/fibr/pkg/provider"
+)
+
+func isBearerRequest(r *http.Request) bool {
+	return strings.HasPrefix(r.Header.Get("Authorization"), bearerPrefix)
+}
+
+// isScriptRequest checks if request comes from a script with Basic Auth credentials: no browser sends a mutation without Origin, Referer nor cookie
+func isScriptRequest(r *http.Request) bool {
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		return false
+	}
+
+	if !strings.HasPrefix(r.Header.Get("Authorization"), basicPrefix) {
+		return false
+	}
+
+	return len(r.Header.Get("Origin")) == 0 && len(r.Header.Get("Referer")) == 0 && len(r.Header.Get("Cookie")) == 0
+}
+
+// getCSRFToken gives anti-CSRF token of browser, issuing one if needed. Token is submitted along forms and compared with cookie.
+func getCSRFToken(w http.ResponseWriter, r *http.Request) (string, error) {
+	if cookie, err := r.Cookie(provider.CSRFCookieName); err == nil && provider.IsValidCSRFToken(cookie.Value) {
+		return cookie.Value, nil
+	}
+
+	token, err := provider.NewCSRFToken()
+	if err != nil {
+		return "", fmt.Errorf("unable to generate anti-CSRF token: %w", err)
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     provider.CSRFCookieName,
+		Value:    token,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https",
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	return token, nil
+}
@@ -2,58 +2,149 @@ package fibr
 
 import (
 	"errors"
+	"flag"
 	"fmt"
 	"net/http"
+	"path"
 	"strings"
+	"time"
 
 	"github.com/ViBiOh/auth/v2/pkg/auth"
 	"github.com/ViBiOh/auth/v2/pkg/ident"
 	authMiddleware "github.com/ViBiOh/auth/v2/pkg/middleware"
 	"github.com/ViBiOh/fibr/pkg/crud"
+	"github.com/ViBiOh/fibr/pkg/oidc"
 	"github.com/ViBiOh/fibr/pkg/provider"
 	"github.com/ViBiOh/fibr/pkg/renderer"
+	"github.com/ViBiOh/fibr/pkg/throttle"
+	"github.com/ViBiOh/fibr/pkg/webdav"
+	"github.com/ViBiOh/httputils/v3/pkg/flags"
 	"github.com/ViBiOh/httputils/v3/pkg/httperror"
 	"github.com/ViBiOh/httputils/v3/pkg/query"
 )
 
+const (
+	adminProfile  = "admin"
+	editorProfile = "editor"
+
+	bearerPrefix = "Bearer "
+	basicPrefix  = "Basic "
+)
+
 // App of package
 type App interface {
 	Handler() http.Handler
 }
 
+// Config of package
+type Config struct {
+	homes *string
+}
+
 type app struct {
+	homes map[uint64]string
+	acl   provider.ACL
+
 	loginApp    authMiddleware.App
+	oidcApp     oidc.App
 	crudApp     crud.App
 	rendererApp renderer.App
+	webdavApp   webdav.App
+	throttleApp throttle.App
+}
+
+// Flags adds flags for configuring package
+func Flags(fs *flag.FlagSet, prefix string) Config {
+	return Config{
+		homes: flags.New(prefix, "fibr").Name("Homes").Default("").Label("Users home directory in the form 'id:/path,id2:/path2'").ToString(fs),
+	}
 }
 
 // New creates new App from Config
-func New(crudApp crud.App, rendererApp renderer.App, loginApp authMiddleware.App) App {
+func New(config Config, storage provider.Storage, crudApp crud.App, rendererApp renderer.App, webdavApp webdav.App, loginApp authMiddleware.App, oidcApp oidc.App, throttleApp throttle.App) (App, error) {
+	homes, err := parseHomes(*config.homes)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, home := range homes {
+		if err := storage.CreateDir(home); err != nil {
+			return nil, fmt.Errorf("unable to create home directory %s: %w", home, err)
+		}
+	}
+
+	acl, err := loadACL(storage)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load access control list: %w", err)
+	}
+
 	return &app{
+		homes:       homes,
+		acl:         acl,
 		crudApp:     crudApp,
 		rendererApp: rendererApp,
+		webdavApp:   webdavApp,
 		loginApp:    loginApp,
-	}
+		oidcApp:     oidcApp,
+		throttleApp: throttleApp,
+	}, nil
 }
 
-func (a app) parseShare(request *provider.Request, authorizationHeader string) error {
+func (a app) parseShare(request *provider.Request, authorizationHeader, ip string) error {
 	share := a.crudApp.GetShare(request.Path)
 	if share == nil {
 		return nil
 	}
 
-	if err := share.CheckPassword(authorizationHeader); err != nil {
+	if share.IsExpired(time.Now()) {
+		return provider.ErrShareExpired
+	}
+
+	if err := a.checkSharePassword(share, authorizationHeader, ip); err != nil {
 		return err
 	}
 
 	request.Share = share
-	request.CanEdit = share.Edit
+	request.Permissions = share.Permissions
 	request.Path = strings.TrimPrefix(request.Path, fmt.Sprintf("/%s", share.ID))
 
 	return nil
 }
 
+// applyACL sets permissions of request from its access control list, hiding path if needed
+func applyACL(request *provider.Request) *provider.Error {
+	pathname := request.GetFilepath("")
+
+	if !request.IsVisible(pathname) {
+		return provider.NewError(http.StatusNotFound, errors.New("no such file or directory"))
+	}
+
+	request.Permissions = request.PermissionOn(pathname)
+	return nil
+}
+
+func (a app) parseToken(request *provider.Request, secret, ip string) *provider.Error {
+	if err := a.checkThrottle(throttle.IPKey(ip)); err != nil {
+		return provider.NewError(http.StatusTooManyRequests, err)
+	}
+
+	token := a.crudApp.GetToken(strings.TrimSpace(secret))
+	if token == nil {
+		a.failAuthentication(throttle.KindToken, throttle.IPKey(ip))
+		return provider.NewError(http.StatusUnauthorized, errors.New("invalid or expired token"))
+	}
+
+	request.User = token.Owner
+	request.ACL = token.ACL()
+
+	return applyACL(request)
+}
+
 func convertAuthenticationError(err error) *provider.Error {
+	if isThrottled(err) {
+		return provider.NewError(http.StatusTooManyRequests, err)
+	}
+
 	if errors.Is(err, auth.ErrForbidden) {
 		return provider.NewError(http.StatusForbidden, errors.New("you're not authorized to speak to me"))
 	}
@@ -67,42 +158,121 @@ func convertAuthenticationError(err error) *provider.Error {
 
 func (a app) parseRequest(r *http.Request) (provider.Request, *provider.Error) {
 	request := provider.Request{
-		Path:     r.URL.Path,
-		CanEdit:  false,
-		CanShare: false,
-		Display:  r.URL.Query().Get("d"),
+		Path:    r.URL.Path,
+		Display: r.URL.Query().Get("d"),
+		JSON:    provider.IsJSONRequest(r),
 	}
 
-	if err := a.parseShare(&request, r.Header.Get("Authorization")); err != nil {
+	ip := a.getIP(r)
+	authorization := r.Header.Get("Authorization")
+
+	if err := a.parseShare(&request, authorization, ip); err != nil {
+		if errors.Is(err, provider.ErrShareExpired) {
+			return request, provider.NewError(http.StatusGone, err)
+		}
+
+		if isThrottled(err) {
+			return request, provider.NewError(http.StatusTooManyRequests, err)
+		}
+
 		return request, provider.NewError(http.StatusUnauthorized, err)
 	}
 
+	if request.UploadOnly() && !isUploadOnlyAllowed(r) {
+		return request, provider.NewError(http.StatusForbidden, errors.New("this share only accepts uploads"))
+	}
+
 	if request.Share != nil {
 		return request, nil
 	}
 
 	if a.loginApp == nil {
-		request.CanEdit = true
-		request.CanShare = true
+		request.Permissions = provider.PermissionAll
+		request.Admin = true
 		return request, nil
 	}
 
+	if isBearerRequest(r) {
+		return request, a.parseToken(&request, strings.TrimPrefix(authorization, bearerPrefix), ip)
+	}
+
+	loginKeys := getLoginKeys(r, ip)
+
+	if len(authorization) != 0 {
+		if err := a.checkThrottle(loginKeys...); err != nil {
+			return request, convertAuthenticationError(err)
+		}
+	}
+
+	if a.isOIDCEnabled() {
+		r = a.oidcApp.WithSession(r)
+	}
+
 	_, user, err := a.loginApp.IsAuthenticated(r, "")
 	if err != nil {
+		if errors.Is(err, ident.ErrInvalidCredentials) {
+			a.failAuthentication(throttle.KindLogin, loginKeys...)
+		}
+
 		return request, convertAuthenticationError(err)
 	}
 
-	if a.loginApp.HasProfile(r.Context(), user, "admin") {
-		request.CanEdit = true
-		request.CanShare = true
+	// IP failures are kept, otherwise any valid account would reset lockout between guesses on others
+	a.succeedAuthentication(loginKeys[1:]...)
+
+	request.User = user.Login
+
+	home := a.homes[user.ID]
+	if a.isOIDCEnabled() && oidc.IsUser(user) {
+		home = ""
+	}
+
+	switch {
+	case a.loginApp.HasProfile(r.Context(), user, adminProfile):
+		request.Permissions = provider.PermissionAll
+		request.Admin = true
+	case a.loginApp.HasProfile(r.Context(), user, editorProfile):
+		request.Permissions = provider.PermissionEdit | provider.PermissionShare
+		request.Home = home
+	default:
+		request.Permissions = provider.PermissionRead
+		request.Home = home
+	}
+
+	if !request.Admin {
+		if request.ACL = a.getACL(r, user, request.Permissions); request.ACL != nil {
+			return request, applyACL(&request)
+		}
 	}
 
 	return request, nil
 }
 
+func (a app) isOIDCEnabled() bool {
+	return a.loginApp != nil && a.oidcApp != nil && a.oidcApp.Enabled()
+}
+
+// isOIDCLoginNeeded checks if an unauthenticated browser request should be redirected to OpenID Connect login instead of Basic Auth prompt
+func (a app) isOIDCLoginNeeded(r *http.Request, request provider.Request, err *provider.Error) bool {
+	if !a.isOIDCEnabled() || err.Status != http.StatusUnauthorized || request.JSON {
+		return false
+	}
+
+	if r.Method != http.MethodGet || len(r.Header.Get("Authorization")) != 0 {
+		return false
+	}
+
+	return a.crudApp.GetShare(r.URL.Path) == nil
+}
+
 func (a app) handleRequest(w http.ResponseWriter, r *http.Request, request provider.Request) {
+	if crud.IsTusRequest(r) {
+		a.crudApp.Tus(w, r, request)
+		return
+	}
+
 	switch r.Method {
-	case http.MethodGet:
+	case http.MethodGet, http.MethodHead:
 		a.crudApp.Get(w, r, request)
 	case http.MethodPost:
 		a.crudApp.Post(w, r, request)
@@ -117,11 +287,54 @@ func (a app) handleRequest(w http.ResponseWriter, r *http.Request, request provi
 	}
 }
 
+func (a app) isWebDAVRequest(r *http.Request) bool {
+	if a.webdavApp == nil || !a.webdavApp.Enabled() {
+		return false
+	}
+
+	prefix := a.webdavApp.Prefix()
+	return r.URL.Path == prefix || strings.HasPrefix(r.URL.Path, fmt.Sprintf("%s/", prefix))
+}
+
+func (a app) handleWebDAV(w http.ResponseWriter, r *http.Request) {
+	davRequest := r.Clone(r.Context())
+	davRequest.URL.Path = path.Join("/", strings.TrimPrefix(r.URL.Path, a.webdavApp.Prefix()))
+
+	request, err := a.parseRequest(davRequest)
+	if err != nil {
+		setRetryAfter(w, err)
+		a.rendererApp.Error(w, request, err)
+		return
+	}
+
+	if request.UploadOnly() {
+		a.rendererApp.Error(w, request, provider.NewError(http.StatusForbidden, errors.New("upload-only share can't be mounted")))
+		return
+	}
+
+	if request.Share != nil && request.Share.IsLimited() {
+		a.rendererApp.Error(w, request, provider.NewError(http.StatusForbidden, errors.New("share with download limits can't be mounted")))
+		return
+	}
+
+	a.webdavApp.Handle(w, r, request)
+}
+
 // Handler for request. Should be use with net/http
 func (a app) Handler() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a.isWebDAVRequest(r) {
+			a.handleWebDAV(w, r)
+			return
+		}
+
+		if a.isOIDCEnabled() && a.oidcApp.IsHandled(r) {
+			a.oidcApp.Handler().ServeHTTP(w, r)
+			return
+		}
+
 		if !isMethodAllowed(r) {
-			a.rendererApp.Error(w, provider.Request{}, provider.NewError(http.StatusMethodNotAllowed, errors.New("you lack of method for calling me")))
+			a.rendererApp.Error(w, provider.Request{JSON: provider.IsJSONRequest(r)}, provider.NewError(http.StatusMethodNotAllowed, errors.New("you lack of method for calling me")))
 			return
 		}
 
@@ -136,10 +349,26 @@ func (a app) Handler() http.Handler {
 
 		request, err := a.parseRequest(r)
 		if err != nil {
+			if a.isOIDCLoginNeeded(r, request, err) {
+				http.Redirect(w, r, a.oidcApp.LoginURL(r.URL.RequestURI()), http.StatusFound)
+				return
+			}
+
+			setRetryAfter(w, err)
 			a.rendererApp.Error(w, request, err)
 			return
 		}
 
+		if !isBearerRequest(r) && !isScriptRequest(r) {
+			csrf, err := getCSRFToken(w, r)
+			if err != nil {
+				a.rendererApp.Error(w, request, provider.NewError(http.StatusInternalServerError, err))
+				return
+			}
+
+			request.CSRF = csrf
+		}
+
 		a.handleRequest(w, r, request)
 	})
 }
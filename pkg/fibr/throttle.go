@@ -0,0 +1,86 @@
+package fibr
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+
+	"github.com/ViBiOh/fibr/pkg/provider"
+	"github.com/ViBiOh/fibr/pkg/throttle"
+)
+
+func (a app) getIP(r *http.Request) string {
+	if a.throttleApp == nil {
+		return ""
+	}
+
+	return a.throttleApp.GetIP(r)
+}
+
+func (a app) checkThrottle(keys ...string) error {
+	if a.throttleApp == nil {
+		return nil
+	}
+
+	return a.throttleApp.Check(keys...)
+}
+
+func (a app) failAuthentication(kind string, keys ...string) {
+	if a.throttleApp != nil {
+		a.throttleApp.Fail(kind, keys...)
+	}
+}
+
+func (a app) succeedAuthentication(keys ...string) {
+	if a.throttleApp != nil {
+		a.throttleApp.Success(keys...)
+	}
+}
+
+// getLoginKeys gives keys throttling a login attempt of given request, per IP and per login from this IP.
+// Nothing is counted per login alone, otherwise anyone could lock a user out by failing on purpose
+func getLoginKeys(r *http.Request, ip string) []string {
+	login, _, ok := r.BasicAuth()
+	if !ok || len(login) == 0 {
+		return []string{throttle.IPKey(ip)}
+	}
+
+	return []string{throttle.IPKey(ip), throttle.IPLoginKey(ip, login)}
+}
+
+// checkSharePassword verifies password of share, refusing attempts from a locked IP, never locking the share for everyone
+func (a app) checkSharePassword(share *provider.Share, authorizationHeader, ip string) error {
+	if len(share.Password) == 0 || len(authorizationHeader) == 0 {
+		return share.CheckPassword(authorizationHeader)
+	}
+
+	keys := []string{throttle.IPKey(ip), throttle.IPShareKey(ip, share.ID)}
+
+	if err := a.checkThrottle(keys...); err != nil {
+		return err
+	}
+
+	if err := share.CheckPassword(authorizationHeader); err != nil {
+		a.failAuthentication(throttle.KindShare, keys...)
+		return err
+	}
+
+	a.succeedAuthentication(throttle.IPShareKey(ip, share.ID))
+	return nil
+}
+
+func isThrottled(err error) bool {
+	var locked throttle.LockedError
+	return errors.As(err, &locked)
+}
+
+// setRetryAfter adds header telling when a throttled client can retry
+func setRetryAfter(w http.ResponseWriter, err *provider.Error) {
+	var locked throttle.LockedError
+	if err.Status != http.StatusTooManyRequests || !errors.As(err.Err, &locked) {
+		return
+	}
+
+	w.Header().Set("Retry-After", fmt.Sprintf("%.0f", math.Ceil(locked.RetryAfter.Seconds())))
+}
@@ -1,7 +1,13 @@
 package fibr
 
 import (
+	"fmt"
 	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/ViBiOh/fibr/pkg/crud"
 )
 
 func isMethodAllowed(r *http.Request) bool {
@@ -15,8 +21,54 @@ func isMethodAllowed(r *http.Request) bool {
 	case http.MethodPatch:
 		fallthrough
 	case http.MethodDelete:
+		fallthrough
+	case http.MethodHead:
 		return true
 	default:
 		return false
 	}
 }
+
+// isUploadOnlyAllowed checks if request is allowed on a drop box share: displaying upload page or uploading
+func isUploadOnlyAllowed(r *http.Request) bool {
+	if crud.IsTusRequest(r) {
+		return true
+	}
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		return true
+	case http.MethodPost:
+		return strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data")
+	default:
+		return false
+	}
+}
+
+// parseHomes parses home directory of users, in the form `id:/path,id2:/path2`
+func parseHomes(value string) (map[uint64]string, error) {
+	homes := make(map[uint64]string)
+
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if len(entry) == 0 {
+			continue
+		}
+
+		parts := strings.SplitN(entry, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid home format for `%s`", entry)
+		}
+
+		id, err := strconv.ParseUint(strings.TrimSpace(parts[0]), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid user id for home `%s`: %w", entry, err)
+		}
+
+		if home := path.Join("/", strings.TrimSpace(parts[1])); home != "/" {
+			homes[id] = home
+		}
+	}
+
+	return homes, nil
+}
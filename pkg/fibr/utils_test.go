@@ -1,8 +1,11 @@
 package fibr
 
 import (
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -32,3 +35,113 @@ func TestIsMethodAllowed(t *testing.T) {
 		})
 	}
 }
+
+func TestIsUploadOnlyAllowed(t *testing.T) {
+	multipartRequest := httptest.NewRequest(http.MethodPost, "/", nil)
+	multipartRequest.Header.Set("Content-Type", "multipart/form-data; boundary=fibr")
+
+	tusRequest := httptest.NewRequest(http.MethodPatch, "/", nil)
+	tusRequest.Header.Set("Tus-Resumable", "1.0.0")
+
+	var cases = []struct {
+		intention string
+		input     *http.Request
+		want      bool
+	}{
+		{
+			"display",
+			httptest.NewRequest(http.MethodGet, "/", nil),
+			true,
+		},
+		{
+			"upload",
+			multipartRequest,
+			true,
+		},
+		{
+			"tus",
+			tusRequest,
+			true,
+		},
+		{
+			"form",
+			httptest.NewRequest(http.MethodPost, "/", nil),
+			false,
+		},
+		{
+			"webdav",
+			httptest.NewRequest(http.MethodPut, "/", nil),
+			false,
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.intention, func(t *testing.T) {
+			if result := isUploadOnlyAllowed(testCase.input); result != testCase.want {
+				t.Errorf("isUploadOnlyAllowed(%#v) = %#v, want %#v", testCase.input, result, testCase.want)
+			}
+		})
+	}
+}
+
+func TestParseHomes(t *testing.T) {
+	var cases = []struct {
+		intention string
+		input     string
+		want      map[uint64]string
+		wantErr   error
+	}{
+		{
+			"empty",
+			"",
+			map[uint64]string{},
+			nil,
+		},
+		{
+			"multiple",
+			"1:/home/alice, 2:users/bob/",
+			map[uint64]string{1: "/home/alice", 2: "/users/bob"},
+			nil,
+		},
+		{
+			"root",
+			"1:/",
+			map[uint64]string{},
+			nil,
+		},
+		{
+			"invalid format",
+			"1",
+			nil,
+			errors.New("invalid home format for `1`"),
+		},
+		{
+			"invalid id",
+			"alice:/home/alice",
+			nil,
+			errors.New("invalid user id for home `alice:/home/alice`"),
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.intention, func(t *testing.T) {
+			result, err := parseHomes(testCase.input)
+
+			failed := false
+
+			if err == nil && testCase.wantErr != nil {
+				failed = true
+			} else if err != nil && testCase.wantErr == nil {
+				failed = true
+			} else if err != nil && !strings.Contains(err.Error(), testCase.wantErr.Error()) {
+				failed = true
+			} else if !reflect.DeepEqual(result, testCase.want) {
+				failed = true
+			}
+
+			if failed {
+				t.Errorf("parseHomes() = (%+v, `%s`), want (%+v, `%s`)", result, err, testCase.want, testCase.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package filesystem
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ViBiOh/httputils/v3/pkg/logger"
+)
+
+const (
+	temporaryPrefix = ".fibr-tmp-"
+)
+
+// atomicFile writes to a temporary sibling of its destination, moved in place on Close,
+// so readers never see a partially written file and a failed write keeps previous content
+type atomicFile struct {
+	*os.File
+	pathname string
+	failed   bool
+}
+
+func (f *atomicFile) Write(content []byte) (int, error) {
+	n, err := f.File.Write(content)
+	if err != nil {
+		f.failed = true
+	}
+
+	return n, err
+}
+
+func (f *atomicFile) Close() error {
+	if err := f.File.Close(); err != nil {
+		f.remove()
+		return err
+	}
+
+	if f.failed {
+		f.remove()
+		return nil
+	}
+
+	if err := os.Rename(f.Name(), f.pathname); err != nil {
+		f.remove()
+		return err
+	}
+
+	return nil
+}
+
+// Abort discards written content, previous content being kept
+func (f *atomicFile) Abort() error {
+	f.failed = true
+
+	return f.Close()
+}
+
+func (f *atomicFile) discard() {
+	if err := f.Abort(); err != nil {
+		logger.Error("unable to close temporary file: %s", err)
+	}
+}
+
+// removeTemporaryFiles deletes temporary files left by writes interrupted by a crash or a restart, before given date
+func (a *app) removeTemporaryFiles(before time.Time) {
+	err := filepath.Walk(a.rootDirectory, func(pathname string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || !strings.HasPrefix(info.Name(), temporaryPrefix) || !info.ModTime().Before(before) {
+			return nil
+		}
+
+		if err := os.Remove(pathname); err != nil {
+			logger.Error("unable to remove temporary file %s: %s", pathname, err)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		logger.Error("unable to remove temporary files: %s", err)
+	}
+}
+
+func (f *atomicFile) remove() {
+	if err := os.Remove(f.Name()); err != nil && !os.IsNotExist(err) {
+		logger.Error("unable to remove temporary file: %s", err)
+	}
+}
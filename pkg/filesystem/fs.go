@@ -11,6 +11,7 @@ import (
 	"path/filepath"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/ViBiOh/fibr/pkg/provider"
 	"github.com/ViBiOh/httputils/v3/pkg/flags"
@@ -60,10 +61,14 @@ func New(config Config) (provider.Storage, error) {
 
 	logger.Info("Serving file from %s", rootDirectory)
 
-	return &app{
+	app := &app{
 		rootDirectory: rootDirectory,
 		rootDirname:   info.Name(),
-	}, nil
+	}
+
+	go app.removeTemporaryFiles(time.Now())
+
+	return app, nil
 }
 
 func (a *app) SetIgnoreFn(ignoreFn func(provider.StorageItem) bool) {
@@ -102,26 +107,30 @@ func (a *app) List(pathname string) ([]provider.StorageItem, error) {
 	items := make([]provider.StorageItem, 0)
 	for _, file := range files {
 		item := convertToItem(a.getRelativePath(path.Join(fullpath, file.Name())), file)
-		if a.ignoreFn != nil && a.ignoreFn(item) {
+		if isTemporary(item) || (a.ignoreFn != nil && a.ignoreFn(item)) {
 			continue
 		}
 
 		items = append(items, item)
 	}
 
-	sort.Sort(ByHybridSort(items))
+	sort.Sort(provider.ByHybridSort(items))
 
 	return items, nil
 }
 
 // WriterTo opens writer for given pathname
-func (a *app) WriterTo(pathname string) (io.WriteCloser, error) {
+func (a *app) WriterTo(pathname string) (provider.WriteAborter, error) {
 	if err := a.checkPathname(pathname); err != nil {
 		return nil, convertError(err)
 	}
 
-	writer, err := a.getFile(pathname)
-	return writer, convertError(err)
+	writer, err := a.getWriter(pathname)
+	if err != nil {
+		return nil, convertError(err)
+	}
+
+	return writer, nil
 }
 
 // ReaderFrom reads content from given pathname
@@ -145,6 +154,10 @@ func (a *app) Walk(pathname string, walkFn func(provider.StorageItem, error) err
 		}
 
 		item := convertToItem(a.getRelativePath(path), info)
+		if isTemporary(item) {
+			return nil
+		}
+
 		if a.ignoreFn != nil && a.ignoreFn(item) {
 			if item.IsDir {
 				return filepath.SkipDir
@@ -171,25 +184,18 @@ func (a *app) Store(pathname string, content io.ReadCloser) error {
 		return convertError(err)
 	}
 
-	storageFile, err := a.getFile(pathname)
-	if storageFile != nil {
-		defer func() {
-			if err := storageFile.Close(); err != nil {
-				logger.Error("unable to close stored file: %s", err)
-			}
-		}()
-	}
-
+	storageFile, err := a.getWriter(pathname)
 	if err != nil {
 		return convertError(err)
 	}
 
 	copyBuffer := make([]byte, 32*1024)
 	if _, err = io.CopyBuffer(storageFile, content, copyBuffer); err != nil {
+		storageFile.discard()
 		return convertError(err)
 	}
 
-	return nil
+	return convertError(storageFile.Close())
 }
 
 // Rename file or directory from storage
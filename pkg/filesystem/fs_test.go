@@ -0,0 +1,189 @@
+package filesystem
+
+import (
+	"errors"
+	"flag"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ViBiOh/fibr/pkg/provider"
+)
+
+type failingReader struct{}
+
+func (failingReader) Read(p []byte) (int, error) {
+	return copy(p, "partial"), errors.New("connection reset")
+}
+
+func (failingReader) Close() error {
+	return nil
+}
+
+func newTestStorage(t *testing.T) provider.Storage {
+	directory, err := ioutil.TempDir("", "fibr")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %s", err)
+	}
+
+	t.Cleanup(func() {
+		if err := os.RemoveAll(directory); err != nil {
+			t.Errorf("unable to remove temp dir: %s", err)
+		}
+	})
+
+	fs := flag.NewFlagSet("filesystem-test", flag.ContinueOnError)
+	config := Flags(fs, "")
+
+	if err := fs.Parse([]string{"-directory", directory}); err != nil {
+		t.Fatalf("unable to parse flags: %s", err)
+	}
+
+	storage, err := New(config)
+	if err != nil {
+		t.Fatalf("unable to create storage: %s", err)
+	}
+
+	return storage
+}
+
+func readContent(t *testing.T, storage provider.Storage, pathname string) string {
+	reader, err := storage.ReaderFrom(pathname)
+	if err != nil {
+		t.Fatalf("ReaderFrom() = %s", err)
+	}
+	defer reader.Close()
+
+	content, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("ReadAll() = %s", err)
+	}
+
+	return string(content)
+}
+
+func TestStore(t *testing.T) {
+	storage := newTestStorage(t)
+
+	if err := storage.Store("/report.txt", ioutil.NopCloser(strings.NewReader("first version"))); err != nil {
+		t.Fatalf("Store() = %s", err)
+	}
+
+	if err := storage.Store("/report.txt", failingReader{}); err == nil {
+		t.Errorf("Store() = nil, want error")
+	}
+
+	if content := readContent(t, storage, "/report.txt"); content != "first version" {
+		t.Errorf("Store() = `%s`, want previous version kept", content)
+	}
+
+	items, err := storage.List("/")
+	if err != nil {
+		t.Fatalf("List() = %s", err)
+	}
+
+	if len(items) != 1 || items[0].Name != "report.txt" {
+		t.Errorf("List() = %+v, want no temporary file left", items)
+	}
+}
+
+func TestWriterTo(t *testing.T) {
+	storage := newTestStorage(t)
+
+	if err := storage.Store("/report.txt", ioutil.NopCloser(strings.NewReader("first version"))); err != nil {
+		t.Fatalf("Store() = %s", err)
+	}
+
+	writer, err := storage.WriterTo("/report.txt")
+	if err != nil {
+		t.Fatalf("WriterTo() = %s", err)
+	}
+
+	if _, err := writer.Write([]byte("second version")); err != nil {
+		t.Fatalf("Write() = %s", err)
+	}
+
+	if content := readContent(t, storage, "/report.txt"); content != "first version" {
+		t.Errorf("WriterTo() = `%s`, want previous version until closed", content)
+	}
+
+	if items, err := storage.List("/"); err != nil || len(items) != 1 {
+		t.Errorf("List() = (%+v, `%v`), want temporary file hidden", items, err)
+	}
+
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Close() = %s", err)
+	}
+
+	if content := readContent(t, storage, "/report.txt"); content != "second version" {
+		t.Errorf("WriterTo() = `%s`, want new version once closed", content)
+	}
+}
+
+func TestWriterToAbort(t *testing.T) {
+	storage := newTestStorage(t)
+
+	if err := storage.Store("/report.txt", ioutil.NopCloser(strings.NewReader("first version"))); err != nil {
+		t.Fatalf("Store() = %s", err)
+	}
+
+	writer, err := storage.WriterTo("/report.txt")
+	if err != nil {
+		t.Fatalf("WriterTo() = %s", err)
+	}
+
+	if _, err := writer.Write([]byte("truncat")); err != nil {
+		t.Fatalf("Write() = %s", err)
+	}
+
+	if err := writer.Abort(); err != nil {
+		t.Fatalf("Abort() = %s", err)
+	}
+
+	if content := readContent(t, storage, "/report.txt"); content != "first version" {
+		t.Errorf("Abort() = `%s`, want previous version kept", content)
+	}
+
+	if items, err := storage.List("/"); err != nil || len(items) != 1 {
+		t.Errorf("List() = (%+v, `%v`), want no temporary file left", items, err)
+	}
+}
+
+func TestRemoveTemporaryFiles(t *testing.T) {
+	storage := newTestStorage(t)
+	instance := storage.(*app)
+
+	if err := storage.CreateDir("/photos"); err != nil {
+		t.Fatalf("CreateDir() = %s", err)
+	}
+
+	orphan, err := ioutil.TempFile(instance.getFullPath("/photos"), temporaryPrefix)
+	if err != nil {
+		t.Fatalf("TempFile() = %s", err)
+	}
+
+	if err := orphan.Close(); err != nil {
+		t.Fatalf("Close() = %s", err)
+	}
+
+	writer, err := storage.WriterTo("/photos/beach.jpg")
+	if err != nil {
+		t.Fatalf("WriterTo() = %s", err)
+	}
+
+	if err := os.Chtimes(orphan.Name(), time.Now().Add(-time.Hour), time.Now().Add(-time.Hour)); err != nil {
+		t.Fatalf("Chtimes() = %s", err)
+	}
+
+	instance.removeTemporaryFiles(time.Now().Add(-time.Minute))
+
+	if _, err := os.Stat(orphan.Name()); !os.IsNotExist(err) {
+		t.Errorf("Stat() = `%v`, want orphaned temporary file removed", err)
+	}
+
+	if err := writer.Close(); err != nil {
+		t.Errorf("Close() = `%s`, want ongoing write kept", err)
+	}
+}
@@ -1,7 +1,7 @@
 package filesystem
 
 import (
-	"io"
+	"io/ioutil"
 	"os"
 	"path"
 	"strings"
@@ -25,8 +25,22 @@ func (a app) getRelativePath(pathname string) string {
 	return strings.TrimPrefix(pathname, a.rootDirectory)
 }
 
-func (a app) getFile(filename string) (io.WriteCloser, error) {
-	return os.OpenFile(a.getFullPath(filename), os.O_RDWR|os.O_CREATE|os.O_TRUNC, getMode(filename))
+func (a app) getWriter(filename string) (*atomicFile, error) {
+	fullpath := a.getFullPath(filename)
+
+	file, err := ioutil.TempFile(path.Dir(fullpath), temporaryPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	return &atomicFile{
+		File:     file,
+		pathname: fullpath,
+	}, nil
+}
+
+func isTemporary(item provider.StorageItem) bool {
+	return !item.IsDir && strings.HasPrefix(item.Name, temporaryPrefix)
 }
 
 func getMode(name string) os.FileMode {
@@ -43,6 +57,7 @@ func convertToItem(pathname string, info os.FileInfo) provider.StorageItem {
 		Pathname: pathname,
 		IsDir:    info.IsDir(),
 		Date:     info.ModTime(),
+		Size:     info.Size(),
 		Info:     info,
 	}
 }
@@ -0,0 +1,351 @@
+package memory
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ViBiOh/fibr/pkg/provider"
+)
+
+var (
+	// ErrRelativePath occurs when path is relative (contains ".."")
+	ErrRelativePath = errors.New("pathname contains relatives paths")
+
+	// ErrNotDir occurs when a directory is expected
+	ErrNotDir = errors.New("not a directory")
+
+	// ErrIsDir occurs when a file is expected
+	ErrIsDir = errors.New("is a directory")
+)
+
+type node struct {
+	content []byte
+	date    time.Time
+	isDir   bool
+}
+
+type app struct {
+	nodes map[string]node
+	mutex sync.RWMutex
+
+	ignoreFn func(provider.StorageItem) bool
+}
+
+// New creates new in-memory App
+func New() provider.Storage {
+	return &app{
+		nodes: map[string]node{
+			"/": {
+				isDir: true,
+				date:  time.Now(),
+			},
+		},
+	}
+}
+
+func (a *app) SetIgnoreFn(ignoreFn func(provider.StorageItem) bool) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	a.ignoreFn = ignoreFn
+}
+
+// Info provide metadata about given pathname
+func (a *app) Info(pathname string) (provider.StorageItem, error) {
+	if err := checkPathname(pathname); err != nil {
+		return provider.StorageItem{}, err
+	}
+
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	key := getKey(pathname)
+
+	item, ok := a.nodes[key]
+	if !ok {
+		return provider.StorageItem{}, errNotExist(pathname)
+	}
+
+	return convertToItem(key, item), nil
+}
+
+// List items in the storage
+func (a *app) List(pathname string) ([]provider.StorageItem, error) {
+	if err := checkPathname(pathname); err != nil {
+		return nil, err
+	}
+
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	key := getKey(pathname)
+
+	item, ok := a.nodes[key]
+	if !ok {
+		return nil, errNotExist(pathname)
+	}
+
+	if !item.isDir {
+		return nil, fmt.Errorf("%s: %w", pathname, ErrNotDir)
+	}
+
+	items := make([]provider.StorageItem, 0)
+	for _, child := range a.children(key) {
+		if a.ignoreFn != nil && a.ignoreFn(child) {
+			continue
+		}
+
+		items = append(items, child)
+	}
+
+	sort.Sort(provider.ByHybridSort(items))
+
+	return items, nil
+}
+
+// WriterTo opens writer for given pathname
+func (a *app) WriterTo(pathname string) (provider.WriteAborter, error) {
+	if err := checkPathname(pathname); err != nil {
+		return nil, err
+	}
+
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	if err := a.checkWritable(getKey(pathname)); err != nil {
+		return nil, err
+	}
+
+	return &writer{
+		storage:  a,
+		pathname: pathname,
+	}, nil
+}
+
+// ReaderFrom reads content from given pathname
+func (a *app) ReaderFrom(pathname string) (provider.ReadSeekerCloser, error) {
+	if err := checkPathname(pathname); err != nil {
+		return nil, err
+	}
+
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	item, ok := a.nodes[getKey(pathname)]
+	if !ok {
+		return nil, errNotExist(pathname)
+	}
+
+	if item.isDir {
+		return nil, fmt.Errorf("%s: %w", pathname, ErrIsDir)
+	}
+
+	return reader{bytes.NewReader(item.content)}, nil
+}
+
+// Walk browses item recursively
+func (a *app) Walk(pathname string, walkFn func(provider.StorageItem, error) error) error {
+	if err := checkPathname(pathname); err != nil {
+		return err
+	}
+
+	a.mutex.RLock()
+	key := getKey(pathname)
+	item, ok := a.nodes[key]
+	a.mutex.RUnlock()
+
+	if !ok {
+		return walkFn(provider.StorageItem{}, errNotExist(pathname))
+	}
+
+	return a.walk(convertToItem(key, item), walkFn)
+}
+
+func (a *app) walk(item provider.StorageItem, walkFn func(provider.StorageItem, error) error) error {
+	a.mutex.RLock()
+	ignoreFn := a.ignoreFn
+	a.mutex.RUnlock()
+
+	if ignoreFn != nil && ignoreFn(item) {
+		return nil
+	}
+
+	if err := walkFn(item, nil); err != nil || !item.IsDir {
+		return err
+	}
+
+	a.mutex.RLock()
+	children := a.children(getKey(item.Pathname))
+	a.mutex.RUnlock()
+
+	sort.Slice(children, func(i, j int) bool {
+		return children[i].Name < children[j].Name
+	})
+
+	for _, child := range children {
+		if err := a.walk(child, walkFn); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// CreateDir container in storage
+func (a *app) CreateDir(name string) error {
+	if err := checkPathname(name); err != nil {
+		return err
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	key := getKey(name)
+	for _, dirKey := range getParentKeys(key) {
+		item, ok := a.nodes[dirKey]
+		if !ok {
+			a.nodes[dirKey] = node{
+				isDir: true,
+				date:  time.Now(),
+			}
+		} else if !item.isDir {
+			return fmt.Errorf("%s: %w", dirKey, ErrNotDir)
+		}
+	}
+
+	return nil
+}
+
+// Store file to storage
+func (a *app) Store(pathname string, content io.ReadCloser) error {
+	if err := checkPathname(pathname); err != nil {
+		return err
+	}
+
+	payload, err := ioutil.ReadAll(content)
+	if err != nil {
+		return err
+	}
+
+	return a.store(pathname, payload)
+}
+
+// Rename file or directory from storage
+func (a *app) Rename(oldName, newName string) error {
+	if err := checkPathname(oldName); err != nil {
+		return err
+	}
+
+	if err := checkPathname(newName); err != nil {
+		return err
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	oldKey := getKey(oldName)
+	newKey := getKey(newName)
+
+	if _, ok := a.nodes[oldKey]; !ok {
+		return errNotExist(oldName)
+	}
+
+	if err := a.checkWritable(newKey); err != nil {
+		return err
+	}
+
+	if strings.HasPrefix(newKey, fmt.Sprintf("%s/", oldKey)) {
+		return fmt.Errorf("unable to move %s into itself", oldName)
+	}
+
+	moved := make(map[string]node)
+	for key, item := range a.nodes {
+		if key == oldKey || strings.HasPrefix(key, fmt.Sprintf("%s/", oldKey)) {
+			moved[path.Join(newKey, strings.TrimPrefix(key, oldKey))] = item
+			delete(a.nodes, key)
+		}
+	}
+
+	for key, item := range moved {
+		a.nodes[key] = item
+	}
+
+	return nil
+}
+
+// Remove file or directory from storage
+func (a *app) Remove(pathname string) error {
+	if err := checkPathname(pathname); err != nil {
+		return err
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	key := getKey(pathname)
+	if key == "/" {
+		return errors.New("unable to remove root directory")
+	}
+
+	for nodeKey := range a.nodes {
+		if nodeKey == key || strings.HasPrefix(nodeKey, fmt.Sprintf("%s/", key)) {
+			delete(a.nodes, nodeKey)
+		}
+	}
+
+	return nil
+}
+
+func (a *app) store(pathname string, content []byte) error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	key := getKey(pathname)
+	if err := a.checkWritable(key); err != nil {
+		return err
+	}
+
+	a.nodes[key] = node{
+		content: content,
+		date:    time.Now(),
+	}
+
+	return nil
+}
+
+func (a *app) checkWritable(key string) error {
+	if item, ok := a.nodes[key]; ok && item.isDir {
+		return fmt.Errorf("%s: %w", key, ErrIsDir)
+	}
+
+	parent, ok := a.nodes[path.Dir(key)]
+	if !ok {
+		return errNotExist(path.Dir(key))
+	}
+
+	if !parent.isDir {
+		return fmt.Errorf("%s: %w", path.Dir(key), ErrNotDir)
+	}
+
+	return nil
+}
+
+func (a *app) children(key string) []provider.StorageItem {
+	items := make([]provider.StorageItem, 0)
+
+	for nodeKey, item := range a.nodes {
+		if nodeKey != "/" && path.Dir(nodeKey) == key {
+			items = append(items, convertToItem(nodeKey, item))
+		}
+	}
+
+	return items
+}
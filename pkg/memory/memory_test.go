@@ -0,0 +1,234 @@
+package memory
+
+import (
+	"io"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ViBiOh/fibr/pkg/provider"
+)
+
+func store(t *testing.T, storage provider.Storage, pathname, content string) {
+	if err := storage.Store(pathname, ioutil.NopCloser(strings.NewReader(content))); err != nil {
+		t.Fatalf("unable to store %s: %s", pathname, err)
+	}
+}
+
+func walkedPathnames(t *testing.T, storage provider.Storage, pathname string) []string {
+	var walked []string
+
+	if err := storage.Walk(pathname, func(item provider.StorageItem, err error) error {
+		walked = append(walked, item.Pathname)
+		return err
+	}); err != nil {
+		t.Fatalf("Walk() = %s", err)
+	}
+
+	return walked
+}
+
+func TestGetParentKeys(t *testing.T) {
+	var cases = []struct {
+		intention string
+		input     string
+		want      []string
+	}{
+		{
+			"root",
+			"/",
+			[]string{"/"},
+		},
+		{
+			"nested",
+			"/photos/2020",
+			[]string{"/", "/photos", "/photos/2020"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.intention, func(t *testing.T) {
+			if got := getParentKeys(tc.input); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("getParentKeys() = %#v, want %#v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestStorage(t *testing.T) {
+	storage := New()
+	storage.SetIgnoreFn(func(item provider.StorageItem) bool {
+		return item.IsDir && item.Name == provider.MetadataDirectoryName
+	})
+
+	if err := storage.CreateDir("/photos/2020"); err != nil {
+		t.Fatalf("CreateDir() = %s", err)
+	}
+	if err := storage.CreateDir(provider.MetadataDirectoryName); err != nil {
+		t.Fatalf("CreateDir() = %s", err)
+	}
+	store(t, storage, "/readme.md", "hello world")
+	store(t, storage, "/photos/2020/beach.jpg", "beach")
+	store(t, storage, "/.fibr/.json", "[]")
+
+	t.Run("store without parent", func(t *testing.T) {
+		err := storage.Store("/unknown/file.txt", ioutil.NopCloser(strings.NewReader("")))
+		if !provider.IsNotExist(err) {
+			t.Errorf("Store() = `%s`, want not exist error", err)
+		}
+	})
+
+	t.Run("info", func(t *testing.T) {
+		item, err := storage.Info("readme.md")
+		if err != nil {
+			t.Fatalf("Info() = %s", err)
+		}
+
+		if item.IsDir || item.Name != "readme.md" || item.Pathname != "/readme.md" || item.Size != 11 {
+			t.Errorf("Info() = %+v", item)
+		}
+
+		if _, err := storage.Info("/../etc/passwd"); err != ErrRelativePath {
+			t.Errorf("Info() = `%s`, want `%s`", err, ErrRelativePath)
+		}
+	})
+
+	t.Run("list", func(t *testing.T) {
+		items, err := storage.List("/")
+		if err != nil {
+			t.Fatalf("List() = %s", err)
+		}
+
+		if len(items) != 2 || items[0].Name != "photos" || items[1].Name != "readme.md" {
+			t.Errorf("List() = %+v", items)
+		}
+
+		if _, err := storage.List("/readme.md"); err == nil {
+			t.Errorf("List() = nil, want not a directory error")
+		}
+	})
+
+	t.Run("reader", func(t *testing.T) {
+		reader, err := storage.ReaderFrom("/readme.md")
+		if err != nil {
+			t.Fatalf("ReaderFrom() = %s", err)
+		}
+		defer reader.Close()
+
+		if _, err := reader.Seek(-5, io.SeekEnd); err != nil {
+			t.Fatalf("Seek() = %s", err)
+		}
+
+		if content, err := ioutil.ReadAll(reader); err != nil || string(content) != "world" {
+			t.Errorf("ReadAll() = (`%s`, `%s`), want `world`", content, err)
+		}
+	})
+
+	t.Run("writer", func(t *testing.T) {
+		writer, err := storage.WriterTo("/notes.txt")
+		if err != nil {
+			t.Fatalf("WriterTo() = %s", err)
+		}
+
+		if _, err := io.WriteString(writer, "notes"); err != nil {
+			t.Fatalf("WriteString() = %s", err)
+		}
+
+		if _, err := storage.Info("/notes.txt"); !provider.IsNotExist(err) {
+			t.Errorf("Info() = `%s`, want content to be stored on close only", err)
+		}
+
+		if err := writer.Close(); err != nil {
+			t.Fatalf("Close() = %s", err)
+		}
+
+		if item, err := storage.Info("/notes.txt"); err != nil || item.Size != 5 {
+			t.Errorf("Info() = (%+v, `%s`)", item, err)
+		}
+	})
+
+	t.Run("aborted writer", func(t *testing.T) {
+		writer, err := storage.WriterTo("/notes.txt")
+		if err != nil {
+			t.Fatalf("WriterTo() = %s", err)
+		}
+
+		if _, err := io.WriteString(writer, "draft"); err != nil {
+			t.Fatalf("WriteString() = %s", err)
+		}
+
+		if err := writer.Abort(); err != nil {
+			t.Fatalf("Abort() = %s", err)
+		}
+
+		if err := writer.Close(); err != nil {
+			t.Fatalf("Close() = %s", err)
+		}
+
+		if item, err := storage.Info("/notes.txt"); err != nil || item.Size != 5 {
+			t.Errorf("Info() = (%+v, `%s`), want previous content kept", item, err)
+		}
+	})
+
+	t.Run("walk", func(t *testing.T) {
+		want := []string{"/", "/notes.txt", "/photos", "/photos/2020", "/photos/2020/beach.jpg", "/readme.md"}
+		if got := walkedPathnames(t, storage, ""); !reflect.DeepEqual(got, want) {
+			t.Errorf("Walk() = %#v, want %#v", got, want)
+		}
+	})
+
+	t.Run("rename", func(t *testing.T) {
+		if err := storage.Rename("/photos", "/pictures"); err != nil {
+			t.Fatalf("Rename() = %s", err)
+		}
+
+		if err := storage.Rename("/pictures", "/pictures/inside"); err == nil {
+			t.Errorf("Rename() = nil, want error when moving into itself")
+		}
+
+		want := []string{"/pictures", "/pictures/2020", "/pictures/2020/beach.jpg"}
+		if got := walkedPathnames(t, storage, "/pictures"); !reflect.DeepEqual(got, want) {
+			t.Errorf("Rename() = %#v, want %#v", got, want)
+		}
+	})
+
+	t.Run("remove", func(t *testing.T) {
+		if err := storage.Remove("/pictures"); err != nil {
+			t.Fatalf("Remove() = %s", err)
+		}
+
+		want := []string{"/", "/notes.txt", "/readme.md"}
+		if got := walkedPathnames(t, storage, "/"); !reflect.DeepEqual(got, want) {
+			t.Errorf("Remove() = %#v, want %#v", got, want)
+		}
+	})
+}
+
+func TestConcurrentStore(t *testing.T) {
+	storage := New()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			if err := storage.CreateDir("/concurrent"); err != nil {
+				t.Errorf("CreateDir() = %s", err)
+			}
+
+			if err := storage.Store("/concurrent/file.txt", ioutil.NopCloser(strings.NewReader("content"))); err != nil {
+				t.Errorf("Store() = %s", err)
+			}
+
+			if _, err := storage.List("/concurrent"); err != nil {
+				t.Errorf("List() = %s", err)
+			}
+		}()
+	}
+
+	wg.Wait()
+}
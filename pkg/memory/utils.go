@@ -0,0 +1,81 @@
+package memory
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/ViBiOh/fibr/pkg/provider"
+)
+
+type reader struct {
+	*bytes.Reader
+}
+
+// Close does nothing, content is held in memory
+func (r reader) Close() error {
+	return nil
+}
+
+type writer struct {
+	bytes.Buffer
+
+	storage  *app
+	pathname string
+	aborted  bool
+}
+
+// Close stores buffered content, unless write has been aborted
+func (w *writer) Close() error {
+	if w.aborted {
+		return nil
+	}
+
+	return w.storage.store(w.pathname, w.Bytes())
+}
+
+// Abort discards buffered content, previous content being kept
+func (w *writer) Abort() error {
+	w.aborted = true
+	w.Reset()
+
+	return nil
+}
+
+func checkPathname(pathname string) error {
+	if strings.Contains(pathname, "..") {
+		return ErrRelativePath
+	}
+
+	return nil
+}
+
+func getKey(pathname string) string {
+	return path.Clean(fmt.Sprintf("/%s", pathname))
+}
+
+func getParentKeys(key string) []string {
+	keys := []string{key}
+
+	for key != "/" {
+		key = path.Dir(key)
+		keys = append([]string{key}, keys...)
+	}
+
+	return keys
+}
+
+func convertToItem(key string, item node) provider.StorageItem {
+	return provider.StorageItem{
+		Name:     path.Base(key),
+		Pathname: key,
+		IsDir:    item.isDir,
+		Date:     item.date,
+		Size:     int64(len(item.content)),
+	}
+}
+
+func errNotExist(pathname string) error {
+	return provider.ErrNotExist(fmt.Errorf("%s: no such file or directory", pathname))
+}
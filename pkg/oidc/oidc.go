@@ -0,0 +1,433 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ViBiOh/auth/v2/pkg/ident"
+	authMiddleware "github.com/ViBiOh/auth/v2/pkg/middleware"
+	"github.com/ViBiOh/auth/v2/pkg/model"
+	"github.com/ViBiOh/fibr/pkg/provider"
+	"github.com/ViBiOh/httputils/v3/pkg/flags"
+	"github.com/ViBiOh/httputils/v3/pkg/httperror"
+	"github.com/ViBiOh/httputils/v3/pkg/logger"
+)
+
+const (
+	loginPath    = "/oidc/login"
+	callbackPath = "/oidc/callback"
+	logoutPath   = "/oidc/logout"
+
+	sessionCookieName = "fibr_session"
+	stateCookieName   = "fibr_oidc"
+
+	stateDuration = 10 * time.Minute
+
+	// LoginPrefix is prepended to login of users logged in with OpenID Connect, keeping them apart from Basic Auth ones
+	LoginPrefix = "oidc:"
+)
+
+type key int
+
+const (
+	ctxSessionKey key = iota
+)
+
+// App of package
+type App interface {
+	authMiddleware.App
+	Enabled() bool
+	IsHandled(*http.Request) bool
+	Handler() http.Handler
+	LoginURL(string) string
+	WithSession(*http.Request) *http.Request
+}
+
+// Config of package
+type Config struct {
+	issuer          *string
+	clientID        *string
+	clientSecret    *string
+	url             *string
+	scopes          *string
+	loginClaim      *string
+	profilesClaim   *string
+	profiles        *string
+	secret          *string
+	sessionDuration *string
+}
+
+type app struct {
+	issuer          string
+	clientID        string
+	clientSecret    string
+	url             string
+	scopes          []string
+	loginClaim      string
+	profilesClaim   string
+	profiles        map[string][]string
+	secret          []byte
+	sessionDuration time.Duration
+
+	fallback authMiddleware.App
+
+	mutex     *sync.Mutex
+	discovery discovery
+	keys      map[string]*rsa.PublicKey
+}
+
+// Flags adds flags for configuring package
+func Flags(fs *flag.FlagSet, prefix string) Config {
+	return Config{
+		issuer:          flags.New(prefix, "oidc").Name("Issuer").Default("").Label("OpenID Connect issuer URL, disabled if empty").ToString(fs),
+		clientID:        flags.New(prefix, "oidc").Name("ClientID").Default("").Label("OpenID Connect client ID").ToString(fs),
+		clientSecret:    flags.New(prefix, "oidc").Name("ClientSecret").Default("").Label("OpenID Connect client secret").ToString(fs),
+		url:             flags.New(prefix, "oidc").Name("URL").Default("").Label("Public URL of fibr, for redirecting back from issuer to /oidc/callback").ToString(fs),
+		scopes:          flags.New(prefix, "oidc").Name("Scopes").Default("openid,profile,email").Label("Scopes requested to issuer").ToString(fs),
+		loginClaim:      flags.New(prefix, "oidc").Name("LoginClaim").Default("preferred_username").Label("Claim used as user's login").ToString(fs),
+		profilesClaim:   flags.New(prefix, "oidc").Name("ProfilesClaim").Default("groups").Label("Claim used for user's profiles").ToString(fs),
+		profiles:        flags.New(prefix, "oidc").Name("Profiles").Default("").Label("Claim values to profiles mapping in the form 'value:profile,value2:profile2', no profile granted if empty").ToString(fs),
+		secret:          flags.New(prefix, "oidc").Name("Secret").Default("").Label("Secret for signing session cookie, random on each start if empty").ToString(fs),
+		sessionDuration: flags.New(prefix, "oidc").Name("SessionDuration").Default("12h").Label("Duration of a session").ToString(fs),
+	}
+}
+
+// New creates new App from Config, falling back to given App for requests without session
+func New(config Config, fallback authMiddleware.App) (App, error) {
+	issuer := strings.TrimSuffix(strings.TrimSpace(*config.issuer), "/")
+	if len(issuer) == 0 {
+		return &app{fallback: fallback}, nil
+	}
+
+	clientID := strings.TrimSpace(*config.clientID)
+	if len(clientID) == 0 {
+		return nil, errors.New("client ID is required for OpenID Connect")
+	}
+
+	publicURL := strings.TrimSuffix(strings.TrimSpace(*config.url), "/")
+	if len(publicURL) == 0 {
+		return nil, errors.New("public URL is required for OpenID Connect")
+	}
+
+	sessionDuration, err := time.ParseDuration(strings.TrimSpace(*config.sessionDuration))
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse session duration: %w", err)
+	}
+
+	profiles, err := parseProfiles(*config.profiles)
+	if err != nil {
+		return nil, err
+	}
+
+	secret := []byte(strings.TrimSpace(*config.secret))
+	if len(secret) == 0 {
+		logger.Warn("no secret given for OpenID Connect, sessions will be lost on restart")
+
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, fmt.Errorf("unable to generate secret: %w", err)
+		}
+	}
+
+	return &app{
+		issuer:          issuer,
+		clientID:        clientID,
+		clientSecret:    strings.TrimSpace(*config.clientSecret),
+		url:             publicURL,
+		scopes:          splitValues(*config.scopes),
+		loginClaim:      strings.TrimSpace(*config.loginClaim),
+		profilesClaim:   strings.TrimSpace(*config.profilesClaim),
+		profiles:        profiles,
+		secret:          secret,
+		sessionDuration: sessionDuration,
+
+		fallback: fallback,
+
+		mutex: &sync.Mutex{},
+		keys:  make(map[string]*rsa.PublicKey),
+	}, nil
+}
+
+// Enabled checks if OpenID Connect login is configured
+func (a *app) Enabled() bool {
+	return len(a.issuer) != 0
+}
+
+// IsHandled checks if request targets login flow
+func (a *app) IsHandled(r *http.Request) bool {
+	switch r.URL.Path {
+	case loginPath, callbackPath, logoutPath:
+		return true
+	default:
+		return false
+	}
+}
+
+// LoginURL gives URL for starting login flow, redirecting to given path once logged in
+func (a *app) LoginURL(redirect string) string {
+	return fmt.Sprintf("%s?redirect=%s", loginPath, url.QueryEscape(redirect))
+}
+
+func (a *app) getRedirectURI() string {
+	return a.url + callbackPath
+}
+
+// Handler for login flow. Should be use with net/http
+func (a *app) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == logoutPath {
+			a.logout(w, r)
+			return
+		}
+
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		switch r.URL.Path {
+		case loginPath:
+			a.login(w, r)
+		case callbackPath:
+			a.callback(w, r)
+		default:
+			httperror.NotFound(w)
+		}
+	})
+}
+
+func (a *app) logout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !provider.HasCSRFToken(r) {
+		httperror.Forbidden(w)
+		return
+	}
+
+	clearCookie(w, sessionCookieName)
+	http.Redirect(w, r, "/", http.StatusFound)
+}
+
+func (a *app) login(w http.ResponseWriter, r *http.Request) {
+	metadata, err := a.getDiscovery(r.Context())
+	if err != nil {
+		httperror.InternalServerError(w, err)
+		return
+	}
+
+	stateValue, err := randomString()
+	if err != nil {
+		httperror.InternalServerError(w, err)
+		return
+	}
+
+	nonce, err := randomString()
+	if err != nil {
+		httperror.InternalServerError(w, err)
+		return
+	}
+
+	state := loginState{
+		State:    stateValue,
+		Nonce:    nonce,
+		Redirect: getSafeRedirect(r.URL.Query().Get("redirect")),
+		Expire:   time.Now().Add(stateDuration),
+	}
+
+	if err := a.setCookie(w, stateCookieName, state, state.Expire); err != nil {
+		httperror.InternalServerError(w, err)
+		return
+	}
+
+	values := url.Values{}
+	values.Set("response_type", "code")
+	values.Set("client_id", a.clientID)
+	values.Set("redirect_uri", a.getRedirectURI())
+	values.Set("scope", strings.Join(a.scopes, " "))
+	values.Set("state", state.State)
+	values.Set("nonce", state.Nonce)
+
+	separator := "?"
+	if strings.Contains(metadata.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+
+	http.Redirect(w, r, metadata.AuthorizationEndpoint+separator+values.Encode(), http.StatusFound)
+}
+
+func (a *app) callback(w http.ResponseWriter, r *http.Request) {
+	cookie, err := r.Cookie(stateCookieName)
+	if err != nil {
+		httperror.BadRequest(w, errors.New("no login in progress"))
+		return
+	}
+
+	clearCookie(w, stateCookieName)
+
+	var state loginState
+	if err := a.decodeCookie(cookie.Value, &state); err != nil || state.Expire.Before(time.Now()) || r.URL.Query().Get("state") != state.State {
+		httperror.BadRequest(w, errors.New("invalid login state"))
+		return
+	}
+
+	if errorValue := r.URL.Query().Get("error"); len(errorValue) != 0 {
+		httperror.Unauthorized(w, fmt.Errorf("login refused by issuer: %s", errorValue))
+		return
+	}
+
+	idToken, err := a.exchangeCode(r.Context(), r.URL.Query().Get("code"))
+	if err != nil {
+		httperror.Unauthorized(w, err)
+		return
+	}
+
+	claims, err := a.verifyToken(r.Context(), idToken, state.Nonce)
+	if err != nil {
+		httperror.Unauthorized(w, err)
+		return
+	}
+
+	content := session{
+		Login:    a.getLogin(claims),
+		Profiles: a.getProfiles(claims),
+		Expire:   time.Now().Add(a.sessionDuration),
+	}
+
+	if len(content.Login) == 0 {
+		httperror.Unauthorized(w, fmt.Errorf("no `%s` claim in id token", a.loginClaim))
+		return
+	}
+
+	if err := a.setCookie(w, sessionCookieName, content, content.Expire); err != nil {
+		httperror.InternalServerError(w, err)
+		return
+	}
+
+	logger.Info("%s logged in with OpenID Connect", getUser(content).Login)
+	http.Redirect(w, r, state.Redirect, http.StatusFound)
+}
+
+// Middleware wraps next authenticated handler
+func (a *app) Middleware(next http.Handler) http.Handler {
+	fallback := next
+	if a.fallback != nil {
+		fallback = a.fallback.Middleware(next)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if content, user, err := a.getSessionUser(r); err == nil {
+			next.ServeHTTP(w, r.WithContext(model.StoreUser(context.WithValue(r.Context(), ctxSessionKey, content), user)))
+			return
+		}
+
+		fallback.ServeHTTP(w, r)
+	})
+}
+
+func (a *app) getSessionUser(r *http.Request) (session, model.User, error) {
+	if !a.Enabled() || len(r.Header.Get("Authorization")) != 0 {
+		return session{}, model.NoneUser, ErrInvalidSession
+	}
+
+	content, ok := r.Context().Value(ctxSessionKey).(session)
+	if !ok {
+		var err error
+		if content, err = a.getSession(r); err != nil {
+			return content, model.NoneUser, err
+		}
+	}
+
+	return content, getUser(content), nil
+}
+
+// getUser gives user of session, its login being prefixed and its id unused
+func getUser(content session) model.User {
+	return model.NewUser(0, LoginPrefix+content.Login)
+}
+
+// IsUser checks if user is logged in with OpenID Connect
+func IsUser(user model.User) bool {
+	return strings.HasPrefix(user.Login, LoginPrefix)
+}
+
+// WithSession stores session of request in its context, for checking profiles of user afterwards
+func (a *app) WithSession(r *http.Request) *http.Request {
+	content, _, err := a.getSessionUser(r)
+	if err != nil {
+		return r
+	}
+
+	return r.WithContext(context.WithValue(r.Context(), ctxSessionKey, content))
+}
+
+// IsAuthenticated checks session cookie, or falls back to Authorization header
+func (a *app) IsAuthenticated(r *http.Request, profile string) (ident.Provider, model.User, error) {
+	if _, user, err := a.getSessionUser(r); err == nil {
+		return nil, user, nil
+	}
+
+	if a.fallback == nil {
+		return nil, model.NoneUser, authMiddleware.ErrEmptyAuth
+	}
+
+	provider, user, err := a.fallback.IsAuthenticated(r, profile)
+	if err == nil && a.Enabled() && IsUser(user) {
+		return provider, model.NoneUser, ident.ErrInvalidCredentials
+	}
+
+	return provider, user, err
+}
+
+// HasProfile checks if user has given profile, from session stored in context for users logged in with OpenID Connect
+func (a *app) HasProfile(ctx context.Context, user model.User, profile string) bool {
+	if a.Enabled() && IsUser(user) {
+		content, ok := ctx.Value(ctxSessionKey).(session)
+		if !ok || getUser(content).Login != user.Login {
+			return false
+		}
+
+		for _, item := range content.Profiles {
+			if item == profile {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	if a.fallback == nil {
+		return false
+	}
+
+	return a.fallback.HasProfile(ctx, user, profile)
+}
+
+func randomString() (string, error) {
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("unable to generate random string: %w", err)
+	}
+
+	return hex.EncodeToString(raw), nil
+}
+
+func getSafeRedirect(redirect string) string {
+	if !strings.HasPrefix(redirect, "/") || strings.HasPrefix(redirect, "//") || strings.HasPrefix(redirect, "/\\") {
+		return "/"
+	}
+
+	return redirect
+}
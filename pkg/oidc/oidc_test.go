@@ -0,0 +1,480 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ViBiOh/auth/v2/pkg/ident"
+	"github.com/ViBiOh/auth/v2/pkg/model"
+	"github.com/ViBiOh/fibr/pkg/oidc/oidctest"
+	"github.com/ViBiOh/fibr/pkg/provider"
+)
+
+type testFallback struct{}
+
+func (t testFallback) Middleware(next http.Handler) http.Handler {
+	return next
+}
+
+func (t testFallback) IsAuthenticated(r *http.Request, _ string) (ident.Provider, model.User, error) {
+	switch r.Header.Get("Authorization") {
+	case "Basic admin":
+		return nil, model.NewUser(1, "admin"), nil
+	case "Basic root":
+		return nil, model.NewUser(0, "root"), nil
+	case "Basic impostor":
+		return nil, model.NewUser(2, "oidc:alice"), nil
+	}
+
+	return nil, model.NoneUser, ident.ErrInvalidCredentials
+}
+
+func (t testFallback) HasProfile(_ context.Context, user model.User, profile string) bool {
+	return user.ID <= 1 && profile == "admin"
+}
+
+func newTestApp(t *testing.T, issuer *oidctest.Issuer) *app {
+	fs := flag.NewFlagSet("oidc-test", flag.ContinueOnError)
+	config := Flags(fs, "oidc")
+
+	if err := fs.Parse([]string{"-oidcIssuer", issuer.URL, "-oidcClientID", "fibr", "-oidcClientSecret", "secret", "-oidcURL", "https://fibr.example.com", "-oidcProfiles", "team:editor"}); err != nil {
+		t.Fatalf("unable to parse flags: %s", err)
+	}
+
+	instance, err := New(config, testFallback{})
+	if err != nil {
+		t.Fatalf("New() = %s", err)
+	}
+
+	return instance.(*app)
+}
+
+func serve(instance *app, target string, cookies []*http.Cookie) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, target, nil)
+	for _, cookie := range cookies {
+		r.AddCookie(cookie)
+	}
+
+	writer := httptest.NewRecorder()
+	instance.Handler().ServeHTTP(writer, r)
+
+	return writer
+}
+
+// login runs authorization code flow against issuer, giving session cookies
+func login(t *testing.T, instance *app) []*http.Cookie {
+	writer := serve(instance, "/oidc/login?redirect=/photos/", nil)
+	if writer.Code != http.StatusFound {
+		t.Fatalf("login = %d, want redirect to issuer", writer.Code)
+	}
+
+	client := http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+
+	resp, err := client.Get(writer.Header().Get("Location"))
+	if err != nil {
+		t.Fatalf("authorize = %s", err)
+	}
+
+	callback, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil || callback.Path != callbackPath {
+		t.Fatalf("authorize = `%s`, want redirect to callback", resp.Header.Get("Location"))
+	}
+
+	writer = serve(instance, callback.RequestURI(), writer.Result().Cookies())
+	if writer.Code != http.StatusFound || writer.Header().Get("Location") != "/photos/" {
+		t.Fatalf("callback = (%d, `%s`, `%s`), want redirect to /photos/", writer.Code, writer.Header().Get("Location"), writer.Body.String())
+	}
+
+	return writer.Result().Cookies()
+}
+
+func getCookie(cookies []*http.Cookie, name string) *http.Cookie {
+	for _, cookie := range cookies {
+		if cookie.Name == name && len(cookie.Value) != 0 {
+			return cookie
+		}
+	}
+
+	return nil
+}
+
+func TestLogin(t *testing.T) {
+	issuer := oidctest.New("fibr", "secret", map[string]interface{}{
+		"preferred_username": "alice",
+		"groups":             []string{"team", "other"},
+	})
+	defer issuer.Close()
+
+	instance := newTestApp(t, issuer)
+
+	sessionCookie := getCookie(login(t, instance), sessionCookieName)
+	if sessionCookie == nil {
+		t.Fatal("callback did not set session cookie")
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/photos/", nil)
+	r.AddCookie(sessionCookie)
+
+	_, user, err := instance.IsAuthenticated(r, "")
+	if err != nil || user.Login != "oidc:alice" || !IsUser(user) {
+		t.Fatalf("IsAuthenticated() = (%+v, `%v`), want oidc:alice", user, err)
+	}
+
+	if instance.HasProfile(r.Context(), user, "editor") {
+		t.Errorf("HasProfile() = true, want profiles read from request session only")
+	}
+
+	r = instance.WithSession(r)
+
+	if !instance.HasProfile(r.Context(), user, "editor") || instance.HasProfile(r.Context(), user, "admin") {
+		t.Errorf("HasProfile() = wrong mapping of groups claim")
+	}
+
+	tampered := *sessionCookie
+	tampered.Value = strings.Replace(tampered.Value, ".", "x.", 1)
+
+	r = httptest.NewRequest(http.MethodGet, "/photos/", nil)
+	r.AddCookie(&tampered)
+
+	if _, _, err := instance.IsAuthenticated(r, ""); !errors.Is(err, ident.ErrInvalidCredentials) {
+		t.Errorf("IsAuthenticated() = `%v`, want tampered session refused", err)
+	}
+}
+
+func TestLoginBasicFallback(t *testing.T) {
+	issuer := oidctest.New("fibr", "secret", nil)
+	defer issuer.Close()
+
+	instance := newTestApp(t, issuer)
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Authorization", "Basic admin")
+
+	_, user, err := instance.IsAuthenticated(r, "")
+	if err != nil || user.Login != "admin" || !instance.HasProfile(r.Context(), user, "admin") {
+		t.Errorf("IsAuthenticated() = (%+v, `%v`), want basic user", user, err)
+	}
+}
+
+func TestBasicUsersApart(t *testing.T) {
+	issuer := oidctest.New("fibr", "secret", nil)
+	defer issuer.Close()
+
+	instance := newTestApp(t, issuer)
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Authorization", "Basic root")
+
+	_, user, err := instance.IsAuthenticated(r, "")
+	if err != nil || IsUser(user) || !instance.HasProfile(r.Context(), user, "admin") {
+		t.Errorf("IsAuthenticated() = (%+v, `%v`), want basic user with id 0 kept apart from OpenID Connect", user, err)
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Authorization", "Basic impostor")
+
+	if _, _, err := instance.IsAuthenticated(r, ""); !errors.Is(err, ident.ErrInvalidCredentials) {
+		t.Errorf("IsAuthenticated() = `%v`, want basic login with OpenID Connect prefix refused", err)
+	}
+}
+
+func TestCallbackInvalidState(t *testing.T) {
+	issuer := oidctest.New("fibr", "secret", nil)
+	defer issuer.Close()
+
+	instance := newTestApp(t, issuer)
+
+	writer := serve(instance, "/oidc/login", nil)
+	cookies := writer.Result().Cookies()
+
+	if writer := serve(instance, "/oidc/callback?code=forged&state=forged", cookies); writer.Code != http.StatusBadRequest {
+		t.Errorf("callback = %d, want %d", writer.Code, http.StatusBadRequest)
+	}
+
+	if writer := serve(instance, "/oidc/callback?code=forged&state=forged", nil); writer.Code != http.StatusBadRequest {
+		t.Errorf("callback = %d, want %d", writer.Code, http.StatusBadRequest)
+	}
+}
+
+func TestLogout(t *testing.T) {
+	issuer := oidctest.New("fibr", "secret", nil)
+	defer issuer.Close()
+
+	instance := newTestApp(t, issuer)
+	csrf := strings.Repeat("ab", 32)
+
+	var cases = []struct {
+		intention string
+		method    string
+		token     string
+		origin    string
+		want      int
+	}{
+		{
+			"get",
+			http.MethodGet,
+			"",
+			"",
+			http.StatusMethodNotAllowed,
+		},
+		{
+			"no token",
+			http.MethodPost,
+			"",
+			"",
+			http.StatusForbidden,
+		},
+		{
+			"invalid token",
+			http.MethodPost,
+			strings.Repeat("cd", 32),
+			"",
+			http.StatusForbidden,
+		},
+		{
+			"cross origin",
+			http.MethodPost,
+			csrf,
+			"https://evil.com",
+			http.StatusForbidden,
+		},
+		{
+			"valid",
+			http.MethodPost,
+			csrf,
+			"http://example.com",
+			http.StatusFound,
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.intention, func(t *testing.T) {
+			form := url.Values{}
+			form.Set(provider.CSRFFieldName, testCase.token)
+
+			r := httptest.NewRequest(testCase.method, logoutPath, strings.NewReader(form.Encode()))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			r.AddCookie(&http.Cookie{Name: provider.CSRFCookieName, Value: csrf})
+			r.AddCookie(&http.Cookie{Name: sessionCookieName, Value: "session"})
+			if len(testCase.origin) != 0 {
+				r.Header.Set("Origin", testCase.origin)
+			}
+
+			writer := httptest.NewRecorder()
+			instance.Handler().ServeHTTP(writer, r)
+
+			if writer.Code != testCase.want {
+				t.Errorf("logout = %d, want %d", writer.Code, testCase.want)
+			}
+
+			cleared := false
+			for _, cookie := range writer.Result().Cookies() {
+				if cookie.Name == sessionCookieName && cookie.MaxAge < 0 {
+					cleared = true
+				}
+			}
+
+			if cleared != (testCase.want == http.StatusFound) {
+				t.Errorf("logout cleared session = %t, want %t", cleared, testCase.want == http.StatusFound)
+			}
+		})
+	}
+}
+
+func TestVerifyToken(t *testing.T) {
+	issuer := oidctest.New("fibr", "secret", nil)
+	defer issuer.Close()
+
+	instance := newTestApp(t, issuer)
+
+	var cases = []struct {
+		intention string
+		claims    map[string]interface{}
+		wantErr   bool
+	}{
+		{
+			"valid",
+			map[string]interface{}{},
+			false,
+		},
+		{
+			"other audience",
+			map[string]interface{}{"aud": "other"},
+			true,
+		},
+		{
+			"audience list",
+			map[string]interface{}{"aud": []string{"other", "fibr"}},
+			false,
+		},
+		{
+			"other issuer",
+			map[string]interface{}{"iss": "https://evil.example.com"},
+			true,
+		},
+		{
+			"expired",
+			map[string]interface{}{"exp": time.Now().Add(-time.Minute).Unix()},
+			true,
+		},
+		{
+			"replayed",
+			map[string]interface{}{"nonce": "other"},
+			true,
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.intention, func(t *testing.T) {
+			claims := map[string]interface{}{
+				"iss":   issuer.URL,
+				"aud":   "fibr",
+				"sub":   "alice",
+				"exp":   time.Now().Add(time.Hour).Unix(),
+				"nonce": "nonce",
+			}
+
+			for key, value := range testCase.claims {
+				claims[key] = value
+			}
+
+			token, err := issuer.Sign(claims)
+			if err != nil {
+				t.Fatalf("Sign() = %s", err)
+			}
+
+			if _, err := instance.verifyToken(context.Background(), token, "nonce"); (err != nil) != testCase.wantErr {
+				t.Errorf("verifyToken() = `%v`, want error %t", err, testCase.wantErr)
+			}
+		})
+	}
+}
+
+func TestGetSafeRedirect(t *testing.T) {
+	var cases = []struct {
+		intention string
+		input     string
+		want      string
+	}{
+		{
+			"path",
+			"/photos/?d=grid",
+			"/photos/?d=grid",
+		},
+		{
+			"empty",
+			"",
+			"/",
+		},
+		{
+			"absolute",
+			"https://evil.example.com",
+			"/",
+		},
+		{
+			"protocol relative",
+			"//evil.example.com",
+			"/",
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.intention, func(t *testing.T) {
+			if result := getSafeRedirect(testCase.input); result != testCase.want {
+				t.Errorf("getSafeRedirect() = `%s`, want `%s`", result, testCase.want)
+			}
+		})
+	}
+}
+
+func TestParseProfiles(t *testing.T) {
+	var cases = []struct {
+		intention string
+		input     string
+		want      map[string][]string
+		wantErr   bool
+	}{
+		{
+			"empty",
+			"",
+			map[string][]string{},
+			false,
+		},
+		{
+			"mapping",
+			"admins:admin, team:editor,team:reviewer",
+			map[string][]string{"admins": {"admin"}, "team": {"editor", "reviewer"}},
+			false,
+		},
+		{
+			"url value",
+			"https://example.com/admins:admin",
+			map[string][]string{"https://example.com/admins": {"admin"}},
+			false,
+		},
+		{
+			"invalid",
+			"admins",
+			nil,
+			true,
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.intention, func(t *testing.T) {
+			result, err := parseProfiles(testCase.input)
+
+			if (err != nil) != testCase.wantErr || !reflect.DeepEqual(result, testCase.want) {
+				t.Errorf("parseProfiles() = (%+v, `%v`), want (%+v, error %t)", result, err, testCase.want, testCase.wantErr)
+			}
+		})
+	}
+}
+
+func TestGetProfiles(t *testing.T) {
+	var cases = []struct {
+		intention string
+		profiles  map[string][]string
+		claims    map[string]interface{}
+		want      []string
+	}{
+		{
+			"no mapping",
+			map[string][]string{},
+			map[string]interface{}{"groups": []interface{}{"admin"}},
+			[]string{},
+		},
+		{
+			"mapping",
+			map[string][]string{"team": {"editor", "reviewer"}},
+			map[string]interface{}{"groups": []interface{}{"team", "admin"}},
+			[]string{"editor", "reviewer"},
+		},
+		{
+			"string claim",
+			map[string][]string{"admins": {"admin"}},
+			map[string]interface{}{"groups": "admins, others"},
+			[]string{"admin"},
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.intention, func(t *testing.T) {
+			instance := &app{profilesClaim: "groups", profiles: testCase.profiles}
+
+			if result := instance.getProfiles(testCase.claims); !reflect.DeepEqual(result, testCase.want) {
+				t.Errorf("getProfiles() = %+v, want %+v", result, testCase.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package oidc
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrInvalidSession occurs when a session cookie is malformed, tampered or expired
+	ErrInvalidSession = errors.New("invalid session")
+)
+
+type session struct {
+	Login    string    `json:"login"`
+	Profiles []string  `json:"profiles,omitempty"`
+	Expire   time.Time `json:"expire"`
+}
+
+type loginState struct {
+	State    string    `json:"state"`
+	Nonce    string    `json:"nonce"`
+	Redirect string    `json:"redirect"`
+	Expire   time.Time `json:"expire"`
+}
+
+func (a app) sign(payload string) string {
+	mac := hmac.New(sha256.New, a.secret)
+	mac.Write([]byte(payload))
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (a app) encodeCookie(value interface{}) (string, error) {
+	content, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+
+	payload := base64.RawURLEncoding.EncodeToString(content)
+	return payload + "." + a.sign(payload), nil
+}
+
+func (a app) decodeCookie(value string, output interface{}) error {
+	parts := strings.Split(value, ".")
+	if len(parts) != 2 || !hmac.Equal([]byte(parts[1]), []byte(a.sign(parts[0]))) {
+		return ErrInvalidSession
+	}
+
+	content, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return ErrInvalidSession
+	}
+
+	if err := json.Unmarshal(content, output); err != nil {
+		return ErrInvalidSession
+	}
+
+	return nil
+}
+
+func (a app) setCookie(w http.ResponseWriter, name string, value interface{}, expire time.Time) error {
+	content, err := a.encodeCookie(value)
+	if err != nil {
+		return err
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    content,
+		Path:     "/",
+		Expires:  expire,
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(a.url, "https://"),
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	return nil
+}
+
+func clearCookie(w http.ResponseWriter, name string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+	})
+}
+
+func (a app) getSession(r *http.Request) (session, error) {
+	cookie, err := r.Cookie(sessionCookieName)
+	if err != nil {
+		return session{}, ErrInvalidSession
+	}
+
+	var content session
+	if err := a.decodeCookie(cookie.Value, &content); err != nil {
+		return session{}, err
+	}
+
+	if len(content.Login) == 0 || content.Expire.Before(time.Now()) {
+		return session{}, ErrInvalidSession
+	}
+
+	return content, nil
+}
@@ -0,0 +1,249 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ViBiOh/httputils/v3/pkg/request"
+)
+
+var (
+	// ErrInvalidToken occurs when ID token can't be trusted
+	ErrInvalidToken = errors.New("invalid id token")
+)
+
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type jsonWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+type tokenHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+func getJSON(ctx context.Context, req *request.Request, output interface{}) error {
+	resp, err := req.Send(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	content, err := request.ReadBodyResponse(resp)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(content, output)
+}
+
+func (a *app) getDiscovery(ctx context.Context) (discovery, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if len(a.discovery.TokenEndpoint) != 0 {
+		return a.discovery, nil
+	}
+
+	var content discovery
+	if err := getJSON(ctx, request.New().Get(fmt.Sprintf("%s/.well-known/openid-configuration", a.issuer)), &content); err != nil {
+		return content, fmt.Errorf("unable to discover issuer configuration: %w", err)
+	}
+
+	if content.Issuer != a.issuer {
+		return content, fmt.Errorf("issuer mismatch: got `%s`, want `%s`", content.Issuer, a.issuer)
+	}
+
+	a.discovery = content
+	return content, nil
+}
+
+func (a *app) getKey(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	a.mutex.Lock()
+	key, ok := a.keys[kid]
+	a.mutex.Unlock()
+
+	if ok {
+		return key, nil
+	}
+
+	metadata, err := a.getDiscovery(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var content struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := getJSON(ctx, request.New().Get(metadata.JWKSURI), &content); err != nil {
+		return nil, fmt.Errorf("unable to fetch issuer keys: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for _, item := range content.Keys {
+		if item.Kty != "RSA" {
+			continue
+		}
+
+		modulus, err := base64.RawURLEncoding.DecodeString(item.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid modulus for key `%s`: %w", item.Kid, err)
+		}
+
+		exponent, err := base64.RawURLEncoding.DecodeString(item.E)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exponent for key `%s`: %w", item.Kid, err)
+		}
+
+		keys[item.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(modulus),
+			E: int(new(big.Int).SetBytes(exponent).Int64()),
+		}
+	}
+
+	a.mutex.Lock()
+	a.keys = keys
+	a.mutex.Unlock()
+
+	if key, ok := keys[kid]; ok {
+		return key, nil
+	}
+
+	return nil, fmt.Errorf("unknown key `%s`: %w", kid, ErrInvalidToken)
+}
+
+func (a *app) exchangeCode(ctx context.Context, code string) (string, error) {
+	metadata, err := a.getDiscovery(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	values := url.Values{}
+	values.Set("grant_type", "authorization_code")
+	values.Set("code", code)
+	values.Set("redirect_uri", a.getRedirectURI())
+
+	resp, err := request.New().Post(metadata.TokenEndpoint).BasicAuth(url.QueryEscape(a.clientID), url.QueryEscape(a.clientSecret)).Header("Accept", "application/json").Form(ctx, values)
+	if err != nil {
+		return "", fmt.Errorf("unable to exchange code: %w", err)
+	}
+
+	content, err := request.ReadBodyResponse(resp)
+	if err != nil {
+		return "", err
+	}
+
+	var token struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := json.Unmarshal(content, &token); err != nil {
+		return "", fmt.Errorf("unable to parse token response: %w", err)
+	}
+
+	if len(token.IDToken) == 0 {
+		return "", errors.New("no id token in token response")
+	}
+
+	return token.IDToken, nil
+}
+
+func decodeSegment(segment string, output interface{}) error {
+	content, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return ErrInvalidToken
+	}
+
+	if err := json.Unmarshal(content, output); err != nil {
+		return ErrInvalidToken
+	}
+
+	return nil
+}
+
+func hasAudience(claim interface{}, clientID string) bool {
+	switch audience := claim.(type) {
+	case string:
+		return audience == clientID
+	case []interface{}:
+		for _, item := range audience {
+			if item == clientID {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// verifyToken checks signature and claims of an RS256 signed ID token, returning its claims
+func (a *app) verifyToken(ctx context.Context, token, nonce string) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+
+	var header tokenHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, err
+	}
+
+	if header.Alg != "RS256" {
+		return nil, fmt.Errorf("unsupported algorithm `%s`: %w", header.Alg, ErrInvalidToken)
+	}
+
+	key, err := a.getKey(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	hashed := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, hashed[:], signature); err != nil {
+		return nil, fmt.Errorf("invalid signature: %w", ErrInvalidToken)
+	}
+
+	var claims map[string]interface{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, err
+	}
+
+	if claims["iss"] != a.issuer {
+		return nil, fmt.Errorf("invalid issuer: %w", ErrInvalidToken)
+	}
+
+	if !hasAudience(claims["aud"], a.clientID) {
+		return nil, fmt.Errorf("invalid audience: %w", ErrInvalidToken)
+	}
+
+	if expiration, ok := claims["exp"].(float64); !ok || time.Unix(int64(expiration), 0).Before(time.Now()) {
+		return nil, fmt.Errorf("expired token: %w", ErrInvalidToken)
+	}
+
+	if claims["nonce"] != nonce {
+		return nil, fmt.Errorf("invalid nonce: %w", ErrInvalidToken)
+	}
+
+	return claims, nil
+}
@@ -0,0 +1,72 @@
+package oidc
+
+import (
+	"fmt"
+	"strings"
+)
+
+func splitValues(value string) []string {
+	values := make([]string, 0)
+
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); len(item) != 0 {
+			values = append(values, item)
+		}
+	}
+
+	return values
+}
+
+// parseProfiles parses claim values to profiles mapping, in the form `value:profile,value2:profile2`
+func parseProfiles(value string) (map[string][]string, error) {
+	profiles := make(map[string][]string)
+
+	for _, entry := range splitValues(value) {
+		index := strings.LastIndex(entry, ":")
+		if index <= 0 || index == len(entry)-1 {
+			return nil, fmt.Errorf("invalid profile mapping `%s`", entry)
+		}
+
+		claimValue := strings.TrimSpace(entry[:index])
+		profiles[claimValue] = append(profiles[claimValue], strings.TrimSpace(entry[index+1:]))
+	}
+
+	return profiles, nil
+}
+
+func getClaimValues(claim interface{}) []string {
+	switch content := claim.(type) {
+	case string:
+		return splitValues(content)
+	case []interface{}:
+		values := make([]string, 0, len(content))
+		for _, item := range content {
+			if value, ok := item.(string); ok {
+				values = append(values, value)
+			}
+		}
+		return values
+	default:
+		return nil
+	}
+}
+
+func (a *app) getLogin(claims map[string]interface{}) string {
+	if login, ok := claims[a.loginClaim].(string); ok && len(login) != 0 {
+		return login
+	}
+
+	login, _ := claims["sub"].(string)
+	return login
+}
+
+// getProfiles maps claim values to profiles, a value without mapping granting nothing, so an identity provider's group never
+// becomes a fibr profile only because of its name
+func (a *app) getProfiles(claims map[string]interface{}) []string {
+	profiles := make([]string, 0)
+	for _, value := range getClaimValues(claims[a.profilesClaim]) {
+		profiles = append(profiles, a.profiles[value]...)
+	}
+
+	return profiles
+}
@@ -0,0 +1,70 @@
+package provider
+
+import (
+	"path"
+	"strings"
+)
+
+// ACLFilename is the name of access control list file, in metadata directory
+const ACLFilename = "acl.json"
+
+// Rule gives permissions on a path and its content to users or profiles
+type Rule struct {
+	Path        string     `json:"path"`
+	Users       []string   `json:"users"`
+	Profiles    []string   `json:"profiles"`
+	Permissions Permission `json:"permissions"`
+}
+
+// ACL is a list of rules, the most specific path winning
+type ACL []Rule
+
+func isSubPath(pathname, root string) bool {
+	root = path.Join("/", root)
+	pathname = path.Join("/", pathname)
+
+	return root == "/" || pathname == root || strings.HasPrefix(pathname, root+"/")
+}
+
+// Permission gives permission on given pathname, fallback being used when no rule matches
+func (a ACL) Permission(pathname string, fallback Permission) Permission {
+	permission := fallback
+	matchLength := -1
+
+	for _, rule := range a {
+		if root := path.Join("/", rule.Path); len(root) > matchLength && isSubPath(pathname, root) {
+			permission = rule.Permissions
+			matchLength = len(root)
+		}
+	}
+
+	return permission
+}
+
+// Visible checks if given pathname can appear in listings: it's allowed, or leads to an allowed path
+func (a ACL) Visible(pathname string, fallback Permission) bool {
+	if a.Permission(pathname, fallback) != 0 {
+		return true
+	}
+
+	for _, rule := range a {
+		if rule.Permissions != 0 && isSubPath(rule.Path, pathname) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Restricts checks if a rule under given pathname gives less than given permission
+func (a ACL) Restricts(pathname string, permission Permission) bool {
+	root := path.Join("/", pathname)
+
+	for _, rule := range a {
+		if path.Join("/", rule.Path) != root && isSubPath(rule.Path, root) && !rule.Permissions.Has(permission) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,134 @@
+package provider
+
+import (
+	"testing"
+)
+
+var testACL = ACL{
+	{Path: "/", Permissions: PermissionRead},
+	{Path: "/marketing", Permissions: PermissionEdit},
+	{Path: "/finance"},
+	{Path: "/finance/public", Permissions: PermissionRead},
+}
+
+func TestACLPermission(t *testing.T) {
+	var cases = []struct {
+		intention string
+		instance  ACL
+		pathname  string
+		want      Permission
+	}{
+		{
+			"empty",
+			nil,
+			"/marketing",
+			PermissionAll,
+		},
+		{
+			"root",
+			testACL,
+			"/photos/holidays.jpg",
+			PermissionRead,
+		},
+		{
+			"most specific",
+			testACL,
+			"/marketing/logo.png",
+			PermissionEdit,
+		},
+		{
+			"prefix only",
+			testACL,
+			"/marketingTeam",
+			PermissionRead,
+		},
+		{
+			"denied",
+			testACL,
+			"/finance/salaries.csv",
+			0,
+		},
+		{
+			"allowed inside denied",
+			testACL,
+			"/finance/public/report.pdf",
+			PermissionRead,
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.intention, func(t *testing.T) {
+			if result := testCase.instance.Permission(testCase.pathname, PermissionAll); result != testCase.want {
+				t.Errorf("Permission() = %s, want %s", result, testCase.want)
+			}
+		})
+	}
+}
+
+func TestACLVisible(t *testing.T) {
+	var cases = []struct {
+		intention string
+		pathname  string
+		want      bool
+	}{
+		{
+			"allowed",
+			"/marketing",
+			true,
+		},
+		{
+			"leading to allowed path",
+			"/finance",
+			true,
+		},
+		{
+			"denied",
+			"/finance/salaries.csv",
+			false,
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.intention, func(t *testing.T) {
+			if result := testACL.Visible(testCase.pathname, PermissionAll); result != testCase.want {
+				t.Errorf("Visible() = %t, want %t", result, testCase.want)
+			}
+		})
+	}
+}
+
+func TestACLRestricts(t *testing.T) {
+	var cases = []struct {
+		intention  string
+		pathname   string
+		permission Permission
+		want       bool
+	}{
+		{
+			"root",
+			"/",
+			PermissionRead,
+			true,
+		},
+		{
+			"unrestricted directory",
+			"/marketing",
+			PermissionEdit,
+			false,
+		},
+		{
+			"allowed below",
+			"/finance",
+			PermissionRead,
+			false,
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.intention, func(t *testing.T) {
+			if result := testACL.Restricts(testCase.pathname, testCase.permission); result != testCase.want {
+				t.Errorf("Restricts() = %t, want %t", result, testCase.want)
+			}
+		})
+	}
+}
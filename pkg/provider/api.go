@@ -0,0 +1,134 @@
+package provider
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// APIItem is the JSON representation of a storage item
+type APIItem struct {
+	ID           string     `json:"id"`
+	Name         string     `json:"name"`
+	URL          string     `json:"url"`
+	IsDir        bool       `json:"isDir"`
+	Size         int64      `json:"size"`
+	Mime         string     `json:"mime,omitempty"`
+	Date         time.Time  `json:"date"`
+	HasThumbnail bool       `json:"hasThumbnail"`
+	Shares       []APIShare `json:"shares,omitempty"`
+	Versions     []Version  `json:"versions,omitempty"`
+	Message      string     `json:"message,omitempty"`
+}
+
+// APIShare is the JSON representation of a share, without its secrets
+type APIShare struct {
+	ID                string     `json:"id"`
+	Path              string     `json:"path"`
+	RootName          string     `json:"rootName"`
+	Owner             string     `json:"owner,omitempty"`
+	Permissions       Permission `json:"permissions"`
+	File              bool       `json:"file"`
+	Subfolder         bool       `json:"subfolder"`
+	PasswordProtected bool       `json:"passwordProtected"`
+	Expiration        *time.Time `json:"expiration,omitempty"`
+	Views             uint       `json:"views"`
+	Downloads         uint       `json:"downloads"`
+	Bytes             int64      `json:"bytes"`
+	MaxDownloads      uint       `json:"maxDownloads,omitempty"`
+	MaxBytes          int64      `json:"maxBytes,omitempty"`
+}
+
+// APIToken is the JSON representation of an API token, secret being only given on creation
+type APIToken struct {
+	ID          string     `json:"id"`
+	Name        string     `json:"name"`
+	Secret      string     `json:"secret,omitempty"`
+	Owner       string     `json:"owner,omitempty"`
+	Path        string     `json:"path"`
+	Permissions Permission `json:"permissions"`
+	Created     time.Time  `json:"created"`
+	Expiration  *time.Time `json:"expiration,omitempty"`
+}
+
+// APIUpload is the JSON report of an uploaded file
+type APIUpload struct {
+	Name    string   `json:"name"`
+	Status  int      `json:"status"`
+	Message string   `json:"message,omitempty"`
+	Error   string   `json:"error,omitempty"`
+	Item    *APIItem `json:"item,omitempty"`
+}
+
+// APIError is the JSON representation of an error
+type APIError struct {
+	Status int    `json:"status"`
+	Error  string `json:"error"`
+}
+
+// IsJSONRequest checks if request accepts a JSON response
+func IsJSONRequest(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "application/json")
+}
+
+// NewAPIItem creates API representation of given item
+func NewAPIItem(request Request, item RenderItem, hasThumbnail bool) APIItem {
+	uri := request.GetPathnameURI(item.Pathname)
+	if item.IsDir && !strings.HasSuffix(uri, "/") {
+		uri += "/"
+	}
+
+	return APIItem{
+		ID:           item.ID,
+		Name:         item.Name,
+		URL:          uri,
+		IsDir:        item.IsDir,
+		Size:         item.Size,
+		Mime:         item.Mime(),
+		Date:         item.Date,
+		HasThumbnail: hasThumbnail,
+	}
+}
+
+// NewAPIShare creates API representation of given share
+func NewAPIShare(share Share) APIShare {
+	apiShare := APIShare{
+		ID:                share.ID,
+		Path:              share.Path,
+		RootName:          share.RootName,
+		Owner:             share.Owner,
+		Permissions:       share.Permissions,
+		File:              share.File,
+		Subfolder:         share.Subfolder,
+		PasswordProtected: len(share.Password) != 0,
+		Views:             share.Views,
+		Downloads:         share.Downloads,
+		Bytes:             share.Bytes,
+		MaxDownloads:      share.MaxDownloads,
+		MaxBytes:          share.MaxBytes,
+	}
+
+	if !share.Expiration.IsZero() {
+		apiShare.Expiration = &share.Expiration
+	}
+
+	return apiShare
+}
+
+// NewAPIToken creates API representation of given token
+func NewAPIToken(token Token) APIToken {
+	apiToken := APIToken{
+		ID:          token.ID,
+		Name:        token.Name,
+		Owner:       token.Owner,
+		Path:        token.Path,
+		Permissions: token.Permissions,
+		Created:     token.Created,
+	}
+
+	if !token.Expiration.IsZero() {
+		apiToken.Expiration = &token.Expiration
+	}
+
+	return apiToken
+}
@@ -0,0 +1,90 @@
+package provider
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewAPIItem(t *testing.T) {
+	var cases = []struct {
+		intention string
+		request   Request
+		item      RenderItem
+		want      string
+	}{
+		{
+			"file",
+			Request{
+				Path: "/photos/",
+			},
+			RenderItem{
+				StorageItem: StorageItem{Name: "beach.jpg", Pathname: "/photos/beach.jpg"},
+			},
+			"/photos/beach.jpg",
+		},
+		{
+			"directory",
+			Request{
+				Path: "/",
+			},
+			RenderItem{
+				StorageItem: StorageItem{Name: "photos", Pathname: "/photos", IsDir: true},
+			},
+			"/photos/",
+		},
+		{
+			"share",
+			Request{
+				Path: "/",
+				Share: &Share{
+					ID:   "abcdef",
+					Path: "/private/photos",
+				},
+			},
+			RenderItem{
+				StorageItem: StorageItem{Name: "beach.jpg", Pathname: "/private/photos/beach.jpg"},
+			},
+			"/abcdef/beach.jpg",
+		},
+		{
+			"file share",
+			Request{
+				Path: "/",
+				Share: &Share{
+					ID:   "abcdef",
+					Path: "/private/beach.jpg",
+					File: true,
+				},
+			},
+			RenderItem{
+				StorageItem: StorageItem{Name: "beach.jpg", Pathname: "/private/beach.jpg"},
+			},
+			"/abcdef",
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.intention, func(t *testing.T) {
+			if result := NewAPIItem(testCase.request, testCase.item, false); result.URL != testCase.want {
+				t.Errorf("NewAPIItem() = `%s`, want `%s`", result.URL, testCase.want)
+			}
+		})
+	}
+}
+
+func TestNewAPIShare(t *testing.T) {
+	result := NewAPIShare(Share{ID: "abcdef", Path: "/photos", Password: "$2a$12$hash"})
+
+	if !result.PasswordProtected || result.ID != "abcdef" {
+		t.Errorf("NewAPIShare() = %+v, want password protected share without hash", result)
+	}
+
+	if result.Expiration != nil {
+		t.Errorf("NewAPIShare() = %+v, want no expiration", result)
+	}
+
+	expiration := time.Date(2020, 6, 20, 0, 0, 0, 0, time.UTC)
+	if result := NewAPIShare(Share{ID: "abcdef", Expiration: expiration}); result.Expiration == nil || !result.Expiration.Equal(expiration) {
+		t.Errorf("NewAPIShare() = %+v, want expiration", result)
+	}
+}
@@ -0,0 +1,72 @@
+package provider
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+)
+
+const (
+	// CSRFCookieName is the cookie holding anti-CSRF token of browser
+	CSRFCookieName = "fibr_csrf"
+
+	// CSRFFieldName is the form field repeating anti-CSRF token
+	CSRFFieldName = "csrf"
+
+	csrfTokenLength = 32
+)
+
+// NewCSRFToken generates a random anti-CSRF token
+func NewCSRFToken() (string, error) {
+	raw := make([]byte, csrfTokenLength)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(raw), nil
+}
+
+// IsValidCSRFToken checks that given value has the form of an anti-CSRF token
+func IsValidCSRFToken(value string) bool {
+	if len(value) != hex.EncodedLen(csrfTokenLength) {
+		return false
+	}
+
+	_, err := hex.DecodeString(value)
+	return err == nil
+}
+
+// IsSameOrigin checks that Origin, or Referer if absent, targets the requested host
+func IsSameOrigin(r *http.Request) bool {
+	source := r.Header.Get("Origin")
+	if len(source) == 0 {
+		source = r.Header.Get("Referer")
+	}
+
+	if len(source) == 0 {
+		return true
+	}
+
+	sourceURL, err := url.Parse(source)
+	if err != nil || len(sourceURL.Host) == 0 {
+		return false
+	}
+
+	return sourceURL.Host == r.Host || sourceURL.Host == r.Header.Get("X-Forwarded-Host")
+}
+
+// HasCSRFToken checks that form value repeats the anti-CSRF cookie of browser and comes from the same origin
+func HasCSRFToken(r *http.Request) bool {
+	cookie, err := r.Cookie(CSRFCookieName)
+	if err != nil || !IsValidCSRFToken(cookie.Value) {
+		return false
+	}
+
+	if !IsSameOrigin(r) {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(r.FormValue(CSRFFieldName)), []byte(cookie.Value)) == 1
+}
@@ -0,0 +1,122 @@
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Permission is a set of actions allowed on a share
+type Permission uint
+
+const (
+	// PermissionList allows listing directories content
+	PermissionList Permission = 1 << iota
+	// PermissionDownload allows viewing and downloading files
+	PermissionDownload
+	// PermissionUpload allows uploading files and overwriting existing ones
+	PermissionUpload
+	// PermissionCreate allows creating directories
+	PermissionCreate
+	// PermissionRename allows renaming and moving items
+	PermissionRename
+	// PermissionDelete allows deleting items
+	PermissionDelete
+	// PermissionShare allows creating shares from a share
+	PermissionShare
+
+	// PermissionRead is the read-only permission set
+	PermissionRead = PermissionList | PermissionDownload
+	// PermissionEdit is the read-write permission set, without sharing
+	PermissionEdit = PermissionRead | PermissionUpload | PermissionCreate | PermissionRename | PermissionDelete
+	// PermissionAll grants every action
+	PermissionAll = PermissionEdit | PermissionShare
+)
+
+var permissionNames = []struct {
+	permission Permission
+	name       string
+}{
+	{PermissionList, "list"},
+	{PermissionDownload, "download"},
+	{PermissionUpload, "upload"},
+	{PermissionCreate, "create"},
+	{PermissionRename, "rename"},
+	{PermissionDelete, "delete"},
+	{PermissionShare, "share"},
+}
+
+// ParsePermission parses permission from given names
+func ParsePermission(names []string) (Permission, error) {
+	var permission Permission
+
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if len(name) == 0 {
+			continue
+		}
+
+		found := false
+		for _, item := range permissionNames {
+			if item.name == name {
+				permission |= item.permission
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return 0, fmt.Errorf("unknown permission `%s`", name)
+		}
+	}
+
+	return permission, nil
+}
+
+// Has checks if permission contains all given ones
+func (p Permission) Has(other Permission) bool {
+	return p&other == other
+}
+
+// Names gives names of actions allowed by permission
+func (p Permission) Names() []string {
+	names := make([]string, 0)
+
+	for _, item := range permissionNames {
+		if p.Has(item.permission) {
+			names = append(names, item.name)
+		}
+	}
+
+	return names
+}
+
+// UploadOnly checks if permission only allows sending files, without seeing any content
+func (p Permission) UploadOnly() bool {
+	return p.Has(PermissionUpload) && !p.Has(PermissionList) && !p.Has(PermissionDownload)
+}
+
+func (p Permission) String() string {
+	return strings.Join(p.Names(), ",")
+}
+
+// MarshalJSON marshals permission as a list of names
+func (p Permission) MarshalJSON() ([]byte, error) {
+	return json.Marshal(p.Names())
+}
+
+// UnmarshalJSON unmarshals permission from a list of names
+func (p *Permission) UnmarshalJSON(data []byte) error {
+	var names []string
+	if err := json.Unmarshal(data, &names); err != nil {
+		return err
+	}
+
+	permission, err := ParsePermission(names)
+	if err != nil {
+		return err
+	}
+
+	*p = permission
+	return nil
+}
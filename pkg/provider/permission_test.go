@@ -0,0 +1,147 @@
+package provider
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestParsePermission(t *testing.T) {
+	var cases = []struct {
+		intention string
+		names     []string
+		want      Permission
+		wantErr   error
+	}{
+		{
+			"empty",
+			[]string{""},
+			0,
+			nil,
+		},
+		{
+			"read",
+			[]string{"list", "download"},
+			PermissionRead,
+			nil,
+		},
+		{
+			"all",
+			[]string{"list", "download", "upload", "create", "rename", "delete", "share"},
+			PermissionAll,
+			nil,
+		},
+		{
+			"unknown",
+			[]string{"list", "admin"},
+			0,
+			errors.New("unknown permission `admin`"),
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.intention, func(t *testing.T) {
+			result, err := ParsePermission(testCase.names)
+
+			failed := false
+
+			if err == nil && testCase.wantErr != nil {
+				failed = true
+			} else if err != nil && testCase.wantErr == nil {
+				failed = true
+			} else if err != nil && err.Error() != testCase.wantErr.Error() {
+				failed = true
+			} else if result != testCase.want {
+				failed = true
+			}
+
+			if failed {
+				t.Errorf("ParsePermission() = (%s, `%s`), want (%s, `%s`)", result, err, testCase.want, testCase.wantErr)
+			}
+		})
+	}
+}
+
+func TestPermissionUploadOnly(t *testing.T) {
+	var cases = []struct {
+		intention  string
+		permission Permission
+		want       bool
+	}{
+		{
+			"drop box",
+			PermissionUpload | PermissionShare,
+			true,
+		},
+		{
+			"listing",
+			PermissionList | PermissionUpload,
+			false,
+		},
+		{
+			"read",
+			PermissionRead,
+			false,
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.intention, func(t *testing.T) {
+			if result := testCase.permission.UploadOnly(); result != testCase.want {
+				t.Errorf("UploadOnly() = %t, want %t", result, testCase.want)
+			}
+		})
+	}
+}
+
+func TestShareUnmarshalJSON(t *testing.T) {
+	var cases = []struct {
+		intention string
+		input     string
+		want      Permission
+	}{
+		{
+			"permissions",
+			`{"id":"abcdef","permissions":["list","upload"]}`,
+			PermissionList | PermissionUpload,
+		},
+		{
+			"legacy read",
+			`{"id":"abcdef","edit":false}`,
+			PermissionRead,
+		},
+		{
+			"legacy edit",
+			`{"id":"abcdef","edit":true}`,
+			PermissionEdit,
+		},
+		{
+			"legacy upload",
+			`{"id":"abcdef","upload":true,"subfolder":true}`,
+			PermissionUpload,
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.intention, func(t *testing.T) {
+			var share Share
+			if err := json.Unmarshal([]byte(testCase.input), &share); err != nil {
+				t.Fatalf("Unmarshal() = %s", err)
+			}
+
+			if share.ID != "abcdef" || share.Permissions != testCase.want {
+				t.Errorf("Unmarshal() = %+v, want permissions %s", share, testCase.want)
+			}
+
+			output, err := json.Marshal(share)
+			if err != nil {
+				t.Fatalf("Marshal() = %s", err)
+			}
+
+			var result Share
+			if err := json.Unmarshal(output, &result); err != nil || result.Permissions != testCase.want {
+				t.Errorf("Unmarshal(Marshal()) = (%+v, `%v`), want permissions kept", result, err)
+			}
+		})
+	}
+}
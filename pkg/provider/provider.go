@@ -36,6 +36,12 @@ type ReadSeekerCloser interface {
 	Close() error
 }
 
+// WriteAborter is a writer committing its content on Close, or discarding it on Abort
+type WriteAborter interface {
+	io.WriteCloser
+	Abort() error
+}
+
 // Renderer interface for return rich content to user
 type Renderer interface {
 	Directory(http.ResponseWriter, Request, map[string]interface{}, *Message)
@@ -50,7 +56,7 @@ type Storage interface {
 	SetIgnoreFn(ignoreFn func(StorageItem) bool)
 	Info(pathname string) (StorageItem, error)
 	List(pathname string) ([]StorageItem, error)
-	WriterTo(pathname string) (io.WriteCloser, error)
+	WriterTo(pathname string) (WriteAborter, error)
 	ReaderFrom(pathname string) (ReadSeekerCloser, error)
 	Walk(pathname string, walkFn func(StorageItem, error) error) error
 	CreateDir(pathname string) error
@@ -42,7 +42,7 @@ func (s Storage) Info(pathname string) (provider.StorageItem, error) {
 }
 
 // WriterTo fakes implementation
-func (s Storage) WriterTo(pathname string) (io.WriteCloser, error) {
+func (s Storage) WriterTo(pathname string) (provider.WriteAborter, error) {
 	if strings.HasSuffix(pathname, "error") {
 		return nil, errors.New("error on writer to")
 	}
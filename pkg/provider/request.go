@@ -2,6 +2,7 @@ package provider
 
 import (
 	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"mime"
 	"path"
@@ -11,30 +12,114 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+var (
+	// ErrShareExpired occurs when a share is used after its expiration
+	ErrShareExpired = errors.New("this share has expired")
+)
+
 // Request from user
 type Request struct {
-	Path     string
-	CanEdit  bool
-	CanShare bool
-	Display  string
-	Share    *Share
+	Path        string
+	Permissions Permission
+	Display     string
+	Share       *Share
+	User        string
+	Home        string
+	ACL         ACL
+	Admin       bool
+	JSON        bool
+	CSRF        string
+}
+
+// Can checks if request is allowed to perform given actions
+func (r Request) Can(permission Permission) bool {
+	return r.Permissions.Has(permission)
+}
+
+// CanList checks if request is allowed to list directories
+func (r Request) CanList() bool {
+	return r.Can(PermissionList)
+}
+
+// CanDownload checks if request is allowed to view and download files
+func (r Request) CanDownload() bool {
+	return r.Can(PermissionDownload)
+}
+
+// CanUpload checks if request is allowed to upload files
+func (r Request) CanUpload() bool {
+	return r.Can(PermissionUpload)
+}
+
+// CanCreate checks if request is allowed to create directories
+func (r Request) CanCreate() bool {
+	return r.Can(PermissionCreate)
+}
+
+// CanRename checks if request is allowed to rename items
+func (r Request) CanRename() bool {
+	return r.Can(PermissionRename)
+}
+
+// CanDelete checks if request is allowed to delete items
+func (r Request) CanDelete() bool {
+	return r.Can(PermissionDelete)
+}
+
+// CanShare checks if request is allowed to create shares
+func (r Request) CanShare() bool {
+	return r.Can(PermissionShare)
+}
+
+// CanEdit checks if request is allowed to perform any change
+func (r Request) CanEdit() bool {
+	return r.CanUpload() || r.CanCreate() || r.CanRename() || r.CanDelete()
+}
+
+// PermissionOn gives permission of request on given storage pathname, according to its access control list
+func (r Request) PermissionOn(pathname string) Permission {
+	return r.ACL.Permission(pathname, r.Permissions)
+}
+
+// IsVisible checks if given storage pathname can be seen by request
+func (r Request) IsVisible(pathname string) bool {
+	return r.ACL.Visible(pathname, r.Permissions)
+}
+
+// FilterVisible removes items that can't be seen by request
+func (r Request) FilterVisible(items []StorageItem) []StorageItem {
+	if len(r.ACL) == 0 {
+		return items
+	}
+
+	visibles := make([]StorageItem, 0, len(items))
+	for _, item := range items {
+		if r.IsVisible(item.Pathname) {
+			visibles = append(visibles, item)
+		}
+	}
+
+	return visibles
+}
+
+// UploadOnly checks if request comes from a drop box share, where visitors can only upload
+func (r Request) UploadOnly() bool {
+	return r.Share != nil && r.Permissions.UploadOnly()
 }
 
 // GetFilepath of request
 func (r Request) GetFilepath(name string) string {
-	parts := make([]string, 0)
-
+	root := r.Home
 	if r.Share != nil {
-		parts = append(parts, r.Share.Path)
+		root = r.Share.Path
 	}
 
-	parts = append(parts, r.Path)
-
-	if len(name) > 0 {
-		parts = append(parts, name)
+	if len(root) == 0 {
+		return path.Join(r.Path, name)
 	}
 
-	return path.Join(parts...)
+	// relative path is resolved from "/" so it can't escape its root
+	return path.Join(root, path.Join("/", r.Path, name))
 }
 
 // GetURI of request
@@ -54,14 +139,85 @@ func (r Request) GetURI(name string) string {
 	return path.Join(parts...)
 }
 
+// GetPathnameURI gives URI of given storage pathname for request
+func (r Request) GetPathnameURI(pathname string) string {
+	if r.Share == nil {
+		if len(r.Home) != 0 {
+			return path.Join("/", strings.TrimPrefix(pathname, r.Home))
+		}
+
+		return pathname
+	}
+
+	return path.Join("/", r.Share.ID, strings.TrimPrefix(pathname, r.Share.Path))
+}
+
 // Share stores informations about shared paths
 type Share struct {
-	ID       string `json:"id"`
-	Path     string `json:"path"`
-	RootName string `json:"rootName"`
-	Edit     bool   `json:"edit"`
-	Password string `json:"password"`
-	File     bool   `json:"file"`
+	ID          string     `json:"id"`
+	Path        string     `json:"path"`
+	RootName    string     `json:"rootName"`
+	Owner       string     `json:"owner,omitempty"`
+	Permissions Permission `json:"permissions"`
+	Password    string     `json:"password"`
+	File        bool       `json:"file"`
+	Subfolder   bool       `json:"subfolder"`
+	Expiration  time.Time  `json:"expiration"`
+
+	Views        uint  `json:"views"`
+	Downloads    uint  `json:"downloads"`
+	Bytes        int64 `json:"bytes"`
+	MaxDownloads uint  `json:"maxDownloads"`
+	MaxBytes     int64 `json:"maxBytes"`
+}
+
+// TrashItem stores informations about a deleted item waiting for restore or purge
+type TrashItem struct {
+	ID    string    `json:"id"`
+	Name  string    `json:"name"`
+	Path  string    `json:"path"`
+	IsDir bool      `json:"isDir"`
+	Date  time.Time `json:"date"`
+}
+
+// UnmarshalJSON unmarshals share, converting edit and upload flags of previous versions into permissions
+func (s *Share) UnmarshalJSON(data []byte) error {
+	type shareAlias Share
+
+	content := struct {
+		*shareAlias
+		Edit   bool `json:"edit"`
+		Upload bool `json:"upload"`
+	}{
+		shareAlias: (*shareAlias)(s),
+	}
+
+	if err := json.Unmarshal(data, &content); err != nil {
+		return err
+	}
+
+	if s.Permissions == 0 {
+		switch {
+		case content.Upload:
+			s.Permissions = PermissionUpload
+		case content.Edit:
+			s.Permissions = PermissionEdit
+		default:
+			s.Permissions = PermissionRead
+		}
+	}
+
+	return nil
+}
+
+// IsExpired checks if share has an expiration in the past of given time
+func (s Share) IsExpired(now time.Time) bool {
+	return !s.Expiration.IsZero() && now.After(s.Expiration)
+}
+
+// IsLimited checks if share has a download or bytes limit
+func (s Share) IsLimited() bool {
+	return s.MaxDownloads != 0 || s.MaxBytes != 0
 }
 
 // CheckPassword verifies that request has correct password for share
@@ -94,6 +250,13 @@ func (s Share) CheckPassword(authorizationHeader string) error {
 	return nil
 }
 
+// Version stores informations about a previous content of a file
+type Version struct {
+	ID   string    `json:"id"`
+	Size int64     `json:"size"`
+	Date time.Time `json:"date"`
+}
+
 // Config data
 type Config struct {
 	PublicURL string
@@ -140,6 +303,7 @@ type StorageItem struct {
 	Name     string
 	IsDir    bool
 	Date     time.Time
+	Size     int64
 
 	Info interface{}
 }
@@ -44,6 +44,24 @@ func TestGetFilepath(t *testing.T) {
 			"root.html",
 			"/shared/index/root.html",
 		},
+		{
+			"with home",
+			Request{
+				Path: "/photos/",
+				Home: "/home/alice",
+			},
+			"beach.jpg",
+			"/home/alice/photos/beach.jpg",
+		},
+		{
+			"escaping home",
+			Request{
+				Path: "/../bob/",
+				Home: "/home/alice",
+			},
+			"../secret.txt",
+			"/home/alice/secret.txt",
+		},
 	}
 
 	for _, testCase := range cases {
@@ -101,6 +119,49 @@ func TestGetURI(t *testing.T) {
 	}
 }
 
+func TestGetPathnameURI(t *testing.T) {
+	var cases = []struct {
+		intention string
+		request   Request
+		pathname  string
+		want      string
+	}{
+		{
+			"simple",
+			Request{},
+			"/photos/beach.jpg",
+			"/photos/beach.jpg",
+		},
+		{
+			"with share",
+			Request{
+				Share: &Share{
+					ID:   "abcd1234",
+					Path: "/photos",
+				},
+			},
+			"/photos/beach.jpg",
+			"/abcd1234/beach.jpg",
+		},
+		{
+			"with home",
+			Request{
+				Home: "/home/alice",
+			},
+			"/home/alice/photos/beach.jpg",
+			"/photos/beach.jpg",
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.intention, func(t *testing.T) {
+			if result := testCase.request.GetPathnameURI(testCase.pathname); result != testCase.want {
+				t.Errorf("GetPathnameURI() = `%s`, want `%s`", result, testCase.want)
+			}
+		})
+	}
+}
+
 func TestCheckPassword(t *testing.T) {
 	password, err := bcrypt.GenerateFromPassword([]byte("test"), bcrypt.DefaultCost)
 	if err != nil {
@@ -1,10 +1,8 @@
-package filesystem
+package provider
 
 import (
 	"strings"
 	"time"
-
-	"github.com/ViBiOh/fibr/pkg/provider"
 )
 
 func lessString(first, second string) bool {
@@ -16,7 +14,7 @@ func moreTime(first, second time.Time) bool {
 }
 
 // ByHybridSort implements Sorter by type, name then modification time
-type ByHybridSort []provider.StorageItem
+type ByHybridSort []StorageItem
 
 func (a ByHybridSort) Len() int {
 	return len(a)
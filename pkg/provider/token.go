@@ -0,0 +1,41 @@
+package provider
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+// TokenPrefix is the prefix of every API token secret, easing their detection by secret scanners
+const TokenPrefix = "fibr_"
+
+// Token is an API token, acting with given permissions on given path
+type Token struct {
+	ID          string     `json:"id"`
+	Name        string     `json:"name"`
+	Hash        string     `json:"hash"`
+	Owner       string     `json:"owner,omitempty"`
+	Path        string     `json:"path"`
+	Permissions Permission `json:"permissions"`
+	Created     time.Time  `json:"created"`
+	Expiration  time.Time  `json:"expiration,omitempty"`
+}
+
+// HashToken hashes given token secret, for storing it
+func HashToken(secret string) string {
+	hash := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(hash[:])
+}
+
+// IsExpired checks if token is expired at given time
+func (t Token) IsExpired(now time.Time) bool {
+	return !t.Expiration.IsZero() && now.After(t.Expiration)
+}
+
+// ACL gives access control list of token, restricting its permissions to its path. Token's rule comes first for winning when path is root
+func (t Token) ACL() ACL {
+	return ACL{
+		{Path: t.Path, Permissions: t.Permissions},
+		{Path: "/"},
+	}
+}
@@ -47,6 +47,23 @@ func SafeWrite(w io.Writer, content string) {
 	}
 }
 
+// HumanSize formats given bytes size with binary unit
+func HumanSize(size int64) string {
+	const unit = 1024
+
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
 // ErrNotExist create a NotExist error
 func ErrNotExist(err error) error {
 	return fmt.Errorf("path not found: %w", err)
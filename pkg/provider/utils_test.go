@@ -73,6 +73,38 @@ func TestSanitizeName(t *testing.T) {
 	}
 }
 
+func TestHumanSize(t *testing.T) {
+	var cases = []struct {
+		intention string
+		input     int64
+		want      string
+	}{
+		{
+			"bytes",
+			512,
+			"512 B",
+		},
+		{
+			"kibibytes",
+			1536,
+			"1.5 KiB",
+		},
+		{
+			"gibibytes",
+			5 * 1024 * 1024 * 1024,
+			"5.0 GiB",
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.intention, func(t *testing.T) {
+			if result := HumanSize(testCase.input); result != testCase.want {
+				t.Errorf("HumanSize(%d) = `%s`, want `%s`", testCase.input, result, testCase.want)
+			}
+		})
+	}
+}
+
 func TestIsNotExist(t *testing.T) {
 	var cases = []struct {
 		intention string
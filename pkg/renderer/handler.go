@@ -6,6 +6,7 @@ import (
 
 	"github.com/ViBiOh/fibr/pkg/provider"
 	"github.com/ViBiOh/httputils/v3/pkg/httperror"
+	"github.com/ViBiOh/httputils/v3/pkg/httpjson"
 	"github.com/ViBiOh/httputils/v3/pkg/logger"
 	"github.com/ViBiOh/httputils/v3/pkg/templates"
 )
@@ -16,10 +17,20 @@ func (a app) newPageBuilder() *provider.PageBuilder {
 
 // Directory render directory listing
 func (a app) Directory(w http.ResponseWriter, request provider.Request, content map[string]interface{}, message *provider.Message) {
+	if request.JSON {
+		a.directoryJSON(w, request, content)
+		return
+	}
+
 	page := a.newPageBuilder().Request(request).Message(message).Layout(request.Display).Content(content).Build()
 
+	templateName := "files"
+	if request.UploadOnly() {
+		templateName = "drop"
+	}
+
 	w.Header().Set("content-language", "en")
-	if err := templates.ResponseHTMLTemplate(a.tpl.Lookup("files"), w, page, http.StatusOK); err != nil {
+	if err := templates.ResponseHTMLTemplate(a.tpl.Lookup(templateName), w, page, http.StatusOK); err != nil {
 		a.Error(w, request, provider.NewError(http.StatusInternalServerError, err))
 		return
 	}
@@ -27,6 +38,11 @@ func (a app) Directory(w http.ResponseWriter, request provider.Request, content
 
 // File render file detail
 func (a app) File(w http.ResponseWriter, request provider.Request, content map[string]interface{}, message *provider.Message) {
+	if request.JSON {
+		a.fileJSON(w, request, content)
+		return
+	}
+
 	page := a.newPageBuilder().Request(request).Message(message).Layout("browser").Content(content).Build()
 
 	w.Header().Set("content-language", "en")
@@ -44,6 +60,14 @@ func (a app) Error(w http.ResponseWriter, request provider.Request, err *provide
 		w.Header().Add("WWW-Authenticate", `Basic realm="Password required" charset="UTF-8"`)
 	}
 
+	if request.JSON {
+		httpjson.ResponseJSON(w, err.Status, provider.APIError{
+			Status: err.Status,
+			Error:  err.Err.Error(),
+		}, false)
+		return
+	}
+
 	page := a.newPageBuilder().Request(request).Error(err).Build()
 
 	if err := templates.ResponseHTMLTemplate(a.tpl.Lookup("error"), w, page, err.Status); err != nil {
@@ -0,0 +1,73 @@
+package renderer
+
+import (
+	"net/http"
+
+	"github.com/ViBiOh/fibr/pkg/provider"
+	"github.com/ViBiOh/fibr/pkg/thumbnail"
+	"github.com/ViBiOh/httputils/v3/pkg/httpjson"
+)
+
+func hasThumbnail(thumbnailApp thumbnail.App, item provider.RenderItem) bool {
+	return thumbnail.CanHaveThumbnail(item.StorageItem) && thumbnailApp.HasThumbnail(item.StorageItem)
+}
+
+func (a app) newAPIItem(request provider.Request, item provider.RenderItem, shares []*provider.Share) provider.APIItem {
+	apiItem := provider.NewAPIItem(request, item, hasThumbnail(a.thumbnail, item))
+
+	for _, share := range shares {
+		if share.Path == item.Pathname {
+			apiItem.Shares = append(apiItem.Shares, provider.NewAPIShare(*share))
+		}
+	}
+
+	return apiItem
+}
+
+func (a app) directoryJSON(w http.ResponseWriter, request provider.Request, content map[string]interface{}) {
+	files, _ := content["Files"].([]provider.RenderItem)
+	shares, _ := content["Shares"].([]*provider.Share)
+
+	items := make([]provider.APIItem, len(files))
+	for index, file := range files {
+		items[index] = a.newAPIItem(request, file, shares)
+	}
+
+	output := map[string]interface{}{
+		"path":  request.GetURI(""),
+		"files": items,
+	}
+
+	if request.CanShare() {
+		apiShares := make([]provider.APIShare, len(shares))
+		for index, share := range shares {
+			apiShares[index] = provider.NewAPIShare(*share)
+		}
+
+		output["shares"] = apiShares
+	}
+
+	if tokens, ok := content["Tokens"].([]provider.APIToken); ok {
+		output["tokens"] = tokens
+	}
+
+	if trash, ok := content["Trash"].([]provider.TrashItem); ok {
+		output["trash"] = trash
+	}
+
+	httpjson.ResponseJSON(w, http.StatusOK, output, false)
+}
+
+func (a app) fileJSON(w http.ResponseWriter, request provider.Request, content map[string]interface{}) {
+	file, _ := content["File"].(provider.RenderItem)
+
+	var shares []*provider.Share
+	if request.CanShare() {
+		shares, _ = content["Shares"].([]*provider.Share)
+	}
+
+	apiItem := a.newAPIItem(request, file, shares)
+	apiItem.Versions, _ = content["Versions"].([]provider.Version)
+
+	httpjson.ResponseJSON(w, http.StatusOK, apiItem, false)
+}
@@ -32,8 +32,9 @@ type Config struct {
 }
 
 type app struct {
-	config provider.Config
-	tpl    *template.Template
+	config    provider.Config
+	tpl       *template.Template
+	thumbnail thumbnail.App
 }
 
 // Flags adds flags for configuring package
@@ -48,10 +49,19 @@ func Flags(fs *flag.FlagSet, prefix string) Config {
 // New creates new App from Config
 func New(config Config, thumbnailApp thumbnail.App) App {
 	tpl := template.New("fibr").Funcs(template.FuncMap{
-		"asyncImage": func(file provider.RenderItem, version string) map[string]interface{} {
+		"asyncImage": func(file provider.RenderItem) map[string]interface{} {
+			thumbnailURL, srcset := thumbnailApp.URLs(file.StorageItem)
+
+			return map[string]interface{}{
+				"File":   file,
+				"URL":    thumbnailURL,
+				"Srcset": srcset,
+			}
+		},
+		"modal": func(file provider.RenderItem, csrf string) map[string]interface{} {
 			return map[string]interface{}{
-				"File":    file,
-				"Version": version,
+				"File": file,
+				"CSRF": csrf,
 			}
 		},
 		"rebuildPaths": func(parts []string, index int) string {
@@ -82,8 +92,9 @@ func New(config Config, thumbnailApp thumbnail.App) App {
 			}
 		},
 		"hasThumbnail": func(item provider.RenderItem) bool {
-			return thumbnail.CanHaveThumbnail(item.StorageItem) && thumbnailApp.HasThumbnail(item.StorageItem)
+			return hasThumbnail(thumbnailApp, item)
 		},
+		"humanSize": provider.HumanSize,
 	})
 
 	fibrTemplates, err := templates.GetTemplates(strings.TrimSpace(*config.templates), ".html")
@@ -93,7 +104,8 @@ func New(config Config, thumbnailApp thumbnail.App) App {
 	imgSize := uint(512)
 
 	return app{
-		tpl: template.Must(tpl.ParseFiles(fibrTemplates...)),
+		tpl:       template.Must(tpl.ParseFiles(fibrTemplates...)),
+		thumbnail: thumbnailApp,
 		config: provider.Config{
 			PublicURL: publicURL,
 			Version:   *config.version,
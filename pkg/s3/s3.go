@@ -0,0 +1,510 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ViBiOh/fibr/pkg/provider"
+	"github.com/ViBiOh/httputils/v3/pkg/flags"
+	"github.com/ViBiOh/httputils/v3/pkg/logger"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+const (
+	// partSize bounds memory used when streaming content of unknown size
+	partSize = 16 << 20
+)
+
+// maxCopySize is the largest object S3 copies in a single request, larger ones being copied in parts
+var maxCopySize int64 = 5 << 30
+
+var (
+	// ErrRelativePath occurs when path is relative (contains ".."")
+	ErrRelativePath = errors.New("pathname contains relatives paths")
+
+	errAborted = errors.New("upload aborted")
+)
+
+// Config of package
+type Config struct {
+	endpoint  *string
+	accessKey *string
+	secretKey *string
+	bucket    *string
+	region    *string
+	useSSL    *bool
+}
+
+type app struct {
+	client *minio.Client
+	bucket string
+
+	ignoreFn func(provider.StorageItem) bool
+}
+
+// Flags adds flags for configuring package
+func Flags(fs *flag.FlagSet, prefix string) Config {
+	return Config{
+		endpoint:  flags.New(prefix, "s3").Name("Endpoint").Default("").Label("Storage Object endpoint").ToString(fs),
+		accessKey: flags.New(prefix, "s3").Name("AccessKey").Default("").Label("Storage Object Access Key").ToString(fs),
+		secretKey: flags.New(prefix, "s3").Name("SecretAccess").Default("").Label("Storage Object Secret Access").ToString(fs),
+		bucket:    flags.New(prefix, "s3").Name("Bucket").Default("").Label("Storage Object Bucket").ToString(fs),
+		region:    flags.New(prefix, "s3").Name("Region").Default("us-east-1").Label("Storage Object Region").ToString(fs),
+		useSSL:    flags.New(prefix, "s3").Name("SSL").Default(true).Label("Use SSL").ToBool(fs),
+	}
+}
+
+// New creates new App from Config
+func New(config Config) (provider.Storage, error) {
+	endpoint := strings.TrimSpace(*config.endpoint)
+	if len(endpoint) == 0 {
+		return nil, errors.New("no endpoint provided")
+	}
+
+	bucket := strings.TrimSpace(*config.bucket)
+	if len(bucket) == 0 {
+		return nil, errors.New("no bucket provided")
+	}
+
+	client, err := minio.New(endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(strings.TrimSpace(*config.accessKey), *config.secretKey, ""),
+		Secure: *config.useSSL,
+		Region: strings.TrimSpace(*config.region),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to create minio client: %s", err)
+	}
+
+	exists, err := client.BucketExists(context.Background(), bucket)
+	if err != nil {
+		return nil, fmt.Errorf("unable to check bucket %s: %s", bucket, err)
+	}
+
+	if !exists {
+		return nil, fmt.Errorf("bucket %s does not exist", bucket)
+	}
+
+	logger.Info("Serving file from %s/%s", endpoint, bucket)
+
+	return &app{
+		client: client,
+		bucket: bucket,
+	}, nil
+}
+
+func (a *app) SetIgnoreFn(ignoreFn func(provider.StorageItem) bool) {
+	a.ignoreFn = ignoreFn
+}
+
+// Info provide metadata about given pathname
+func (a *app) Info(pathname string) (provider.StorageItem, error) {
+	if err := checkPathname(pathname); err != nil {
+		return provider.StorageItem{}, err
+	}
+
+	key := getKey(pathname)
+	if len(key) == 0 {
+		return provider.StorageItem{
+			Pathname: "/",
+			Name:     "/",
+			IsDir:    true,
+		}, nil
+	}
+
+	ctx := context.Background()
+
+	info, err := a.client.StatObject(ctx, a.bucket, key, minio.StatObjectOptions{})
+	if err == nil {
+		return convertToItem(info), nil
+	}
+
+	if !isNotExist(err) {
+		return provider.StorageItem{}, convertError(err)
+	}
+
+	dirKey := getDirKey(key)
+	if info, err = a.client.StatObject(ctx, a.bucket, dirKey, minio.StatObjectOptions{}); err == nil {
+		return convertToItem(info), nil
+	} else if !isNotExist(err) {
+		return provider.StorageItem{}, convertError(err)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	for object := range a.client.ListObjects(ctx, a.bucket, minio.ListObjectsOptions{Prefix: dirKey, MaxKeys: 1}) {
+		if object.Err != nil {
+			return provider.StorageItem{}, convertError(object.Err)
+		}
+
+		return convertToItem(minio.ObjectInfo{Key: dirKey}), nil
+	}
+
+	return provider.StorageItem{}, provider.ErrNotExist(fmt.Errorf("%s: no such key", pathname))
+}
+
+// List items in the storage
+func (a *app) List(pathname string) ([]provider.StorageItem, error) {
+	if err := checkPathname(pathname); err != nil {
+		return nil, err
+	}
+
+	prefix := getKey(pathname)
+	if len(prefix) != 0 {
+		prefix = getDirKey(prefix)
+	}
+
+	items := make([]provider.StorageItem, 0)
+	for object := range a.client.ListObjects(context.Background(), a.bucket, minio.ListObjectsOptions{Prefix: prefix}) {
+		if object.Err != nil {
+			return nil, convertError(object.Err)
+		}
+
+		if object.Key == prefix {
+			continue
+		}
+
+		item := convertToItem(object)
+		if a.ignoreFn != nil && a.ignoreFn(item) {
+			continue
+		}
+
+		items = append(items, item)
+	}
+
+	if len(items) == 0 && len(prefix) != 0 {
+		if _, err := a.Info(pathname); err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Sort(provider.ByHybridSort(items))
+
+	return items, nil
+}
+
+// WriterTo opens writer for given pathname
+func (a *app) WriterTo(pathname string) (provider.WriteAborter, error) {
+	if err := checkPathname(pathname); err != nil {
+		return nil, err
+	}
+
+	reader, writer := io.Pipe()
+	output := &objectWriter{
+		PipeWriter: writer,
+		done:       make(chan error, 1),
+	}
+
+	go func() {
+		err := a.put(getKey(pathname), reader)
+		if closeErr := reader.CloseWithError(err); closeErr != nil {
+			logger.Error("unable to close pipe: %s", closeErr)
+		}
+
+		output.done <- err
+	}()
+
+	return output, nil
+}
+
+// ReaderFrom reads content from given pathname
+func (a *app) ReaderFrom(pathname string) (provider.ReadSeekerCloser, error) {
+	if err := checkPathname(pathname); err != nil {
+		return nil, err
+	}
+
+	object, err := a.client.GetObject(context.Background(), a.bucket, getKey(pathname), minio.GetObjectOptions{})
+	if err != nil {
+		return nil, convertError(err)
+	}
+
+	if _, err := object.Stat(); err != nil {
+		if closeErr := object.Close(); closeErr != nil {
+			logger.Error("unable to close object: %s", closeErr)
+		}
+
+		return nil, convertError(err)
+	}
+
+	return object, nil
+}
+
+// Walk browses item recursively
+func (a *app) Walk(pathname string, walkFn func(provider.StorageItem, error) error) error {
+	if err := checkPathname(pathname); err != nil {
+		return err
+	}
+
+	root, err := a.Info(pathname)
+	if err != nil {
+		return walkFn(provider.StorageItem{}, err)
+	}
+
+	if err := walkFn(root, nil); err != nil || !root.IsDir {
+		return err
+	}
+
+	prefix := getKey(pathname)
+	if len(prefix) != 0 {
+		prefix = getDirKey(prefix)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	walked := map[string]bool{prefix: true}
+	ignored := make([]string, 0)
+
+	isIgnored := func(key string) bool {
+		for _, ignoredPrefix := range ignored {
+			if strings.HasPrefix(key, ignoredPrefix) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	for object := range a.client.ListObjects(ctx, a.bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if object.Err != nil {
+			return walkFn(provider.StorageItem{}, convertError(object.Err))
+		}
+
+		for _, dirKey := range getParentDirKeys(prefix, object.Key) {
+			if walked[dirKey] || isIgnored(dirKey) {
+				continue
+			}
+			walked[dirKey] = true
+
+			item := convertToItem(minio.ObjectInfo{Key: dirKey})
+			if a.ignoreFn != nil && a.ignoreFn(item) {
+				ignored = append(ignored, dirKey)
+				continue
+			}
+
+			if err := walkFn(item, nil); err != nil {
+				return err
+			}
+		}
+
+		if walked[object.Key] || isIgnored(object.Key) {
+			continue
+		}
+
+		item := convertToItem(object)
+		if a.ignoreFn != nil && a.ignoreFn(item) {
+			continue
+		}
+
+		if err := walkFn(item, nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// CreateDir container in storage
+func (a *app) CreateDir(name string) error {
+	if err := checkPathname(name); err != nil {
+		return err
+	}
+
+	key := getKey(name)
+	if len(key) == 0 {
+		return nil
+	}
+
+	return a.put(getDirKey(key), strings.NewReader(""))
+}
+
+// Store file to storage
+func (a *app) Store(pathname string, content io.ReadCloser) error {
+	if err := checkPathname(pathname); err != nil {
+		return err
+	}
+
+	return a.put(getKey(pathname), content)
+}
+
+// Rename file or directory from storage
+func (a *app) Rename(oldName, newName string) error {
+	if err := checkPathname(oldName); err != nil {
+		return err
+	}
+
+	if err := checkPathname(newName); err != nil {
+		return err
+	}
+
+	info, err := a.Info(oldName)
+	if err != nil {
+		return err
+	}
+
+	oldKey := getKey(oldName)
+	newKey := getKey(newName)
+
+	if !info.IsDir {
+		if err := a.copy(oldKey, newKey, info.Size); err != nil {
+			return err
+		}
+
+		return a.remove(oldKey)
+	}
+
+	oldPrefix := getDirKey(oldKey)
+	newPrefix := getDirKey(newKey)
+
+	objects, err := a.listObjects(oldPrefix)
+	if err != nil {
+		return err
+	}
+
+	for _, object := range objects {
+		if err := a.copy(object.Key, newPrefix+strings.TrimPrefix(object.Key, oldPrefix), object.Size); err != nil {
+			return err
+		}
+	}
+
+	for _, object := range objects {
+		if err := a.remove(object.Key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Remove file or directory from storage
+func (a *app) Remove(pathname string) error {
+	if err := checkPathname(pathname); err != nil {
+		return err
+	}
+
+	key := getKey(pathname)
+	if len(key) == 0 {
+		return errors.New("unable to remove root of bucket")
+	}
+
+	if err := a.remove(key); err != nil && !isNotExist(err) {
+		return convertError(err)
+	}
+
+	keys, err := a.listKeys(getDirKey(key))
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		if err := a.remove(key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (a *app) put(key string, content io.Reader) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+
+	_, err := a.client.PutObject(ctx, a.bucket, key, content, -1, minio.PutObjectOptions{PartSize: partSize})
+	return convertError(err)
+}
+
+// copy duplicates object of given size, with a multipart copy above maxCopySize that a single copy rejects
+func (a *app) copy(oldKey, newKey string, size int64) error {
+	dst := minio.CopyDestOptions{Bucket: a.bucket, Object: newKey}
+	src := minio.CopySrcOptions{Bucket: a.bucket, Object: oldKey}
+
+	if size > maxCopySize {
+		_, err := a.client.ComposeObject(context.Background(), dst, src)
+		return convertError(err)
+	}
+
+	_, err := a.client.CopyObject(context.Background(), dst, src)
+	return convertError(err)
+}
+
+func (a *app) remove(key string) error {
+	return convertError(a.client.RemoveObject(context.Background(), a.bucket, key, minio.RemoveObjectOptions{}))
+}
+
+func (a *app) listKeys(prefix string) ([]string, error) {
+	objects, err := a.listObjects(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, len(objects))
+	for index, object := range objects {
+		keys[index] = object.Key
+	}
+
+	return keys, nil
+}
+
+func (a *app) listObjects(prefix string) ([]minio.ObjectInfo, error) {
+	objects := make([]minio.ObjectInfo, 0)
+
+	for object := range a.client.ListObjects(context.Background(), a.bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if object.Err != nil {
+			return nil, convertError(object.Err)
+		}
+
+		objects = append(objects, object)
+	}
+
+	return objects, nil
+}
+
+type objectWriter struct {
+	*io.PipeWriter
+	done chan error
+}
+
+// Close flushes content and waits for object to be stored
+func (w *objectWriter) Close() error {
+	if err := w.PipeWriter.Close(); err != nil {
+		return err
+	}
+
+	return <-w.done
+}
+
+// Abort interrupts upload, object being not stored
+func (w *objectWriter) Abort() error {
+	if err := w.PipeWriter.CloseWithError(errAborted); err != nil {
+		return err
+	}
+
+	<-w.done
+
+	return nil
+}
+
+func getParentDirKeys(prefix, key string) []string {
+	parts := strings.Split(strings.TrimPrefix(key, prefix), "/")
+	dirKeys := make([]string, 0, len(parts))
+
+	current := prefix
+	for _, part := range parts[:len(parts)-1] {
+		current = fmt.Sprintf("%s%s/", current, part)
+		dirKeys = append(dirKeys, current)
+	}
+
+	return dirKeys
+}
+
+func getKey(pathname string) string {
+	return strings.TrimPrefix(path.Clean(fmt.Sprintf("/%s", pathname)), "/")
+}
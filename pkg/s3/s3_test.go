@@ -0,0 +1,251 @@
+package s3
+
+import (
+	"io"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ViBiOh/fibr/pkg/provider"
+	"github.com/ViBiOh/fibr/pkg/s3/s3test"
+)
+
+func newTestStorage(t *testing.T, server *s3test.Server) provider.Storage {
+	endpoint := server.Endpoint()
+	accessKey := "access"
+	secretKey := "secret"
+	bucket := "fibr"
+	region := "us-east-1"
+	useSSL := false
+
+	storage, err := New(Config{
+		endpoint:  &endpoint,
+		accessKey: &accessKey,
+		secretKey: &secretKey,
+		bucket:    &bucket,
+		region:    &region,
+		useSSL:    &useSSL,
+	})
+	if err != nil {
+		t.Fatalf("unable to create storage: %s", err)
+	}
+
+	return storage
+}
+
+func store(t *testing.T, storage provider.Storage, pathname, content string) {
+	if err := storage.Store(pathname, ioutil.NopCloser(strings.NewReader(content))); err != nil {
+		t.Fatalf("unable to store %s: %s", pathname, err)
+	}
+}
+
+func listNames(items []provider.StorageItem) []string {
+	names := make([]string, len(items))
+	for index, item := range items {
+		names[index] = item.Name
+	}
+
+	return names
+}
+
+func TestGetParentDirKeys(t *testing.T) {
+	var cases = []struct {
+		intention string
+		prefix    string
+		key       string
+		want      []string
+	}{
+		{
+			"root file",
+			"",
+			"file.txt",
+			[]string{},
+		},
+		{
+			"nested file",
+			"",
+			"photos/2020/file.txt",
+			[]string{"photos/", "photos/2020/"},
+		},
+		{
+			"prefixed",
+			"photos/",
+			"photos/2020/file.txt",
+			[]string{"photos/2020/"},
+		},
+		{
+			"directory marker",
+			"",
+			"photos/",
+			[]string{"photos/"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.intention, func(t *testing.T) {
+			if got := getParentDirKeys(tc.prefix, tc.key); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("getParentDirKeys() = %#v, want %#v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestStorage(t *testing.T) {
+	server := s3test.New("fibr")
+	defer server.Close()
+
+	storage := newTestStorage(t, server)
+	storage.SetIgnoreFn(func(item provider.StorageItem) bool {
+		return item.IsDir && item.Name == provider.MetadataDirectoryName
+	})
+
+	if err := storage.CreateDir("/photos"); err != nil {
+		t.Fatalf("CreateDir() = %s", err)
+	}
+	if err := storage.CreateDir(provider.MetadataDirectoryName); err != nil {
+		t.Fatalf("CreateDir() = %s", err)
+	}
+	store(t, storage, "/readme.md", "hello world")
+	store(t, storage, "/photos/2020/beach.jpg", "beach")
+	store(t, storage, "/.fibr/photos/2020/beach.jpg", "thumbnail")
+
+	t.Run("info", func(t *testing.T) {
+		item, err := storage.Info("/readme.md")
+		if err != nil {
+			t.Fatalf("Info() = %s", err)
+		}
+
+		if item.IsDir || item.Name != "readme.md" || item.Pathname != "/readme.md" || item.Size != 11 {
+			t.Errorf("Info() = %+v", item)
+		}
+
+		if item, err = storage.Info("/photos/2020"); err != nil || !item.IsDir || item.Name != "2020" {
+			t.Errorf("Info() = (%+v, `%s`), want implicit directory", item, err)
+		}
+
+		if _, err = storage.Info("/unknown"); !provider.IsNotExist(err) {
+			t.Errorf("Info() = `%s`, want not exist error", err)
+		}
+	})
+
+	t.Run("list", func(t *testing.T) {
+		items, err := storage.List("/")
+		if err != nil {
+			t.Fatalf("List() = %s", err)
+		}
+
+		if got, want := listNames(items), []string{"photos", "readme.md"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("List() = %#v, want %#v", got, want)
+		}
+
+		if _, err = storage.List("/unknown"); !provider.IsNotExist(err) {
+			t.Errorf("List() = `%s`, want not exist error", err)
+		}
+	})
+
+	t.Run("reader", func(t *testing.T) {
+		reader, err := storage.ReaderFrom("/readme.md")
+		if err != nil {
+			t.Fatalf("ReaderFrom() = %s", err)
+		}
+		defer reader.Close()
+
+		if _, err := reader.Seek(6, io.SeekStart); err != nil {
+			t.Fatalf("Seek() = %s", err)
+		}
+
+		content, err := ioutil.ReadAll(reader)
+		if err != nil || string(content) != "world" {
+			t.Errorf("ReadAll() = (`%s`, `%s`), want `world`", content, err)
+		}
+
+		if _, err := storage.ReaderFrom("/unknown"); !provider.IsNotExist(err) {
+			t.Errorf("ReaderFrom() = `%s`, want not exist error", err)
+		}
+	})
+
+	t.Run("writer", func(t *testing.T) {
+		writer, err := storage.WriterTo("/notes.txt")
+		if err != nil {
+			t.Fatalf("WriterTo() = %s", err)
+		}
+
+		if _, err := io.WriteString(writer, "some notes"); err != nil {
+			t.Fatalf("WriteString() = %s", err)
+		}
+
+		if err := writer.Close(); err != nil {
+			t.Fatalf("Close() = %s", err)
+		}
+
+		if item, err := storage.Info("/notes.txt"); err != nil || item.Size != 10 {
+			t.Errorf("Info() = (%+v, `%s`)", item, err)
+		}
+	})
+
+	t.Run("walk", func(t *testing.T) {
+		var walked []string
+		err := storage.Walk("", func(item provider.StorageItem, err error) error {
+			walked = append(walked, item.Pathname)
+			return err
+		})
+
+		if err != nil {
+			t.Fatalf("Walk() = %s", err)
+		}
+
+		want := []string{"/", "/notes.txt", "/photos", "/photos/2020", "/photos/2020/beach.jpg", "/readme.md"}
+		if !reflect.DeepEqual(walked, want) {
+			t.Errorf("Walk() = %#v, want %#v", walked, want)
+		}
+	})
+
+	t.Run("rename", func(t *testing.T) {
+		if err := storage.Rename("/photos", "/pictures"); err != nil {
+			t.Fatalf("Rename() = %s", err)
+		}
+
+		want := []string{".fibr/", ".fibr/photos/2020/beach.jpg", "notes.txt", "pictures/", "pictures/2020/beach.jpg", "readme.md"}
+		if got := server.Keys(); !reflect.DeepEqual(got, want) {
+			t.Errorf("Rename() = %#v, want %#v", got, want)
+		}
+	})
+
+	t.Run("rename large", func(t *testing.T) {
+		defaultMaxCopySize := maxCopySize
+		maxCopySize = 4
+		defer func() {
+			maxCopySize = defaultMaxCopySize
+		}()
+
+		if err := storage.Rename("/readme.md", "/large.md"); err != nil {
+			t.Fatalf("Rename() = %s", err)
+		}
+
+		reader, err := storage.ReaderFrom("/large.md")
+		if err != nil {
+			t.Fatalf("ReaderFrom() = %s", err)
+		}
+		defer reader.Close()
+
+		if content, err := ioutil.ReadAll(reader); err != nil || string(content) != "hello world" {
+			t.Errorf("Rename() = (`%s`, `%s`), want content copied in parts", content, err)
+		}
+
+		if _, err := storage.Info("/readme.md"); !provider.IsNotExist(err) {
+			t.Errorf("Info() = `%v`, want old object removed", err)
+		}
+	})
+
+	t.Run("remove", func(t *testing.T) {
+		if err := storage.Remove("/pictures"); err != nil {
+			t.Fatalf("Remove() = %s", err)
+		}
+
+		want := []string{".fibr/", ".fibr/photos/2020/beach.jpg", "large.md", "notes.txt"}
+		if got := server.Keys(); !reflect.DeepEqual(got, want) {
+			t.Errorf("Remove() = %#v, want %#v", got, want)
+		}
+	})
+}
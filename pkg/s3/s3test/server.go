@@ -0,0 +1,348 @@
+package s3test
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type object struct {
+	content []byte
+	date    time.Time
+}
+
+func (o object) etag() string {
+	hash := md5.Sum(o.content)
+	return fmt.Sprintf(`"%s"`, hex.EncodeToString(hash[:]))
+}
+
+// Server is a minimal in-memory stand-in of an S3 compatible server
+type Server struct {
+	*httptest.Server
+
+	bucket  string
+	objects map[string]object
+	uploads map[string]map[int][]byte
+	mutex   sync.Mutex
+}
+
+// New starts a stand-in server serving given bucket
+func New(bucket string) *Server {
+	server := &Server{
+		bucket:  bucket,
+		objects: make(map[string]object),
+		uploads: make(map[string]map[int][]byte),
+	}
+
+	server.Server = httptest.NewServer(http.HandlerFunc(server.handle))
+
+	return server
+}
+
+// Endpoint of server, without scheme
+func (s *Server) Endpoint() string {
+	return strings.TrimPrefix(s.URL, "http://")
+}
+
+// Keys lists keys currently stored
+func (s *Server) Keys() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	keys := make([]string, 0, len(s.objects))
+	for key := range s.objects {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)
+	if parts[0] != s.bucket {
+		writeError(w, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+
+	if len(parts) == 1 || len(parts[1]) == 0 {
+		s.handleBucket(w, r)
+		return
+	}
+
+	s.handleObject(w, r, parts[1])
+}
+
+func (s *Server) handleBucket(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodHead:
+		w.WriteHeader(http.StatusOK)
+	case http.MethodGet:
+		s.list(w, r)
+	default:
+		writeError(w, http.StatusNotImplemented, "NotImplemented")
+	}
+}
+
+func (s *Server) handleObject(w http.ResponseWriter, r *http.Request, key string) {
+	query := r.URL.Query()
+
+	switch r.Method {
+	case http.MethodHead, http.MethodGet:
+		item, ok := s.objects[key]
+		if !ok {
+			writeError(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+
+		w.Header().Set("ETag", item.etag())
+		http.ServeContent(w, r, key, item.date, bytes.NewReader(item.content))
+
+	case http.MethodPut:
+		if source := r.Header.Get("X-Amz-Copy-Source"); len(source) != 0 {
+			if uploadID := query.Get("uploadId"); len(uploadID) != 0 {
+				partNumber, _ := strconv.Atoi(query.Get("partNumber"))
+				s.copyPart(w, uploadID, partNumber, source, r.Header.Get("X-Amz-Copy-Source-Range"))
+				return
+			}
+
+			s.copy(w, key, source)
+			return
+		}
+
+		content, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "InternalError")
+			return
+		}
+
+		if uploadID := query.Get("uploadId"); len(uploadID) != 0 {
+			partNumber, _ := strconv.Atoi(query.Get("partNumber"))
+			s.uploads[uploadID][partNumber] = content
+			w.Header().Set("ETag", object{content: content}.etag())
+			return
+		}
+
+		s.objects[key] = object{content: content, date: time.Now().UTC().Truncate(time.Second)}
+		w.Header().Set("ETag", s.objects[key].etag())
+
+	case http.MethodPost:
+		if _, ok := query["uploads"]; ok {
+			uploadID := strconv.Itoa(len(s.uploads) + 1)
+			s.uploads[uploadID] = make(map[int][]byte)
+			writeXML(w, struct {
+				XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+				Bucket   string
+				Key      string
+				UploadID string `xml:"UploadId"`
+			}{Bucket: s.bucket, Key: key, UploadID: uploadID})
+			return
+		}
+
+		s.complete(w, key, query.Get("uploadId"))
+
+	case http.MethodDelete:
+		if uploadID := query.Get("uploadId"); len(uploadID) != 0 {
+			delete(s.uploads, uploadID)
+		} else {
+			delete(s.objects, key)
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		writeError(w, http.StatusNotImplemented, "NotImplemented")
+	}
+}
+
+func (s *Server) copy(w http.ResponseWriter, key, source string) {
+	item, ok := s.getSource(w, source)
+	if !ok {
+		return
+	}
+
+	item.date = time.Now().UTC().Truncate(time.Second)
+	s.objects[key] = item
+
+	writeXML(w, struct {
+		XMLName      xml.Name `xml:"CopyObjectResult"`
+		LastModified string
+		ETag         string
+	}{LastModified: item.date.Format(time.RFC3339), ETag: item.etag()})
+}
+
+func (s *Server) getSource(w http.ResponseWriter, source string) (object, bool) {
+	source, err := url.QueryUnescape(source)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "InvalidArgument")
+		return object{}, false
+	}
+
+	item, ok := s.objects[strings.TrimPrefix(strings.TrimPrefix(source, "/"), s.bucket+"/")]
+	if !ok {
+		writeError(w, http.StatusNotFound, "NoSuchKey")
+		return object{}, false
+	}
+
+	return item, true
+}
+
+// copyPart copies range of source, in the form `bytes=start-end`, as a part of a multipart upload
+func (s *Server) copyPart(w http.ResponseWriter, uploadID string, partNumber int, source, sourceRange string) {
+	parts, ok := s.uploads[uploadID]
+	if !ok {
+		writeError(w, http.StatusNotFound, "NoSuchUpload")
+		return
+	}
+
+	item, ok := s.getSource(w, source)
+	if !ok {
+		return
+	}
+
+	content := item.content
+	if len(sourceRange) != 0 {
+		var start, end int
+		if _, err := fmt.Sscanf(sourceRange, "bytes=%d-%d", &start, &end); err != nil || start > end || end >= len(content) {
+			writeError(w, http.StatusBadRequest, "InvalidArgument")
+			return
+		}
+
+		content = content[start : end+1]
+	}
+
+	parts[partNumber] = content
+
+	writeXML(w, struct {
+		XMLName      xml.Name `xml:"CopyPartResult"`
+		LastModified string
+		ETag         string
+	}{LastModified: time.Now().UTC().Format(time.RFC3339), ETag: object{content: content}.etag()})
+}
+
+func (s *Server) complete(w http.ResponseWriter, key, uploadID string) {
+	parts, ok := s.uploads[uploadID]
+	if !ok {
+		writeError(w, http.StatusNotFound, "NoSuchUpload")
+		return
+	}
+
+	numbers := make([]int, 0, len(parts))
+	for number := range parts {
+		numbers = append(numbers, number)
+	}
+	sort.Ints(numbers)
+
+	var content []byte
+	for _, number := range numbers {
+		content = append(content, parts[number]...)
+	}
+
+	delete(s.uploads, uploadID)
+	s.objects[key] = object{content: content, date: time.Now().UTC().Truncate(time.Second)}
+
+	writeXML(w, struct {
+		XMLName xml.Name `xml:"CompleteMultipartUploadResult"`
+		Bucket  string
+		Key     string
+		ETag    string
+	}{Bucket: s.bucket, Key: key, ETag: s.objects[key].etag()})
+}
+
+type listContent struct {
+	Key          string
+	LastModified string
+	ETag         string
+	Size         int
+}
+
+type listPrefix struct {
+	Prefix string
+}
+
+func (s *Server) list(w http.ResponseWriter, r *http.Request) {
+	prefix := r.URL.Query().Get("prefix")
+	delimiter := r.URL.Query().Get("delimiter")
+
+	keys := make([]string, 0)
+	for key := range s.objects {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	contents := make([]listContent, 0)
+	prefixes := make([]listPrefix, 0)
+	seenPrefixes := make(map[string]bool)
+
+	for _, key := range keys {
+		if len(delimiter) != 0 {
+			if index := strings.Index(key[len(prefix):], delimiter); index >= 0 {
+				commonPrefix := key[:len(prefix)+index+1]
+				if !seenPrefixes[commonPrefix] {
+					seenPrefixes[commonPrefix] = true
+					prefixes = append(prefixes, listPrefix{commonPrefix})
+				}
+				continue
+			}
+		}
+
+		item := s.objects[key]
+		contents = append(contents, listContent{
+			Key:          key,
+			LastModified: item.date.Format(time.RFC3339),
+			ETag:         item.etag(),
+			Size:         len(item.content),
+		})
+	}
+
+	writeXML(w, struct {
+		XMLName        xml.Name `xml:"ListBucketResult"`
+		Name           string
+		Prefix         string
+		Delimiter      string
+		KeyCount       int
+		IsTruncated    bool
+		Contents       []listContent
+		CommonPrefixes []listPrefix
+	}{
+		Name:           s.bucket,
+		Prefix:         prefix,
+		Delimiter:      delimiter,
+		KeyCount:       len(contents) + len(prefixes),
+		Contents:       contents,
+		CommonPrefixes: prefixes,
+	})
+}
+
+func writeXML(w http.ResponseWriter, content interface{}) {
+	w.Header().Set("Content-Type", "application/xml")
+
+	if err := xml.NewEncoder(w).Encode(content); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+
+	_ = xml.NewEncoder(w).Encode(struct {
+		XMLName xml.Name `xml:"Error"`
+		Code    string
+	}{Code: code})
+}
@@ -0,0 +1,62 @@
+package s3
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/ViBiOh/fibr/pkg/provider"
+	"github.com/minio/minio-go/v7"
+)
+
+func checkPathname(pathname string) error {
+	if strings.Contains(pathname, "..") {
+		return ErrRelativePath
+	}
+
+	return nil
+}
+
+func getDirKey(key string) string {
+	if strings.HasSuffix(key, "/") {
+		return key
+	}
+
+	return fmt.Sprintf("%s/", key)
+}
+
+func convertToItem(info minio.ObjectInfo) provider.StorageItem {
+	isDir := strings.HasSuffix(info.Key, "/")
+	key := strings.TrimSuffix(info.Key, "/")
+
+	return provider.StorageItem{
+		Name:     path.Base(key),
+		Pathname: fmt.Sprintf("/%s", key),
+		IsDir:    isDir,
+		Date:     info.LastModified,
+		Size:     info.Size,
+		Info:     info,
+	}
+}
+
+func isNotExist(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	response := minio.ToErrorResponse(err)
+	return response.StatusCode == http.StatusNotFound || response.Code == "NoSuchKey"
+}
+
+func convertError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if isNotExist(err) {
+		return provider.ErrNotExist(err)
+	}
+
+	return err
+}
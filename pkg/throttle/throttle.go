@@ -0,0 +1,260 @@
+package throttle
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ViBiOh/httputils/v3/pkg/flags"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// KindLogin identifies a failed login
+	KindLogin = "login"
+
+	// KindShare identifies a failed share password
+	KindShare = "share"
+
+	// KindToken identifies a failed API token
+	KindToken = "token"
+
+	purgeInterval = time.Minute
+)
+
+// LockedError occurs when too many authentications failed recently
+type LockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e LockedError) Error() string {
+	return fmt.Sprintf("too many failed authentications, retry in %s", e.RetryAfter.Round(time.Second))
+}
+
+// App of package
+type App interface {
+	Check(...string) error
+	Fail(string, ...string)
+	Success(...string)
+	GetIP(*http.Request) string
+}
+
+// Config of package
+type Config struct {
+	attempts  *uint
+	delay     *string
+	maxDelay  *string
+	forwarded *bool
+}
+
+type attempt struct {
+	failures    uint
+	last        time.Time
+	lockedUntil time.Time
+}
+
+type app struct {
+	attempts  uint
+	delay     time.Duration
+	maxDelay  time.Duration
+	forwarded bool
+
+	mutex     sync.Mutex
+	entries   map[string]*attempt
+	nextPurge time.Time
+
+	failures *prometheus.CounterVec
+	lockouts *prometheus.CounterVec
+}
+
+// Flags adds flags for configuring package
+func Flags(fs *flag.FlagSet, prefix string) Config {
+	return Config{
+		attempts:  flags.New(prefix, "throttle").Name("Attempts").Default(uint(5)).Label("Failed authentications allowed per IP before lockout, disabled if 0").ToUint(fs),
+		delay:     flags.New(prefix, "throttle").Name("Delay").Default("30s").Label("Lockout duration after too many failed authentications, doubled on each new failure").ToString(fs),
+		maxDelay:  flags.New(prefix, "throttle").Name("MaxDelay").Default("1h").Label("Maximum lockout duration, failures being forgotten after this idle duration").ToString(fs),
+		forwarded: flags.New(prefix, "throttle").Name("Forwarded").Default(false).Label("Use X-Forwarded-For header for client IP, only behind a trusted reverse proxy").ToBool(fs),
+	}
+}
+
+// New creates new App from Config, registering metrics on given registerer if not nil
+func New(config Config, registerer prometheus.Registerer) (App, error) {
+	delay, err := time.ParseDuration(strings.TrimSpace(*config.delay))
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse throttle delay: %w", err)
+	}
+
+	maxDelay, err := time.ParseDuration(strings.TrimSpace(*config.maxDelay))
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse throttle max delay: %w", err)
+	}
+
+	if maxDelay < delay {
+		return nil, fmt.Errorf("throttle max delay %s is lower than delay %s", maxDelay, delay)
+	}
+
+	instance := &app{
+		attempts:  *config.attempts,
+		delay:     delay,
+		maxDelay:  maxDelay,
+		forwarded: *config.forwarded,
+		entries:   make(map[string]*attempt),
+
+		failures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "fibr_auth_failures_total",
+			Help: "A counter of failed authentications.",
+		}, []string{"kind"}),
+		lockouts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "fibr_auth_lockouts_total",
+			Help: "A counter of lockouts after too many failed authentications.",
+		}, []string{"kind"}),
+	}
+
+	if registerer != nil {
+		if err := registerer.Register(instance.failures); err != nil {
+			return nil, fmt.Errorf("unable to register failures metric: %w", err)
+		}
+
+		if err := registerer.Register(instance.lockouts); err != nil {
+			return nil, fmt.Errorf("unable to register lockouts metric: %w", err)
+		}
+	}
+
+	return instance, nil
+}
+
+// IPKey gives key for throttling a client IP
+func IPKey(ip string) string {
+	return fmt.Sprintf("ip:%s", ip)
+}
+
+// IPLoginKey gives key for throttling a login from a client IP
+func IPLoginKey(ip, login string) string {
+	return fmt.Sprintf("ip-login:%s:%s", ip, login)
+}
+
+// IPShareKey gives key for throttling a share password from a client IP
+func IPShareKey(ip, id string) string {
+	return fmt.Sprintf("ip-share:%s:%s", ip, id)
+}
+
+// GetIP gives client IP of request
+func (a *app) GetIP(r *http.Request) string {
+	if a.forwarded {
+		if forwarded := r.Header.Get("X-Forwarded-For"); len(forwarded) != 0 {
+			ips := strings.Split(forwarded, ",")
+			return strings.TrimSpace(ips[len(ips)-1])
+		}
+	}
+
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return ip
+}
+
+// Check returns a LockedError if one of given keys is locked
+func (a *app) Check(keys ...string) error {
+	if a.attempts == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	var retryAfter time.Duration
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	for _, key := range keys {
+		if entry, ok := a.entries[key]; ok && entry.lockedUntil.After(now) {
+			if remaining := entry.lockedUntil.Sub(now); remaining > retryAfter {
+				retryAfter = remaining
+			}
+		}
+	}
+
+	if retryAfter == 0 {
+		return nil
+	}
+
+	return LockedError{RetryAfter: retryAfter}
+}
+
+// Fail records a failed authentication of given kind on given keys, locking them if needed
+func (a *app) Fail(kind string, keys ...string) {
+	a.failures.WithLabelValues(kind).Inc()
+
+	if a.attempts == 0 {
+		return
+	}
+
+	now := time.Now()
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	a.purge(now)
+
+	for _, key := range keys {
+		entry, ok := a.entries[key]
+		if !ok || now.Sub(entry.last) > a.maxDelay {
+			entry = &attempt{}
+			a.entries[key] = entry
+		}
+
+		entry.failures++
+		entry.last = now
+
+		if entry.failures >= a.attempts {
+			entry.lockedUntil = now.Add(a.getLockout(entry.failures))
+			a.lockouts.WithLabelValues(kind).Inc()
+		}
+	}
+}
+
+// Success forgets failures of given keys
+func (a *app) Success(keys ...string) {
+	if a.attempts == 0 {
+		return
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	for _, key := range keys {
+		delete(a.entries, key)
+	}
+}
+
+// getLockout gives lockout duration for given failures count, doubling on each failure over allowed attempts
+func (a *app) getLockout(failures uint) time.Duration {
+	exponent := float64(failures - a.attempts)
+	lockout := float64(a.delay) * math.Pow(2, exponent)
+
+	if lockout > float64(a.maxDelay) {
+		return a.maxDelay
+	}
+
+	return time.Duration(lockout)
+}
+
+func (a *app) purge(now time.Time) {
+	if now.Before(a.nextPurge) {
+		return
+	}
+
+	for key, entry := range a.entries {
+		if now.Sub(entry.last) > a.maxDelay && now.After(entry.lockedUntil) {
+			delete(a.entries, key)
+		}
+	}
+
+	a.nextPurge = now.Add(purgeInterval)
+}
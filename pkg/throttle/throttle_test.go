@@ -0,0 +1,148 @@
+package throttle
+
+import (
+	"errors"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newTestApp(t *testing.T, args ...string) *app {
+	fs := flag.NewFlagSet("throttle-test", flag.ContinueOnError)
+	config := Flags(fs, "throttle")
+
+	if err := fs.Parse(args); err != nil {
+		t.Fatalf("unable to parse flags: %s", err)
+	}
+
+	instance, err := New(config, nil)
+	if err != nil {
+		t.Fatalf("New() = %s", err)
+	}
+
+	return instance.(*app)
+}
+
+func TestFail(t *testing.T) {
+	instance := newTestApp(t, "-throttleAttempts", "3")
+
+	for i := 0; i < 2; i++ {
+		instance.Fail(KindLogin, IPKey("10.0.0.1"))
+	}
+
+	if err := instance.Check(IPKey("10.0.0.1")); err != nil {
+		t.Errorf("Check() = `%s`, want no lockout before attempts", err)
+	}
+
+	instance.Fail(KindLogin, IPKey("10.0.0.1"))
+
+	var locked LockedError
+	if err := instance.Check(IPKey("10.0.0.2"), IPKey("10.0.0.1")); !errors.As(err, &locked) || locked.RetryAfter > 30*time.Second {
+		t.Errorf("Check() = `%v`, want lockout of 30s", err)
+	}
+
+	if err := instance.Check(IPKey("10.0.0.2")); err != nil {
+		t.Errorf("Check() = `%s`, want other IP not locked", err)
+	}
+
+	instance.Success(IPKey("10.0.0.1"))
+
+	if err := instance.Check(IPKey("10.0.0.1")); err != nil {
+		t.Errorf("Check() = `%s`, want lockout forgotten after success", err)
+	}
+}
+
+func TestFailDisabled(t *testing.T) {
+	instance := newTestApp(t, "-throttleAttempts", "0")
+
+	for i := 0; i < 10; i++ {
+		instance.Fail(KindShare, IPShareKey("10.0.0.1", "a1b2c3d4f5"))
+	}
+
+	if err := instance.Check(IPShareKey("10.0.0.1", "a1b2c3d4f5")); err != nil {
+		t.Errorf("Check() = `%s`, want no lockout when disabled", err)
+	}
+}
+
+func TestGetLockout(t *testing.T) {
+	instance := newTestApp(t, "-throttleAttempts", "3", "-throttleDelay", "10s", "-throttleMaxDelay", "1m")
+
+	var cases = []struct {
+		intention string
+		input     uint
+		want      time.Duration
+	}{
+		{
+			"first lockout",
+			3,
+			10 * time.Second,
+		},
+		{
+			"doubled",
+			5,
+			40 * time.Second,
+		},
+		{
+			"capped",
+			8,
+			time.Minute,
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.intention, func(t *testing.T) {
+			if result := instance.getLockout(testCase.input); result != testCase.want {
+				t.Errorf("getLockout() = %s, want %s", result, testCase.want)
+			}
+		})
+	}
+}
+
+func TestGetIP(t *testing.T) {
+	var cases = []struct {
+		intention string
+		args      []string
+		forwarded string
+		want      string
+	}{
+		{
+			"remote address",
+			nil,
+			"",
+			"192.0.2.1",
+		},
+		{
+			"untrusted forwarded header",
+			nil,
+			"10.0.0.1",
+			"192.0.2.1",
+		},
+		{
+			"trusted forwarded header",
+			[]string{"-throttleForwarded"},
+			"10.0.0.1, 10.0.0.2",
+			"10.0.0.2",
+		},
+		{
+			"trusted without header",
+			[]string{"-throttleForwarded"},
+			"",
+			"192.0.2.1",
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.intention, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if len(testCase.forwarded) != 0 {
+				r.Header.Set("X-Forwarded-For", testCase.forwarded)
+			}
+
+			if result := newTestApp(t, testCase.args...).GetIP(r); result != testCase.want {
+				t.Errorf("GetIP() = `%s`, want `%s`", result, testCase.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,252 @@
+package thumbnail
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io"
+	"io/ioutil"
+
+	// Register decoders of handled formats
+	_ "image/gif"
+	_ "image/png"
+
+	"github.com/ViBiOh/fibr/pkg/provider"
+	"github.com/ViBiOh/httputils/v3/pkg/logger"
+)
+
+const (
+	jpegQuality = 80
+
+	maxImageBytes  = 100 << 20
+	maxImagePixels = 100 * 1000 * 1000
+)
+
+var (
+	// ErrImageTooLarge occurs when image exceeds size or pixels handled in process
+	ErrImageTooLarge = errors.New("image is too large")
+)
+
+var builtinExtensions = map[string]bool{".jpg": true, ".jpeg": true, ".png": true, ".gif": true}
+
+// builtinGenerator crops JPEG, PNG and GIF in process, without any sidecar
+type builtinGenerator struct{}
+
+func (g builtinGenerator) Handles(item provider.StorageItem) bool {
+	return builtinExtensions[item.Extension()]
+}
+
+// Supports only JPEG, standard library having no WebP encoder
+func (g builtinGenerator) Supports(format Format) bool {
+	return format == JPEG
+}
+
+func (g builtinGenerator) Generate(_ context.Context, open Source, variants []Variant) ([][]byte, error) {
+	content, err := open()
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := ioutil.ReadAll(io.LimitReader(content, maxImageBytes+1))
+	if closeErr := content.Close(); closeErr != nil {
+		logger.Error("unable to close image: %s", closeErr)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(raw) > maxImageBytes {
+		return nil, ErrImageTooLarge
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(config.Width)*int64(config.Height) > maxImagePixels {
+		return nil, ErrImageTooLarge
+	}
+
+	source, _, err := image.Decode(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+
+	if source.Bounds().Empty() {
+		return nil, errors.New("empty image")
+	}
+
+	orientation := getOrientation(raw)
+	thumbnails := make([][]byte, len(variants))
+
+	for index, variant := range variants {
+		if !g.Supports(variant.Format) {
+			return nil, fmt.Errorf("unsupported format %s", variant.Format)
+		}
+
+		output := bytes.Buffer{}
+		if err := jpeg.Encode(&output, crop(source, orientation, variant.Width, variant.Height), &jpeg.Options{Quality: jpegQuality}); err != nil {
+			return nil, err
+		}
+
+		thumbnails[index] = output.Bytes()
+	}
+
+	return thumbnails, nil
+}
+
+// crop resizes source to fill width x height once oriented, cropping its center
+func crop(source image.Image, orientation, width, height int) image.Image {
+	swapped := orientation >= 5
+
+	targetWidth, targetHeight := width, height
+	if swapped {
+		targetWidth, targetHeight = height, width
+	}
+
+	resized := resize(source, getCropArea(source.Bounds(), targetWidth, targetHeight), targetWidth, targetHeight)
+	if orientation <= 1 {
+		return resized
+	}
+
+	return orient(resized, orientation, width, height)
+}
+
+// getCropArea gives the centered area of bounds having the aspect ratio of width x height
+func getCropArea(bounds image.Rectangle, width, height int) image.Rectangle {
+	sourceWidth, sourceHeight := bounds.Dx(), bounds.Dy()
+
+	if sourceWidth*height > sourceHeight*width {
+		cropWidth := sourceHeight * width / height
+		offset := (sourceWidth - cropWidth) / 2
+
+		return image.Rect(bounds.Min.X+offset, bounds.Min.Y, bounds.Min.X+offset+cropWidth, bounds.Max.Y)
+	}
+
+	cropHeight := sourceWidth * height / width
+	offset := (sourceHeight - cropHeight) / 2
+
+	return image.Rect(bounds.Min.X, bounds.Min.Y+offset, bounds.Max.X, bounds.Min.Y+offset+cropHeight)
+}
+
+// resize scales area of source to width x height, averaging source pixels covered by each output pixel
+func resize(source image.Image, area image.Rectangle, width, height int) *image.RGBA {
+	output := image.NewRGBA(image.Rect(0, 0, width, height))
+	ycbcr, isYCbCr := source.(*image.YCbCr)
+
+	for y := 0; y < height; y++ {
+		minY, maxY := getSourceRange(area.Min.Y, area.Dy(), y, height)
+
+		for x := 0; x < width; x++ {
+			minX, maxX := getSourceRange(area.Min.X, area.Dx(), x, width)
+
+			var pixel color.RGBA
+			if isYCbCr {
+				pixel = averageYCbCr(ycbcr, minX, maxX, minY, maxY)
+			} else {
+				pixel = average(source, minX, maxX, minY, maxY)
+			}
+
+			output.SetRGBA(x, y, pixel)
+		}
+	}
+
+	return output
+}
+
+func getSourceRange(start, size, index, count int) (int, int) {
+	min := start + index*size/count
+	max := start + (index+1)*size/count
+
+	if max <= min {
+		max = min + 1
+	}
+
+	return min, max
+}
+
+func averageYCbCr(source *image.YCbCr, minX, maxX, minY, maxY int) color.RGBA {
+	var sumY, sumCb, sumCr, count uint64
+
+	for y := minY; y < maxY; y++ {
+		for x := minX; x < maxX; x++ {
+			sumY += uint64(source.Y[source.YOffset(x, y)])
+
+			cOffset := source.COffset(x, y)
+			sumCb += uint64(source.Cb[cOffset])
+			sumCr += uint64(source.Cr[cOffset])
+
+			count++
+		}
+	}
+
+	r, g, b := color.YCbCrToRGB(uint8(sumY/count), uint8(sumCb/count), uint8(sumCr/count))
+	return color.RGBA{R: r, G: g, B: b, A: 0xff}
+}
+
+func average(source image.Image, minX, maxX, minY, maxY int) color.RGBA {
+	var sumR, sumG, sumB, sumA, count uint64
+
+	for y := minY; y < maxY; y++ {
+		for x := minX; x < maxX; x++ {
+			r, g, b, a := source.At(x, y).RGBA()
+
+			sumR += uint64(r)
+			sumG += uint64(g)
+			sumB += uint64(b)
+			sumA += uint64(a)
+
+			count++
+		}
+	}
+
+	// JPEG has no transparency: blend premultiplied colors over white
+	white := 0xffff - sumA/count
+
+	return color.RGBA{
+		R: uint8((sumR/count + white) >> 8),
+		G: uint8((sumG/count + white) >> 8),
+		B: uint8((sumB/count + white) >> 8),
+		A: 0xff,
+	}
+}
+
+// orient applies EXIF orientation to source, giving an image of width x height
+func orient(source *image.RGBA, orientation, width, height int) *image.RGBA {
+	output := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			var sourceX, sourceY int
+
+			switch orientation {
+			case 2:
+				sourceX, sourceY = width-1-x, y
+			case 3:
+				sourceX, sourceY = width-1-x, height-1-y
+			case 4:
+				sourceX, sourceY = x, height-1-y
+			case 5:
+				sourceX, sourceY = y, x
+			case 6:
+				sourceX, sourceY = y, width-1-x
+			case 7:
+				sourceX, sourceY = height-1-y, width-1-x
+			case 8:
+				sourceX, sourceY = height-1-y, x
+			default:
+				sourceX, sourceY = x, y
+			}
+
+			output.SetRGBA(x, y, source.RGBAAt(sourceX, sourceY))
+		}
+	}
+
+	return output
+}
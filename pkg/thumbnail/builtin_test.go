@@ -0,0 +1,310 @@
+package thumbnail
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/ViBiOh/fibr/pkg/memory"
+	"github.com/ViBiOh/fibr/pkg/provider"
+)
+
+func newEXIF(order binary.ByteOrder, orientation uint16) []byte {
+	tiff := bytes.Buffer{}
+	if order == binary.LittleEndian {
+		tiff.WriteString("II")
+	} else {
+		tiff.WriteString("MM")
+	}
+
+	_ = binary.Write(&tiff, order, uint16(42))
+	_ = binary.Write(&tiff, order, uint32(8))
+	_ = binary.Write(&tiff, order, uint16(1))
+	_ = binary.Write(&tiff, order, uint16(exifOrientationTag))
+	_ = binary.Write(&tiff, order, uint16(3))
+	_ = binary.Write(&tiff, order, uint32(1))
+	_ = binary.Write(&tiff, order, orientation)
+	_ = binary.Write(&tiff, order, uint16(0))
+	_ = binary.Write(&tiff, order, uint32(0))
+
+	segment := append(append([]byte{}, exifHeader...), tiff.Bytes()...)
+
+	content := []byte{0xff, jpegSOI, 0xff, jpegAPP1}
+	content = append(content, byte((len(segment)+2)>>8), byte(len(segment)+2))
+	content = append(content, segment...)
+
+	return append(content, 0xff, jpegSOS, 0x00, 0x02)
+}
+
+func TestGetOrientation(t *testing.T) {
+	var cases = []struct {
+		intention string
+		input     []byte
+		want      int
+	}{
+		{
+			"empty",
+			nil,
+			1,
+		},
+		{
+			"not a jpeg",
+			[]byte("\x89PNG\r\n\x1a\n"),
+			1,
+		},
+		{
+			"no exif",
+			[]byte{0xff, jpegSOI, 0xff, jpegSOS, 0x00, 0x02},
+			1,
+		},
+		{
+			"little endian",
+			newEXIF(binary.LittleEndian, 6),
+			6,
+		},
+		{
+			"big endian",
+			newEXIF(binary.BigEndian, 3),
+			3,
+		},
+		{
+			"invalid value",
+			newEXIF(binary.LittleEndian, 9),
+			1,
+		},
+		{
+			"truncated",
+			newEXIF(binary.LittleEndian, 6)[:20],
+			1,
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.intention, func(t *testing.T) {
+			if result := getOrientation(testCase.input); result != testCase.want {
+				t.Errorf("getOrientation() = %d, want %d", result, testCase.want)
+			}
+		})
+	}
+}
+
+func TestGetCropArea(t *testing.T) {
+	var cases = []struct {
+		intention string
+		input     image.Rectangle
+		width     int
+		height    int
+		want      image.Rectangle
+	}{
+		{
+			"landscape",
+			image.Rect(0, 0, 400, 200),
+			150,
+			150,
+			image.Rect(100, 0, 300, 200),
+		},
+		{
+			"portrait",
+			image.Rect(0, 0, 200, 400),
+			150,
+			150,
+			image.Rect(0, 100, 200, 300),
+		},
+		{
+			"same ratio",
+			image.Rect(0, 0, 300, 150),
+			200,
+			100,
+			image.Rect(0, 0, 300, 150),
+		},
+		{
+			"offset bounds",
+			image.Rect(10, 10, 410, 210),
+			150,
+			150,
+			image.Rect(110, 10, 310, 210),
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.intention, func(t *testing.T) {
+			if result := getCropArea(testCase.input, testCase.width, testCase.height); result != testCase.want {
+				t.Errorf("getCropArea() = %s, want %s", result, testCase.want)
+			}
+		})
+	}
+}
+
+// newHalvesImage creates an image with left half red and right half blue
+func newHalvesImage(width, height int) *image.NRGBA {
+	source := image.NewNRGBA(image.Rect(0, 0, width, height))
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			if x < width/2 {
+				source.SetNRGBA(x, y, color.NRGBA{R: 0xff, A: 0xff})
+			} else {
+				source.SetNRGBA(x, y, color.NRGBA{B: 0xff, A: 0xff})
+			}
+		}
+	}
+
+	return source
+}
+
+func isRed(pixel color.Color) bool {
+	r, g, b, _ := pixel.RGBA()
+	return r > 0xe000 && g < 0x2000 && b < 0x2000
+}
+
+func isBlue(pixel color.Color) bool {
+	r, g, b, _ := pixel.RGBA()
+	return r < 0x2000 && g < 0x2000 && b > 0xe000
+}
+
+func TestCrop(t *testing.T) {
+	encoded := bytes.Buffer{}
+	if err := jpeg.Encode(&encoded, newHalvesImage(300, 200), nil); err != nil {
+		t.Fatalf("unable to encode jpeg: %s", err)
+	}
+
+	ycbcr, err := jpeg.Decode(&encoded)
+	if err != nil {
+		t.Fatalf("unable to decode jpeg: %s", err)
+	}
+
+	sources := map[string]image.Image{
+		"rgba":  newHalvesImage(300, 200),
+		"ycbcr": ycbcr,
+	}
+
+	var cases = []struct {
+		intention   string
+		orientation int
+		red         image.Point
+		blue        image.Point
+	}{
+		{
+			"as is",
+			1,
+			image.Pt(10, 75),
+			image.Pt(140, 75),
+		},
+		{
+			"mirrored",
+			2,
+			image.Pt(140, 75),
+			image.Pt(10, 75),
+		},
+		{
+			"rotated clockwise",
+			6,
+			image.Pt(75, 10),
+			image.Pt(75, 140),
+		},
+		{
+			"rotated counter clockwise",
+			8,
+			image.Pt(75, 140),
+			image.Pt(75, 10),
+		},
+	}
+
+	for name, source := range sources {
+		for _, testCase := range cases {
+			t.Run(name+" "+testCase.intention, func(t *testing.T) {
+				result := crop(source, testCase.orientation, 150, 150)
+
+				if result.Bounds() != image.Rect(0, 0, 150, 150) {
+					t.Fatalf("crop() = %s, want 150x150", result.Bounds())
+				}
+
+				if !isRed(result.At(testCase.red.X, testCase.red.Y)) || !isBlue(result.At(testCase.blue.X, testCase.blue.Y)) {
+					t.Errorf("crop() = %v at %s and %v at %s, want red and blue", result.At(testCase.red.X, testCase.red.Y), testCase.red, result.At(testCase.blue.X, testCase.blue.Y), testCase.blue)
+				}
+			})
+		}
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	content := bytes.Buffer{}
+	if err := png.Encode(&content, newHalvesImage(640, 480)); err != nil {
+		t.Fatalf("unable to encode png: %s", err)
+	}
+
+	storage := memory.New()
+	if err := storage.CreateDir(provider.MetadataDirectoryName); err != nil {
+		t.Fatalf("CreateDir() = %s", err)
+	}
+
+	if err := storage.Store("/photo.png", ioutil.NopCloser(&content)); err != nil {
+		t.Fatalf("Store() = %s", err)
+	}
+
+	sizes := []Size{{Name: "small", Width: 150, Height: 150}, {Name: "large", Width: 320, Height: 180}}
+
+	instance := app{
+		storage:  storage,
+		backends: []backend{newBackend(builtinGenerator{}, 1)},
+		sizes:    sizes,
+	}
+
+	item := provider.StorageItem{Pathname: "/photo.png", Name: "photo.png"}
+	if err := instance.generate(item); err != nil {
+		t.Fatalf("generate() = %s", err)
+	}
+
+	for _, size := range sizes {
+		file, err := storage.ReaderFrom(instance.getThumbnailPath(item, size, JPEG))
+		if err != nil {
+			t.Fatalf("ReaderFrom() = %s", err)
+		}
+
+		thumbnail, err := jpeg.Decode(file)
+		if err != nil {
+			t.Fatalf("jpeg.Decode() = %s", err)
+		}
+
+		if thumbnail.Bounds() != image.Rect(0, 0, size.Width, size.Height) {
+			t.Errorf("generate() = %s, want %dx%d", thumbnail.Bounds(), size.Width, size.Height)
+		}
+	}
+
+	if !instance.HasAllSizes(item) {
+		t.Errorf("HasAllSizes() = false, want true")
+	}
+
+	if err := instance.generate(provider.StorageItem{Pathname: "/report.pdf", Name: "report.pdf"}); err == nil {
+		t.Errorf("generate() = nil, want error for unhandled pdf")
+	}
+
+	if _, err := (builtinGenerator{}).Generate(context.Background(), bytesSource([]byte("not an image")), []Variant{{Size: sizes[0], Format: JPEG}}); err == nil {
+		t.Errorf("Generate() = nil, want error for invalid content")
+	}
+
+	bomb := []byte{'G', 'I', 'F', '8', '9', 'a', 0xff, 0xff, 0xff, 0xff, 0x00, 0x00, 0x00}
+	if _, err := (builtinGenerator{}).Generate(context.Background(), bytesSource(bomb), []Variant{{Size: sizes[0], Format: JPEG}}); !errors.Is(err, ErrImageTooLarge) {
+		t.Errorf("Generate() = `%v`, want error for too many pixels", err)
+	}
+
+	if _, err := (builtinGenerator{}).Generate(context.Background(), func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(io.LimitReader(zeroReader{}, maxImageBytes+10)), nil
+	}, []Variant{{Size: sizes[0], Format: JPEG}}); !errors.Is(err, ErrImageTooLarge) {
+		t.Errorf("Generate() = `%v`, want error for too many bytes", err)
+	}
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	return len(p), nil
+}
@@ -1,18 +1,26 @@
 package thumbnail
 
 import (
+	"path"
+
 	"github.com/ViBiOh/fibr/pkg/provider"
 	"github.com/ViBiOh/httputils/v3/pkg/logger"
 )
 
-// Remove thumbnail of given item
+// Remove thumbnails of given item
 func (a app) Remove(item provider.StorageItem) {
 	if !a.Enabled() {
 		return
 	}
 
-	if err := a.storage.Remove(getThumbnailPath(item)); err != nil {
-		logger.Error("%s", err)
+	if backend := a.getBackend(item); backend != nil {
+		backend.queue.remove(item.Pathname)
+	}
+
+	for _, thumbnailPath := range a.getThumbnailPaths(item) {
+		if err := a.storage.Remove(thumbnailPath); err != nil && !provider.IsNotExist(err) {
+			logger.Error("%s", err)
+		}
 	}
 }
 
@@ -22,7 +30,44 @@ func (a app) Rename(old, new provider.StorageItem) {
 		return
 	}
 
-	if err := a.storage.Rename(getThumbnailPath(old), getThumbnailPath(new)); err != nil {
-		logger.Error("%s", err)
+	if backend := a.getBackend(old); backend != nil && backend.queue.remove(old.Pathname) {
+		a.GenerateThumbnail(new)
+	}
+
+	newPaths := a.getThumbnailPaths(new)
+	for index, thumbnailPath := range a.getThumbnailPaths(old) {
+		if _, err := a.storage.Info(thumbnailPath); err != nil {
+			continue
+		}
+
+		if err := a.storage.CreateDir(path.Dir(newPaths[index])); err != nil {
+			logger.Error("%s", err)
+			continue
+		}
+
+		if err := a.storage.Rename(thumbnailPath, newPaths[index]); err != nil {
+			logger.Error("%s", err)
+		}
+	}
+}
+
+// getThumbnailPaths gives paths of thumbnails in every size and format, even disabled ones, directory having one per size
+func (a app) getThumbnailPaths(item provider.StorageItem) []string {
+	if item.IsDir {
+		paths := make([]string, len(a.sizes))
+		for index, size := range a.sizes {
+			paths[index] = a.getThumbnailPath(item, size, JPEG)
+		}
+
+		return paths
 	}
+
+	paths := make([]string, 0, len(a.sizes)*len(formats))
+	for _, format := range formats {
+		for _, size := range a.sizes {
+			paths = append(paths, a.getThumbnailPath(item, size, format))
+		}
+	}
+
+	return paths
 }
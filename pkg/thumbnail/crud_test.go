@@ -0,0 +1,49 @@
+package thumbnail
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/ViBiOh/fibr/pkg/memory"
+	"github.com/ViBiOh/fibr/pkg/provider"
+)
+
+func TestRenameAndRemove(t *testing.T) {
+	storage := memory.New()
+	instance := app{
+		storage:  storage,
+		backends: []backend{newBackend(builtinGenerator{}, 1)},
+		sizes:    []Size{{Name: "small", Width: 150, Height: 150}, {Name: "small@2x", Width: 300, Height: 300}},
+	}
+
+	for _, size := range []string{"small", "small@2x"} {
+		if err := storage.CreateDir(fmt.Sprintf("/.fibr/thumbnails/%s/photos", size)); err != nil {
+			t.Fatalf("CreateDir() = %s", err)
+		}
+
+		if err := storage.Store(fmt.Sprintf("/.fibr/thumbnails/%s/photos/beach.png.jpg", size), ioutil.NopCloser(strings.NewReader("thumbnail"))); err != nil {
+			t.Fatalf("Store() = %s", err)
+		}
+	}
+
+	oldItem := provider.StorageItem{Pathname: "/photos/beach.png", Name: "beach.png"}
+	newItem := provider.StorageItem{Pathname: "/holidays/sea.png", Name: "sea.png"}
+
+	if !instance.HasThumbnail(oldItem) {
+		t.Fatalf("HasThumbnail() = false, want true")
+	}
+
+	instance.Rename(oldItem, newItem)
+
+	if instance.HasThumbnail(oldItem) || !instance.HasAllSizes(newItem) {
+		t.Errorf("Rename() did not move thumbnails")
+	}
+
+	instance.Remove(newItem)
+
+	if _, err := storage.Info("/.fibr/thumbnails/small@2x/holidays/sea.png.jpg"); instance.HasThumbnail(newItem) || err == nil {
+		t.Errorf("Remove() did not delete thumbnails")
+	}
+}
@@ -0,0 +1,93 @@
+package thumbnail
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+const (
+	exifOrientationTag = 0x0112
+
+	jpegSOI  = 0xd8
+	jpegAPP1 = 0xe1
+	jpegSOS  = 0xda
+)
+
+var exifHeader = []byte("Exif\x00\x00")
+
+// getOrientation reads EXIF orientation of a JPEG, from 1 (as is) to 8, defaulting to 1 if absent or invalid
+func getOrientation(content []byte) int {
+	if len(content) < 4 || content[0] != 0xff || content[1] != jpegSOI {
+		return 1
+	}
+
+	for offset := 2; offset+4 <= len(content); {
+		if content[offset] != 0xff {
+			return 1
+		}
+
+		marker := content[offset+1]
+		if marker == jpegSOS {
+			return 1
+		}
+
+		length := int(binary.BigEndian.Uint16(content[offset+2:]))
+		end := offset + 2 + length
+		if length < 2 || end > len(content) {
+			return 1
+		}
+
+		if segment := content[offset+4 : end]; marker == jpegAPP1 && bytes.HasPrefix(segment, exifHeader) {
+			return parseTIFFOrientation(segment[len(exifHeader):])
+		}
+
+		offset = end
+	}
+
+	return 1
+}
+
+func parseTIFFOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	if order.Uint16(tiff[2:]) != 42 {
+		return 1
+	}
+
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return 1
+	}
+
+	count := int(order.Uint16(tiff[ifd:]))
+	for index := 0; index < count; index++ {
+		entry := ifd + 2 + index*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+
+		if order.Uint16(tiff[entry:]) != exifOrientationTag {
+			continue
+		}
+
+		if orientation := int(order.Uint16(tiff[entry+8:])); orientation >= 1 && orientation <= 8 {
+			return orientation
+		}
+
+		return 1
+	}
+
+	return 1
+}
@@ -0,0 +1,65 @@
+package thumbnail
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Format of a thumbnail file
+type Format string
+
+const (
+	// JPEG format, produced by every generator
+	JPEG Format = "jpeg"
+
+	// WebP format, produced only by generators supporting it
+	WebP Format = "webp"
+)
+
+var formats = []Format{JPEG, WebP}
+
+// Extension gives file extension of format
+func (f Format) Extension() string {
+	if f == WebP {
+		return ".webp"
+	}
+
+	return ".jpg"
+}
+
+// ContentType gives mime type of format
+func (f Format) ContentType() string {
+	return "image/" + string(f)
+}
+
+// Variant of a thumbnail to generate, a size in a format
+type Variant struct {
+	Size
+	Format Format
+}
+
+// getVariants gives variants that given backend generates, JPEG ones first
+func (a app) getVariants(backend *backend) []Variant {
+	variants := make([]Variant, 0, len(a.sizes)*len(formats))
+
+	for _, format := range formats {
+		if format != JPEG && (!a.webp || !backend.generator.Supports(format)) {
+			continue
+		}
+
+		for _, size := range a.sizes {
+			variants = append(variants, Variant{Size: size, Format: format})
+		}
+	}
+
+	return variants
+}
+
+// getAcceptedFormat gives format to serve for request, WebP if enabled and accepted by client, JPEG otherwise
+func (a app) getAcceptedFormat(r *http.Request) Format {
+	if a.webp && strings.Contains(r.Header.Get("Accept"), WebP.ContentType()) {
+		return WebP
+	}
+
+	return JPEG
+}
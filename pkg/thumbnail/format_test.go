@@ -0,0 +1,153 @@
+package thumbnail
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/ViBiOh/fibr/pkg/memory"
+	"github.com/ViBiOh/fibr/pkg/provider"
+)
+
+// formatGenerator writes format as thumbnail content, for every format
+type formatGenerator struct{}
+
+func (g formatGenerator) Handles(provider.StorageItem) bool {
+	return true
+}
+
+func (g formatGenerator) Supports(Format) bool {
+	return true
+}
+
+func (g formatGenerator) Generate(_ context.Context, _ Source, variants []Variant) ([][]byte, error) {
+	thumbnails := make([][]byte, len(variants))
+	for index, variant := range variants {
+		thumbnails[index] = []byte(variant.Format)
+	}
+
+	return thumbnails, nil
+}
+
+func TestGetVariants(t *testing.T) {
+	sizes := []Size{{Name: "small"}, {Name: "large"}}
+
+	var cases = []struct {
+		intention string
+		instance  app
+		generator Generator
+		want      int
+	}{
+		{
+			"jpeg only",
+			app{sizes: sizes},
+			formatGenerator{},
+			2,
+		},
+		{
+			"webp",
+			app{sizes: sizes, webp: true},
+			formatGenerator{},
+			4,
+		},
+		{
+			"webp unsupported",
+			app{sizes: sizes, webp: true},
+			builtinGenerator{},
+			2,
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.intention, func(t *testing.T) {
+			backend := newBackend(testCase.generator, 1)
+
+			if result := testCase.instance.getVariants(&backend); len(result) != testCase.want || result[0].Format != JPEG {
+				t.Errorf("getVariants() = %+v, want %d variants, JPEG first", result, testCase.want)
+			}
+		})
+	}
+}
+
+func TestServeFormat(t *testing.T) {
+	storage := memory.New()
+	if err := storage.CreateDir(provider.MetadataDirectoryName); err != nil {
+		t.Fatalf("CreateDir() = %s", err)
+	}
+
+	if err := storage.Store("/photo.png", ioutil.NopCloser(strings.NewReader("photo"))); err != nil {
+		t.Fatalf("Store() = %s", err)
+	}
+
+	item := provider.StorageItem{Pathname: "/photo.png", Name: "photo.png"}
+
+	instance := app{
+		storage:  storage,
+		backends: []backend{newBackend(formatGenerator{}, 1)},
+		sizes:    []Size{{Name: "small", Width: 150, Height: 150}, {Name: "large", Width: 1200, Height: 630}},
+		webp:     true,
+	}
+
+	if err := instance.generate(item); err != nil {
+		t.Fatalf("generate() = %s", err)
+	}
+
+	if err := storage.Remove("/.fibr/thumbnails/large/photo.png.webp"); err != nil {
+		t.Fatalf("Remove() = %s", err)
+	}
+
+	var cases = []struct {
+		intention       string
+		target          string
+		accept          string
+		want            string
+		wantContentType string
+	}{
+		{
+			"jpeg",
+			"/photo.png?thumbnail",
+			"image/png,image/*",
+			"jpeg",
+			"image/jpeg",
+		},
+		{
+			"webp",
+			"/photo.png?thumbnail",
+			"image/avif,image/webp,image/*",
+			"webp",
+			"image/webp",
+		},
+		{
+			"missing webp",
+			"/photo.png?thumbnail=large",
+			"image/webp,image/*",
+			"jpeg",
+			"image/jpeg",
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.intention, func(t *testing.T) {
+			r := httptest.NewRequest("GET", testCase.target, nil)
+			r.Header.Set("Accept", testCase.accept)
+			writer := httptest.NewRecorder()
+
+			instance.Serve(writer, r, item)
+
+			if result := writer.Body.String(); result != testCase.want || writer.Header().Get("Content-Type") != testCase.wantContentType || writer.Header().Get("Vary") != "Accept" {
+				t.Errorf("Serve() = (`%s`, `%s`, `%s`), want (`%s`, `%s`, `Accept`)", result, writer.Header().Get("Content-Type"), writer.Header().Get("Vary"), testCase.want, testCase.wantContentType)
+			}
+		})
+	}
+
+	instance.Remove(item)
+
+	for _, size := range instance.sizes {
+		if files, err := storage.List(path.Join(thumbnailsDirname, size.Name)); err != nil || len(files) != 0 {
+			t.Errorf("Remove() left %+v, %s", files, err)
+		}
+	}
+}
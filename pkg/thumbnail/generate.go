@@ -1,93 +1,148 @@
 package thumbnail
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"io"
-	"net/http"
+	"io/ioutil"
 	"path"
 	"time"
 
 	"github.com/ViBiOh/fibr/pkg/provider"
 	"github.com/ViBiOh/httputils/v3/pkg/logger"
-	"github.com/ViBiOh/httputils/v3/pkg/request"
 )
 
 const (
-	defaultTimeout = time.Second * 30
+	// defaultTimeout bounds each request to a sidecar
+	defaultTimeout  = time.Second * 30
+	persistInterval = time.Second * 10
+
+	maxAttempts = 5
+	retryDelay  = time.Second * 30
 )
 
 func (a app) generate(item provider.StorageItem) error {
-	var (
-		file io.ReadCloser
-		err  error
-	)
-
-	file, err = a.storage.ReaderFrom(item.Pathname)
-	if err != nil {
-		return err
+	backend := a.getBackend(item)
+	if backend == nil {
+		return fmt.Errorf("no generator for %s", item.Pathname)
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
-	defer cancel()
-
-	var resp *http.Response
+	variants := a.getVariants(backend)
 
-	if item.IsVideo() {
-		resp, err = request.New().Post(fmt.Sprintf("%s/", a.videoURL)).Send(ctx, file)
-		if err != nil {
-			return err
-		}
-
-		file = resp.Body
+	// Content is opened on demand, so a sidecar receives a stream for each variant instead of a copy in memory
+	source := func() (io.ReadCloser, error) {
+		return a.storage.ReaderFrom(item.Pathname)
 	}
 
-	resp, err = request.New().Post(a.imageURL).Send(ctx, file)
+	thumbnails, err := backend.generator.Generate(context.Background(), source, variants)
 	if err != nil {
 		return err
 	}
 
-	thumbnailPath := getThumbnailPath(item)
-	if err := a.storage.CreateDir(path.Dir(thumbnailPath)); err != nil {
-		return err
-	}
+	// Default JPEG is stored last, its date being the cache key of every variant
+	for index := len(variants) - 1; index >= 0; index-- {
+		variant := variants[index]
+		thumbnailPath := a.getThumbnailPath(item, variant.Size, variant.Format)
 
-	if err := a.storage.Store(thumbnailPath, resp.Body); err != nil {
-		return err
+		if err := a.storage.CreateDir(path.Dir(thumbnailPath)); err != nil {
+			return err
+		}
+
+		if err := a.storage.Store(thumbnailPath, ioutil.NopCloser(bytes.NewReader(thumbnails[index]))); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
-// GenerateThumbnail generate thumbnail image for given path
+// GenerateThumbnail queues thumbnail generation for given item, if a generator handles it
 func (a app) GenerateThumbnail(item provider.StorageItem) {
 	if !a.Enabled() {
 		return
 	}
 
-	a.pathnameInput <- item
+	if backend := a.getBackend(item); backend != nil {
+		backend.queue.push(task{Item: item})
+	}
 }
 
+// Start workers of each backend and persist queues periodically
 func (a app) Start() {
 	if !a.Enabled() {
 		return
 	}
 
-	if _, err := a.storage.Info(provider.MetadataDirectoryName); err != nil {
+	if err := a.storage.CreateDir(provider.MetadataDirectoryName); err != nil {
 		logger.Warn("no thumbnail generation because %s has error: %s", provider.MetadataDirectoryName, err)
+
+		// Nothing would ever consume queues
+		for _, backend := range a.backends {
+			backend.queue.close()
+		}
+
 		return
 	}
 
-	waitTimeout := time.Millisecond * 300
+	if err := a.loadQueue(); err != nil {
+		logger.Error("unable to load thumbnail queue: %s", err)
+	}
+
+	for index := range a.backends {
+		backend := &a.backends[index]
+
+		concurrency := backend.concurrency
+		if concurrency == 0 {
+			concurrency = 1
+		}
+
+		for i := uint(0); i < concurrency; i++ {
+			go a.work(backend)
+		}
+	}
 
-	for item := range a.pathnameInput {
-		if err := a.generate(item); err != nil {
-			logger.Error("unable to generate thumbnail for %s: %s", item.Pathname, err)
+	for range time.Tick(persistInterval) {
+		if err := a.saveQueue(); err != nil {
+			logger.Error("unable to save thumbnail queue: %s", err)
+		}
+	}
+}
+
+func (a app) work(backend *backend) {
+	for {
+		t, wait, ok := backend.queue.pop(time.Now())
+		if !ok {
+			backend.queue.wait(wait)
+			continue
+		}
+
+		if err := a.generate(t.Item); err != nil {
+			a.retry(backend, t, err)
 		} else {
-			logger.Info("Thumbnail generated for %s", item.Pathname)
+			logger.Info("Thumbnail generated for %s", t.Item.Pathname)
 		}
 
-		// Do not stress API
-		time.Sleep(waitTimeout)
+		backend.queue.done(t.Item.Pathname)
+	}
+}
+
+// retry queues failed task again with an exponential backoff, until maxAttempts
+func (a app) retry(backend *backend, t task, err error) {
+	t.Attempts++
+
+	if t.Attempts >= maxAttempts || provider.IsNotExist(err) {
+		logger.Error("unable to generate thumbnail for %s: %s", t.Item.Pathname, err)
+		return
 	}
+
+	delay := getRetryDelay(t.Attempts)
+	logger.Warn("unable to generate thumbnail for %s, retrying in %s: %s", t.Item.Pathname, delay, err)
+
+	t.NextTry = time.Now().Add(delay)
+	backend.queue.push(t)
+}
+
+func getRetryDelay(attempts uint) time.Duration {
+	return retryDelay << (attempts - 1)
 }
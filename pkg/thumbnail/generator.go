@@ -0,0 +1,53 @@
+package thumbnail
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+
+	"github.com/ViBiOh/fibr/pkg/provider"
+)
+
+// Source opens content of an item, once per read needed by generator
+type Source func() (io.ReadCloser, error)
+
+// Generator creates a thumbnail for each variant from content of an item, in the same order
+type Generator interface {
+	Handles(provider.StorageItem) bool
+	Supports(Format) bool
+	Generate(context.Context, Source, []Variant) ([][]byte, error)
+}
+
+// bytesSource gives a Source of content already in memory
+func bytesSource(content []byte) Source {
+	return func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(content)), nil
+	}
+}
+
+// backend is a generator with its own queue, consumed by at most concurrency workers
+type backend struct {
+	generator   Generator
+	concurrency uint
+	queue       *queue
+}
+
+func newBackend(generator Generator, concurrency uint) backend {
+	return backend{
+		generator:   generator,
+		concurrency: concurrency,
+		queue:       newQueue(),
+	}
+}
+
+// getBackend gives first backend handling given item
+func (a app) getBackend(item provider.StorageItem) *backend {
+	for index := range a.backends {
+		if a.backends[index].generator.Handles(item) {
+			return &a.backends[index]
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,296 @@
+package thumbnail
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/ViBiOh/fibr/pkg/provider"
+	"github.com/ViBiOh/httputils/v3/pkg/logger"
+)
+
+var queueFilename = path.Join(provider.MetadataDirectoryName, "thumbnails.json")
+
+// task is a pending thumbnail generation
+type task struct {
+	Item     provider.StorageItem
+	Attempts uint
+	NextTry  time.Time
+}
+
+// persistedTask is a task as saved on storage, item being read again on load
+type persistedTask struct {
+	Pathname string    `json:"pathname"`
+	Attempts uint      `json:"attempts"`
+	NextTry  time.Time `json:"nextTry"`
+}
+
+// queue is a non-blocking FIFO of tasks, de-duplicated by pathname. A task pushed while its pathname is being
+// generated waits until the worker is done, so two workers never write thumbnails of the same item
+type queue struct {
+	tasks   []task
+	index   map[string]bool
+	running map[string]task
+	waiting map[string]task
+	notify  chan struct{}
+	dirty   bool
+	closed  bool
+	mutex   sync.Mutex
+}
+
+func newQueue() *queue {
+	return &queue{
+		tasks:   make([]task, 0),
+		index:   make(map[string]bool),
+		running: make(map[string]task),
+		waiting: make(map[string]task),
+		notify:  make(chan struct{}, 1),
+	}
+}
+
+func (q *queue) signal() {
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+}
+
+// push adds task to queue, returning false if its pathname is already pending or queue is closed
+func (q *queue) push(t task) bool {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if q.closed || q.index[t.Item.Pathname] {
+		return false
+	}
+
+	if _, ok := q.running[t.Item.Pathname]; ok {
+		if _, ok := q.waiting[t.Item.Pathname]; ok {
+			return false
+		}
+
+		q.waiting[t.Item.Pathname] = t
+		return true
+	}
+
+	q.enqueue(t)
+
+	return true
+}
+
+// enqueue appends task, caller must hold the lock
+func (q *queue) enqueue(t task) {
+	q.tasks = append(q.tasks, t)
+	q.index[t.Item.Pathname] = true
+	q.dirty = true
+
+	q.signal()
+}
+
+// pop takes first task ready at given time. If none, it gives how long to wait for next one, zero meaning until a push
+func (q *queue) pop(now time.Time) (task, time.Duration, bool) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	var wait time.Duration
+
+	for index, t := range q.tasks {
+		if delay := t.NextTry.Sub(now); delay > 0 {
+			if wait == 0 || delay < wait {
+				wait = delay
+			}
+
+			continue
+		}
+
+		q.tasks = append(q.tasks[:index], q.tasks[index+1:]...)
+		delete(q.index, t.Item.Pathname)
+		q.running[t.Item.Pathname] = t
+		q.dirty = true
+
+		// Wake another worker if there is still work to do
+		if len(q.tasks) != 0 {
+			q.signal()
+		}
+
+		return t, 0, true
+	}
+
+	return task{}, wait, false
+}
+
+// done marks running task of pathname as finished, queuing the task that waited for it, if any
+func (q *queue) done(pathname string) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	delete(q.running, pathname)
+	q.dirty = true
+
+	if t, ok := q.waiting[pathname]; ok {
+		delete(q.waiting, pathname)
+		q.enqueue(t)
+	}
+}
+
+// remove drops pending task of pathname, returning true if there was one
+func (q *queue) remove(pathname string) bool {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if _, ok := q.waiting[pathname]; ok {
+		delete(q.waiting, pathname)
+		return true
+	}
+
+	if !q.index[pathname] {
+		return false
+	}
+
+	for index, t := range q.tasks {
+		if t.Item.Pathname == pathname {
+			q.tasks = append(q.tasks[:index], q.tasks[index+1:]...)
+			break
+		}
+	}
+
+	delete(q.index, pathname)
+	q.dirty = true
+
+	return true
+}
+
+// close drops pending tasks and refuses new ones, when no worker consumes queue
+func (q *queue) close() {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	q.closed = true
+	q.tasks = nil
+	q.index = make(map[string]bool)
+	q.waiting = make(map[string]task)
+}
+
+// wait blocks until a push or given duration, zero meaning only a push
+func (q *queue) wait(duration time.Duration) {
+	if duration == 0 {
+		<-q.notify
+		return
+	}
+
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+
+	select {
+	case <-q.notify:
+	case <-timer.C:
+	}
+}
+
+// snapshot gives running, waiting and pending tasks, and whether queue changed since last snapshot
+func (q *queue) snapshot() ([]task, bool) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	tasks := make([]task, 0, len(q.running)+len(q.waiting)+len(q.tasks))
+	for _, t := range q.running {
+		tasks = append(tasks, t)
+	}
+	for _, t := range q.waiting {
+		tasks = append(tasks, t)
+	}
+	tasks = append(tasks, q.tasks...)
+
+	dirty := q.dirty
+	q.dirty = false
+
+	return tasks, dirty
+}
+
+// markDirty forces next snapshot, e.g. when it has not been persisted
+func (q *queue) markDirty() {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	q.dirty = true
+}
+
+// loadQueue dispatches persisted tasks to their backend
+func (a app) loadQueue() error {
+	file, err := a.storage.ReaderFrom(queueFilename)
+	if err != nil {
+		if provider.IsNotExist(err) {
+			return nil
+		}
+
+		return err
+	}
+
+	defer func() {
+		if closeErr := file.Close(); closeErr != nil {
+			logger.Error("unable to close thumbnail queue: %s", closeErr)
+		}
+	}()
+
+	rawTasks, err := ioutil.ReadAll(file)
+	if err != nil {
+		return err
+	}
+
+	var tasks []persistedTask
+	if err := json.Unmarshal(rawTasks, &tasks); err != nil {
+		return err
+	}
+
+	for _, t := range tasks {
+		item, err := a.storage.Info(t.Pathname)
+		if err != nil {
+			if !provider.IsNotExist(err) {
+				logger.Error("unable to get info of %s for thumbnail queue: %s", t.Pathname, err)
+			}
+
+			continue
+		}
+
+		if backend := a.getBackend(item); backend != nil {
+			backend.queue.push(task{Item: item, Attempts: t.Attempts, NextTry: t.NextTry})
+		}
+	}
+
+	return nil
+}
+
+// saveQueue persists tasks of every backend, if any of them changed
+func (a app) saveQueue() error {
+	tasks := make([]persistedTask, 0)
+	changed := false
+
+	for _, backend := range a.backends {
+		backendTasks, dirty := backend.queue.snapshot()
+
+		for _, t := range backendTasks {
+			tasks = append(tasks, persistedTask{Pathname: t.Item.Pathname, Attempts: t.Attempts, NextTry: t.NextTry})
+		}
+		changed = changed || dirty
+	}
+
+	if !changed {
+		return nil
+	}
+
+	content, err := json.Marshal(tasks)
+	if err == nil {
+		err = a.storage.Store(queueFilename, ioutil.NopCloser(bytes.NewReader(content)))
+	}
+
+	if err != nil {
+		for _, backend := range a.backends {
+			backend.queue.markDirty()
+		}
+	}
+
+	return err
+}
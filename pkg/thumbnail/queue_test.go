@@ -0,0 +1,228 @@
+package thumbnail
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ViBiOh/fibr/pkg/memory"
+	"github.com/ViBiOh/fibr/pkg/provider"
+)
+
+func newTask(pathname string, nextTry time.Time) task {
+	return task{
+		Item:    provider.StorageItem{Pathname: pathname, Name: pathname[1:]},
+		NextTry: nextTry,
+	}
+}
+
+func TestPop(t *testing.T) {
+	now := time.Date(2020, 9, 1, 12, 0, 0, 0, time.UTC)
+
+	var cases = []struct {
+		intention string
+		input     []task
+		want      string
+		wantWait  time.Duration
+		wantOk    bool
+	}{
+		{
+			"empty",
+			nil,
+			"",
+			0,
+			false,
+		},
+		{
+			"fifo",
+			[]task{newTask("/first.png", time.Time{}), newTask("/second.png", time.Time{})},
+			"/first.png",
+			0,
+			true,
+		},
+		{
+			"de-duplicated",
+			[]task{newTask("/first.png", now.Add(time.Minute)), newTask("/first.png", time.Time{})},
+			"",
+			time.Minute,
+			false,
+		},
+		{
+			"skip delayed",
+			[]task{newTask("/first.png", now.Add(time.Minute)), newTask("/second.png", now)},
+			"/second.png",
+			0,
+			true,
+		},
+		{
+			"wait earliest",
+			[]task{newTask("/first.png", now.Add(time.Minute)), newTask("/second.png", now.Add(time.Second))},
+			"",
+			time.Second,
+			false,
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.intention, func(t *testing.T) {
+			q := newQueue()
+			for _, input := range testCase.input {
+				q.push(input)
+			}
+
+			result, wait, ok := q.pop(now)
+
+			if result.Item.Pathname != testCase.want || wait != testCase.wantWait || ok != testCase.wantOk {
+				t.Errorf("pop() = (`%s`, %s, %t), want (`%s`, %s, %t)", result.Item.Pathname, wait, ok, testCase.want, testCase.wantWait, testCase.wantOk)
+			}
+		})
+	}
+}
+
+func TestRemove(t *testing.T) {
+	q := newQueue()
+	q.push(newTask("/first.png", time.Time{}))
+	q.push(newTask("/second.png", time.Time{}))
+
+	if !q.remove("/first.png") {
+		t.Errorf("remove() = false, want true")
+	}
+
+	if q.remove("/first.png") {
+		t.Errorf("remove() = true, want false")
+	}
+
+	if result, _, _ := q.pop(time.Now()); result.Item.Pathname != "/second.png" {
+		t.Errorf("pop() = `%s`, want `/second.png`", result.Item.Pathname)
+	}
+
+	if !q.push(newTask("/second.png", time.Time{})) {
+		t.Errorf("push() = false, want true while running")
+	}
+}
+
+func TestPushRunning(t *testing.T) {
+	now := time.Now()
+
+	q := newQueue()
+	q.push(newTask("/first.png", time.Time{}))
+
+	if _, _, ok := q.pop(now); !ok {
+		t.Fatalf("pop() = false, want task")
+	}
+
+	if !q.push(newTask("/first.png", time.Time{})) {
+		t.Errorf("push() = false, want task accepted while running")
+	}
+
+	if q.push(newTask("/first.png", time.Time{})) {
+		t.Errorf("push() = true, want task already waiting")
+	}
+
+	if result, _, ok := q.pop(now); ok {
+		t.Errorf("pop() = `%s`, want nothing while same pathname is running", result.Item.Pathname)
+	}
+
+	q.done("/first.png")
+
+	if result, _, ok := q.pop(now); !ok || result.Item.Pathname != "/first.png" {
+		t.Errorf("pop() = (`%s`, %t), want waiting task once done", result.Item.Pathname, ok)
+	}
+}
+
+func TestPushClosed(t *testing.T) {
+	q := newQueue()
+	q.push(newTask("/first.png", time.Time{}))
+	q.close()
+
+	if q.push(newTask("/second.png", time.Time{})) {
+		t.Errorf("push() = true, want task refused by closed queue")
+	}
+
+	if tasks, _ := q.snapshot(); len(tasks) != 0 {
+		t.Errorf("close() = %d tasks, want none kept", len(tasks))
+	}
+}
+
+func TestGetRetryDelay(t *testing.T) {
+	var cases = []struct {
+		intention string
+		input     uint
+		want      time.Duration
+	}{
+		{
+			"first",
+			1,
+			retryDelay,
+		},
+		{
+			"exponential",
+			3,
+			retryDelay * 4,
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.intention, func(t *testing.T) {
+			if result := getRetryDelay(testCase.input); result != testCase.want {
+				t.Errorf("getRetryDelay() = %s, want %s", result, testCase.want)
+			}
+		})
+	}
+}
+
+func TestSaveQueue(t *testing.T) {
+	storage := memory.New()
+	if err := storage.CreateDir(provider.MetadataDirectoryName); err != nil {
+		t.Fatalf("CreateDir() = %s", err)
+	}
+
+	for _, pathname := range []string{"/running.png", "/pending.jpg"} {
+		if err := storage.Store(pathname, ioutil.NopCloser(strings.NewReader("image"))); err != nil {
+			t.Fatalf("Store() = %s", err)
+		}
+	}
+
+	instance := app{
+		storage:  storage,
+		backends: []backend{newBackend(builtinGenerator{}, 1)},
+	}
+
+	instance.GenerateThumbnail(provider.StorageItem{Pathname: "/running.png", Name: "running.png"})
+	instance.GenerateThumbnail(provider.StorageItem{Pathname: "/pending.jpg", Name: "pending.jpg", Info: "not serializable"})
+	instance.GenerateThumbnail(provider.StorageItem{Pathname: "/deleted.png", Name: "deleted.png"})
+	instance.GenerateThumbnail(provider.StorageItem{Pathname: "/report.pdf", Name: "report.pdf"})
+	instance.backends[0].queue.pop(time.Now())
+
+	if err := instance.saveQueue(); err != nil {
+		t.Fatalf("saveQueue() = %s", err)
+	}
+
+	restarted := app{
+		storage:  storage,
+		backends: []backend{newBackend(builtinGenerator{}, 1)},
+	}
+
+	if err := restarted.loadQueue(); err != nil {
+		t.Fatalf("loadQueue() = %s", err)
+	}
+
+	var pathnames []string
+	for {
+		next, _, ok := restarted.backends[0].queue.pop(time.Now())
+		if !ok {
+			break
+		}
+
+		if next.Item.Size != int64(len("image")) {
+			t.Errorf("loadQueue() = %+v, want item read from storage", next.Item)
+		}
+
+		pathnames = append(pathnames, next.Item.Pathname)
+	}
+
+	if len(pathnames) != 2 || pathnames[0] != "/running.png" || pathnames[1] != "/pending.jpg" {
+		t.Errorf("loadQueue() = %v, want [/running.png /pending.jpg]", pathnames)
+	}
+}
@@ -0,0 +1,134 @@
+package thumbnail
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/ViBiOh/fibr/pkg/provider"
+	"github.com/ViBiOh/httputils/v3/pkg/logger"
+	"github.com/ViBiOh/httputils/v3/pkg/request"
+)
+
+// imaginaryGenerator crops images and PDF with an imaginary sidecar, in JPEG or WebP
+type imaginaryGenerator struct {
+	url string
+}
+
+func newImaginaryGenerator(imageURL string) imaginaryGenerator {
+	return imaginaryGenerator{
+		url: imageURL,
+	}
+}
+
+func (g imaginaryGenerator) Handles(item provider.StorageItem) bool {
+	return item.IsImage() || item.IsPdf()
+}
+
+func (g imaginaryGenerator) Supports(format Format) bool {
+	return format == JPEG || format == WebP
+}
+
+// Generate streams content to imaginary for each variant, each request having its own timeout
+func (g imaginaryGenerator) Generate(ctx context.Context, open Source, variants []Variant) ([][]byte, error) {
+	thumbnails := make([][]byte, len(variants))
+
+	for index, variant := range variants {
+		url := fmt.Sprintf("%s/crop?width=%d&height=%d&stripmeta=true&noprofile=true&quality=%d&type=%s", g.url, variant.Width, variant.Height, jpegQuality, variant.Format)
+
+		thumbnail, err := g.crop(ctx, url, open)
+		if err != nil {
+			return nil, err
+		}
+
+		thumbnails[index] = thumbnail
+	}
+
+	return thumbnails, nil
+}
+
+func (g imaginaryGenerator) crop(ctx context.Context, url string, open Source) ([]byte, error) {
+	content, err := open()
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err := content.Close(); err != nil {
+			logger.Error("unable to close content: %s", err)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	resp, err := request.New().Post(url).Send(ctx, content)
+	if err != nil {
+		return nil, err
+	}
+
+	return request.ReadBodyResponse(resp)
+}
+
+// videoGenerator extracts a frame of video with a vith sidecar, then crops it with image generator
+type videoGenerator struct {
+	url   string
+	image Generator
+}
+
+func (g videoGenerator) Handles(item provider.StorageItem) bool {
+	return item.IsVideo()
+}
+
+func (g videoGenerator) Supports(format Format) bool {
+	return g.image.Supports(format)
+}
+
+// Generate extracts a frame once, kept in memory within maxImageBytes, then crops it for each variant
+func (g videoGenerator) Generate(ctx context.Context, open Source, variants []Variant) ([][]byte, error) {
+	frame, err := g.extractFrame(ctx, open)
+	if err != nil {
+		return nil, err
+	}
+
+	return g.image.Generate(ctx, bytesSource(frame), variants)
+}
+
+func (g videoGenerator) extractFrame(ctx context.Context, open Source) ([]byte, error) {
+	content, err := open()
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err := content.Close(); err != nil {
+			logger.Error("unable to close video: %s", err)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	resp, err := request.New().Post(fmt.Sprintf("%s/", g.url)).Send(ctx, content)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			logger.Error("unable to close video frame: %s", err)
+		}
+	}()
+
+	frame, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxImageBytes+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(frame) > maxImageBytes {
+		return nil, ErrImageTooLarge
+	}
+
+	return frame, nil
+}
@@ -0,0 +1,67 @@
+package thumbnail
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestImaginaryGenerate(t *testing.T) {
+	var (
+		mutex  sync.Mutex
+		bodies []string
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		mutex.Lock()
+		bodies = append(bodies, string(body))
+		mutex.Unlock()
+
+		_, _ = w.Write([]byte(r.URL.Query().Get("type")))
+	}))
+	defer server.Close()
+
+	opened := 0
+	source := func() (io.ReadCloser, error) {
+		opened++
+		return ioutil.NopCloser(strings.NewReader("original")), nil
+	}
+
+	variants := []Variant{{Size: Size{Width: 150, Height: 150}, Format: JPEG}, {Size: Size{Width: 150, Height: 150}, Format: WebP}}
+
+	thumbnails, err := newImaginaryGenerator(server.URL).Generate(context.Background(), source, variants)
+	if err != nil {
+		t.Fatalf("Generate() = %s", err)
+	}
+
+	if len(thumbnails) != 2 || string(thumbnails[0]) != string(JPEG) || string(thumbnails[1]) != string(WebP) {
+		t.Errorf("Generate() = %q, want a thumbnail per variant", thumbnails)
+	}
+
+	if opened != 2 || len(bodies) != 2 || bodies[0] != "original" || bodies[1] != "original" {
+		t.Errorf("Generate() = %d opened, %q sent, want content streamed once per variant", opened, bodies)
+	}
+
+	large := func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(io.LimitReader(zeroReader{}, maxImageBytes+10)), nil
+	}
+
+	if _, err := newImaginaryGenerator(server.URL).Generate(context.Background(), large, variants[:1]); err != nil {
+		t.Errorf("Generate() = `%s`, want large content handled by imaginary", err)
+	}
+
+	if len(bodies) != 3 || len(bodies[2]) != maxImageBytes+10 {
+		t.Errorf("Generate() = %d requests, want large content streamed in full", len(bodies))
+	}
+}
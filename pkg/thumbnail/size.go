@@ -0,0 +1,110 @@
+package thumbnail
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Size of a thumbnail, a name suffixed by `@<density>x` being a high density variant of the size with that name
+type Size struct {
+	Name   string
+	Width  int
+	Height int
+}
+
+// Base gives name of the size this one is a variant of, and its pixel density
+func (s Size) Base() (string, int) {
+	parts := strings.SplitN(s.Name, "@", 2)
+	if len(parts) != 2 || !strings.HasSuffix(parts[1], "x") {
+		return s.Name, 1
+	}
+
+	density, err := strconv.Atoi(strings.TrimSuffix(parts[1], "x"))
+	if err != nil || density < 1 {
+		return s.Name, 1
+	}
+
+	return parts[0], density
+}
+
+// parseSizes parses a comma separated list of `name:widthxheight`
+func parseSizes(value string) ([]Size, error) {
+	sizes := make([]Size, 0)
+	names := make(map[string]bool)
+
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if len(part) == 0 {
+			continue
+		}
+
+		nameDimensions := strings.SplitN(part, ":", 2)
+		if len(nameDimensions) != 2 {
+			return nil, fmt.Errorf("invalid size `%s`: format is name:widthxheight", part)
+		}
+
+		name := strings.TrimSpace(nameDimensions[0])
+		if len(name) == 0 || strings.ContainsAny(name, "/.") {
+			return nil, fmt.Errorf("invalid size name `%s`", name)
+		}
+
+		if names[name] {
+			return nil, fmt.Errorf("duplicate size `%s`", name)
+		}
+
+		dimensions := strings.SplitN(strings.TrimSpace(nameDimensions[1]), "x", 2)
+		if len(dimensions) != 2 {
+			return nil, fmt.Errorf("invalid dimensions for size `%s`: format is widthxheight", name)
+		}
+
+		width, err := strconv.Atoi(dimensions[0])
+		if err != nil || width <= 0 {
+			return nil, fmt.Errorf("invalid width for size `%s`", name)
+		}
+
+		height, err := strconv.Atoi(dimensions[1])
+		if err != nil || height <= 0 {
+			return nil, fmt.Errorf("invalid height for size `%s`", name)
+		}
+
+		names[name] = true
+		sizes = append(sizes, Size{Name: name, Width: width, Height: height})
+	}
+
+	if len(sizes) == 0 {
+		return nil, errors.New("no thumbnail size")
+	}
+
+	return sizes, nil
+}
+
+// getSize gives size of given name, default one if name is empty
+func (a app) getSize(name string) (Size, bool) {
+	if len(a.sizes) == 0 {
+		return Size{}, false
+	}
+
+	if len(name) == 0 {
+		return a.sizes[0], true
+	}
+
+	for _, size := range a.sizes {
+		if size.Name == name {
+			return size, true
+		}
+	}
+
+	return Size{}, false
+}
+
+// Cover gives size used for social networks' preview
+func (a app) Cover() Size {
+	if size, ok := a.getSize(a.cover); ok {
+		return size
+	}
+
+	size, _ := a.getSize("")
+	return size
+}
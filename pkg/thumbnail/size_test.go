@@ -0,0 +1,199 @@
+package thumbnail
+
+import (
+	"errors"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseSizes(t *testing.T) {
+	var cases = []struct {
+		intention string
+		input     string
+		want      []Size
+		wantErr   error
+	}{
+		{
+			"simple",
+			"small:150x150",
+			[]Size{{Name: "small", Width: 150, Height: 150}},
+			nil,
+		},
+		{
+			"multiple",
+			" small:150x150, small@2x:300x300,,large:1200x630",
+			[]Size{{Name: "small", Width: 150, Height: 150}, {Name: "small@2x", Width: 300, Height: 300}, {Name: "large", Width: 1200, Height: 630}},
+			nil,
+		},
+		{
+			"empty",
+			"",
+			nil,
+			errors.New("no thumbnail size"),
+		},
+		{
+			"no dimensions",
+			"small",
+			nil,
+			errors.New("invalid size `small`: format is name:widthxheight"),
+		},
+		{
+			"invalid name",
+			"../small:150x150",
+			nil,
+			errors.New("invalid size name `../small`"),
+		},
+		{
+			"invalid width",
+			"small:0x150",
+			nil,
+			errors.New("invalid width for size `small`"),
+		},
+		{
+			"duplicate",
+			"small:150x150,small:300x300",
+			nil,
+			errors.New("duplicate size `small`"),
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.intention, func(t *testing.T) {
+			result, err := parseSizes(testCase.input)
+
+			failed := false
+
+			if err == nil && testCase.wantErr != nil {
+				failed = true
+			} else if err != nil && testCase.wantErr == nil {
+				failed = true
+			} else if err != nil && !strings.Contains(err.Error(), testCase.wantErr.Error()) {
+				failed = true
+			} else if !reflect.DeepEqual(result, testCase.want) {
+				failed = true
+			}
+
+			if failed {
+				t.Errorf("parseSizes() = (%+v, `%s`), want (%+v, `%s`)", result, err, testCase.want, testCase.wantErr)
+			}
+		})
+	}
+}
+
+func TestBase(t *testing.T) {
+	var cases = []struct {
+		intention   string
+		input       Size
+		want        string
+		wantDensity int
+	}{
+		{
+			"simple",
+			Size{Name: "small"},
+			"small",
+			1,
+		},
+		{
+			"retina",
+			Size{Name: "small@2x"},
+			"small",
+			2,
+		},
+		{
+			"not a density",
+			Size{Name: "small@large"},
+			"small@large",
+			1,
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.intention, func(t *testing.T) {
+			if result, density := testCase.input.Base(); result != testCase.want || density != testCase.wantDensity {
+				t.Errorf("Base() = (`%s`, %d), want (`%s`, %d)", result, density, testCase.want, testCase.wantDensity)
+			}
+		})
+	}
+}
+
+func TestSrcset(t *testing.T) {
+	var cases = []struct {
+		intention string
+		instance  app
+		input     string
+		want      string
+	}{
+		{
+			"no size",
+			app{},
+			"photo.jpg",
+			"",
+		},
+		{
+			"no variant",
+			app{sizes: []Size{{Name: "small"}, {Name: "large"}}},
+			"photo.jpg",
+			"",
+		},
+		{
+			"variants",
+			app{sizes: []Size{{Name: "small"}, {Name: "small@2x"}, {Name: "large@2x"}, {Name: "small@3x"}}},
+			"my photo.jpg",
+			"my%20photo.jpg?thumbnail=small%402x&t=1 2x, my%20photo.jpg?thumbnail=small%403x&t=1 3x",
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.intention, func(t *testing.T) {
+			if result := testCase.instance.getSrcset(testCase.input, "1"); result != testCase.want {
+				t.Errorf("getSrcset() = `%s`, want `%s`", result, testCase.want)
+			}
+		})
+	}
+}
+
+func TestIsRequested(t *testing.T) {
+	var cases = []struct {
+		intention string
+		input     string
+		want      bool
+		wantSize  string
+	}{
+		{
+			"absent",
+			"/photo.jpg",
+			false,
+			"",
+		},
+		{
+			"empty",
+			"/photo.jpg?thumbnail",
+			true,
+			"",
+		},
+		{
+			"false",
+			"/photo.jpg?thumbnail=false",
+			false,
+			"",
+		},
+		{
+			"size",
+			"/photo.jpg?thumbnail=large",
+			true,
+			"large",
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.intention, func(t *testing.T) {
+			r := httptest.NewRequest("GET", testCase.input, nil)
+
+			if result, size := IsRequested(r), getRequestedSize(r); result != testCase.want || size != testCase.wantSize {
+				t.Errorf("IsRequested() = (%t, `%s`), want (%t, `%s`)", result, size, testCase.want, testCase.wantSize)
+			}
+		})
+	}
+}
@@ -1,147 +1,287 @@
 package thumbnail
 
 import (
-	"encoding/base64"
 	"flag"
 	"fmt"
-	"io/ioutil"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 
 	"github.com/ViBiOh/fibr/pkg/provider"
 	"github.com/ViBiOh/fibr/pkg/sha"
 	"github.com/ViBiOh/httputils/v3/pkg/flags"
 	"github.com/ViBiOh/httputils/v3/pkg/httperror"
+	"github.com/ViBiOh/httputils/v3/pkg/httpjson"
 	"github.com/ViBiOh/httputils/v3/pkg/logger"
+	"github.com/ViBiOh/httputils/v3/pkg/query"
 )
 
 const (
-	// Width is the width of each thumbnail generated
-	Width = 150
+	cacheKeyParam         = "t"
+	immutableCacheControl = "private, max-age=31536000, immutable"
 
-	// Height is the width of each thumbnail generated
-	Height = 150
+	defaultPageSize = 100
+	maxPageSize     = 500
 )
 
+// apiThumbnail is the JSON representation of a thumbnail
+type apiThumbnail struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	URL    string `json:"url"`
+	Srcset string `json:"srcset,omitempty"`
+}
+
 // App of package
 type App interface {
 	Start()
 	Remove(provider.StorageItem)
 	Rename(provider.StorageItem, provider.StorageItem)
 	HasThumbnail(provider.StorageItem) bool
+	HasAllSizes(provider.StorageItem) bool
+	Cover() Size
+	URLs(provider.StorageItem) (string, string)
 	Serve(http.ResponseWriter, *http.Request, provider.StorageItem)
-	List(http.ResponseWriter, *http.Request, provider.StorageItem)
+	List(http.ResponseWriter, *http.Request, []provider.StorageItem)
 	GenerateThumbnail(provider.StorageItem)
 }
 
 // Config of package
 type Config struct {
-	imageURL *string
-	videoURL *string
+	imageURL         *string
+	videoURL         *string
+	disable          *bool
+	imageConcurrency *uint
+	videoConcurrency *uint
+	sizes            *string
+	cover            *string
+	webp             *bool
 }
 
 type app struct {
-	imageURL      string
-	videoURL      string
-	storage       provider.Storage
-	pathnameInput chan provider.StorageItem
+	storage  provider.Storage
+	backends []backend
+	sizes    []Size
+	cover    string
+	webp     bool
 }
 
 // Flags adds flags for configuring package
 func Flags(fs *flag.FlagSet, prefix string) Config {
 	return Config{
-		imageURL: flags.New(prefix, "thumbnail").Name("imageURL").Default("http://image:9000").Label("Imaginary URL").ToString(fs),
-		videoURL: flags.New(prefix, "vith").Name("VideoURL").Default("http://video:1080").Label("Video Thumbnail URL").ToString(fs),
+		imageURL:         flags.New(prefix, "thumbnail").Name("imageURL").Default("").Label("Imaginary URL, for PDF and more image formats, built-in generator for JPEG, PNG and GIF if empty").ToString(fs),
+		videoURL:         flags.New(prefix, "vith").Name("VideoURL").Default("").Label("Video Thumbnail URL, no video thumbnail if empty").ToString(fs),
+		disable:          flags.New(prefix, "thumbnail").Name("Disable").Default(false).Label("Disable thumbnail generation").ToBool(fs),
+		imageConcurrency: flags.New(prefix, "thumbnail").Name("ImageConcurrency").Default(2).Label("Maximum concurrent image thumbnail generations").ToUint(fs),
+		videoConcurrency: flags.New(prefix, "thumbnail").Name("VideoConcurrency").Default(1).Label("Maximum concurrent video thumbnail generations").ToUint(fs),
+		sizes:            flags.New(prefix, "thumbnail").Name("Sizes").Default("small:150x150,small@2x:300x300,large:1200x630").Label("Thumbnail sizes, comma separated name:widthxheight, first one being the default, name@2x being high density variant of name").ToString(fs),
+		cover:            flags.New(prefix, "thumbnail").Name("Cover").Default("large").Label("Thumbnail size for social networks' preview").ToString(fs),
+		webp:             flags.New(prefix, "thumbnail").Name("Webp").Default(false).Label("Generate WebP thumbnails too, served to browsers accepting it, if generator supports it").ToBool(fs),
 	}
 }
 
 // New creates new App from Config
-func New(config Config, storage provider.Storage) App {
-	imageURL := strings.TrimSpace(*config.imageURL)
-	if len(imageURL) == 0 {
-		return &app{}
+func New(config Config, storage provider.Storage) (App, error) {
+	sizes, err := parseSizes(*config.sizes)
+	if err != nil {
+		return nil, err
 	}
 
-	videoURL := strings.TrimSpace(*config.videoURL)
-	if len(videoURL) == 0 {
-		return &app{}
+	cover := strings.TrimSpace(*config.cover)
+
+	if *config.disable {
+		return &app{
+			sizes: sizes,
+			cover: cover,
+		}, nil
 	}
 
-	app := &app{
-		imageURL:      fmt.Sprintf("%s/crop?width=%d&height=%d&stripmeta=true&noprofile=true&quality=80&type=jpeg", imageURL, Width, Height),
-		videoURL:      videoURL,
-		storage:       storage,
-		pathnameInput: make(chan provider.StorageItem, 10),
+	var imageGenerator Generator = builtinGenerator{}
+	if imageURL := strings.TrimSpace(*config.imageURL); len(imageURL) != 0 {
+		imageGenerator = newImaginaryGenerator(imageURL)
 	}
 
-	return app
+	backends := []backend{newBackend(imageGenerator, *config.imageConcurrency)}
+	if videoURL := strings.TrimSpace(*config.videoURL); len(videoURL) != 0 {
+		backends = append(backends, newBackend(videoGenerator{url: videoURL, image: imageGenerator}, *config.videoConcurrency))
+	}
+
+	return &app{
+		storage:  storage,
+		backends: backends,
+		sizes:    sizes,
+		cover:    cover,
+		webp:     *config.webp,
+	}, nil
 }
 
 // Enabled checks if app is enabled
 func (a app) Enabled() bool {
-	return len(a.imageURL) != 0 && len(a.videoURL) != 0 && a.storage != nil
+	return len(a.backends) != 0 && a.storage != nil
 }
 
-// Serve check if thumbnail is present and serve it
+// Serve check if thumbnail is present and serve it, in size requested by `thumbnail` query param
 func (a app) Serve(w http.ResponseWriter, r *http.Request, item provider.StorageItem) {
-	if !CanHaveThumbnail(item) || !a.HasThumbnail(item) {
+	size, ok := a.getSize(getRequestedSize(r))
+	if !ok {
+		httperror.BadRequest(w, fmt.Errorf("unknown thumbnail size `%s`", getRequestedSize(r)))
+		return
+	}
+
+	if !CanHaveThumbnail(item) {
 		w.WriteHeader(http.StatusNoContent)
 		return
 	}
 
-	file, err := a.storage.ReaderFrom(getThumbnailPath(item))
+	cacheKey := a.getCacheKey(item)
+	if len(cacheKey) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	info, format, err := a.getServedThumbnail(item, size, a.getAcceptedFormat(r))
 	if err != nil {
 		httperror.InternalServerError(w, err)
 		return
 	}
 
-	http.ServeContent(w, r, item.Name, item.Date, file)
+	file, err := a.storage.ReaderFrom(info.Pathname)
+	if err != nil {
+		httperror.InternalServerError(w, err)
+		return
+	}
+
+	defer func() {
+		if err := file.Close(); err != nil {
+			logger.Error("unable to close %s: %s", info.Pathname, err)
+		}
+	}()
+
+	if a.webp {
+		w.Header().Add("Vary", "Accept")
+	}
+
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("ETag", fmt.Sprintf(`"%s"`, sha.Sha1(fmt.Sprintf("%s:%d:%d", info.Pathname, info.Date.UnixNano(), info.Size))))
+
+	if r.URL.Query().Get(cacheKeyParam) == cacheKey {
+		w.Header().Set("Cache-Control", immutableCacheControl)
+	} else {
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+
+	http.ServeContent(w, r, item.Name, info.Date, file)
 }
 
-// List return all thumbnail in a base64 form
-func (a app) List(w http.ResponseWriter, _ *http.Request, item provider.StorageItem) {
+// getServedThumbnail gives the best thumbnail available, falling back to JPEG then to default size
+func (a app) getServedThumbnail(item provider.StorageItem, size Size, format Format) (provider.StorageItem, Format, error) {
+	candidates := []Variant{{Size: size, Format: format}, {Size: size, Format: JPEG}}
+
+	for _, candidate := range candidates {
+		if info, err := a.storage.Info(a.getThumbnailPath(item, candidate.Size, candidate.Format)); err == nil {
+			return info, candidate.Format, nil
+		}
+	}
+
+	// Generated before this size was configured
+	info, err := a.storage.Info(a.getDefaultThumbnailPath(item))
+	return info, JPEG, err
+}
+
+// getCacheKey gives key changing each time thumbnails of item are generated, empty if there is none
+func (a app) getCacheKey(item provider.StorageItem) string {
 	if !a.Enabled() {
-		w.WriteHeader(http.StatusNoContent)
-		return
+		return ""
 	}
 
-	items, err := a.storage.List(item.Pathname)
+	info, err := a.storage.Info(a.getDefaultThumbnailPath(item))
 	if err != nil {
-		httperror.InternalServerError(w, err)
-		return
+		return ""
 	}
 
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	w.Header().Set("Cache-Control", "no-cache")
-	w.WriteHeader(http.StatusOK)
+	return strconv.FormatInt(info.Date.UnixNano(), 36)
+}
 
-	commaNeeded := false
-	provider.SafeWrite(w, "{")
+// URLs gives cacheable URL of default size thumbnail of item, relative to its directory, and its high density variants in srcset format
+func (a app) URLs(item provider.StorageItem) (string, string) {
+	key := a.getCacheKey(item)
 
-	for _, item := range items {
-		if item.IsDir || !a.HasThumbnail(item) {
-			continue
-		}
+	return getURL(item.Name, "", key), a.getSrcset(item.Name, key)
+}
+
+func getURL(name, size, key string) string {
+	thumbnailURL := fmt.Sprintf("%s?thumbnail", url.PathEscape(name))
+	if len(size) != 0 {
+		thumbnailURL = fmt.Sprintf("%s=%s", thumbnailURL, url.QueryEscape(size))
+	}
 
-		file, err := a.storage.ReaderFrom(getThumbnailPath(item))
-		if err != nil {
-			logger.Error("unable to open %s: %s", item.Pathname, err)
+	if len(key) != 0 {
+		thumbnailURL = fmt.Sprintf("%s&%s=%s", thumbnailURL, cacheKeyParam, key)
+	}
+
+	return thumbnailURL
+}
+
+func (a app) getSrcset(name, key string) string {
+	if len(a.sizes) == 0 {
+		return ""
+	}
+
+	defaultName, _ := a.sizes[0].Base()
+	variants := make([]string, 0)
+
+	for _, size := range a.sizes[1:] {
+		if base, density := size.Base(); base == defaultName && density > 1 {
+			variants = append(variants, fmt.Sprintf("%s %dx", getURL(name, size.Name, key), density))
 		}
+	}
 
-		content, err := ioutil.ReadAll(file)
-		if err != nil {
-			logger.Error("unable to read %s: %s", item.Pathname, err)
+	return strings.Join(variants, ", ")
+}
+
+// List writes paginated URLs of default size thumbnail of given items, as JSON
+func (a app) List(w http.ResponseWriter, r *http.Request, items []provider.StorageItem) {
+	if !a.Enabled() {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	pagination, err := query.ParsePagination(r, 1, defaultPageSize, maxPageSize)
+	if err != nil || pagination.Page == 0 {
+		httperror.BadRequest(w, fmt.Errorf("invalid pagination: %v", err))
+		return
+	}
+
+	thumbnails := make([]apiThumbnail, 0)
+	for _, storageItem := range items {
+		if storageItem.IsDir || !CanHaveThumbnail(storageItem) {
+			continue
 		}
 
-		if commaNeeded {
-			provider.SafeWrite(w, ",")
-		} else {
-			commaNeeded = true
+		key := a.getCacheKey(storageItem)
+		if len(key) == 0 {
+			continue
 		}
 
-		provider.SafeWrite(w, fmt.Sprintf(`"%s":`, sha.Sha1(item.Name)))
-		provider.SafeWrite(w, fmt.Sprintf(`"%s"`, base64.StdEncoding.EncodeToString(content)))
+		thumbnails = append(thumbnails, apiThumbnail{
+			ID:     sha.Sha1(storageItem.Name),
+			Name:   storageItem.Name,
+			URL:    getURL(storageItem.Name, "", key),
+			Srcset: a.getSrcset(storageItem.Name, key),
+		})
+	}
+
+	total := uint(len(thumbnails))
+	start := (pagination.Page - 1) * pagination.PageSize
+	if start > total {
+		start = total
+	}
+
+	end := start + pagination.PageSize
+	if end > total {
+		end = total
 	}
 
-	provider.SafeWrite(w, "}")
+	httpjson.ResponsePaginatedJSON(w, http.StatusOK, pagination.Page, pagination.PageSize, total, thumbnails[start:end], httpjson.IsPretty(r))
 }
@@ -0,0 +1,199 @@
+package thumbnail
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ViBiOh/fibr/pkg/memory"
+	"github.com/ViBiOh/fibr/pkg/provider"
+)
+
+func newListApp(t *testing.T, count int) (app, []provider.StorageItem) {
+	storage := memory.New()
+	if err := storage.CreateDir(provider.MetadataDirectoryName); err != nil {
+		t.Fatalf("CreateDir() = %s", err)
+	}
+
+	instance := app{
+		storage:  storage,
+		backends: []backend{newBackend(formatGenerator{}, 1)},
+		sizes:    []Size{{Name: "small", Width: 150, Height: 150}, {Name: "small@2x", Width: 300, Height: 300}},
+	}
+
+	items := []provider.StorageItem{{Pathname: "/folder", Name: "folder", IsDir: true}, {Pathname: "/notes.txt", Name: "notes.txt"}}
+
+	for index := 0; index < count; index++ {
+		name := fmt.Sprintf("photo %d.png", index)
+		item := provider.StorageItem{Pathname: "/" + name, Name: name}
+
+		if err := storage.Store(item.Pathname, ioutil.NopCloser(strings.NewReader("photo"))); err != nil {
+			t.Fatalf("Store() = %s", err)
+		}
+
+		if err := instance.generate(item); err != nil {
+			t.Fatalf("generate() = %s", err)
+		}
+
+		items = append(items, item)
+	}
+
+	return instance, items
+}
+
+func TestServeCache(t *testing.T) {
+	instance, items := newListApp(t, 1)
+	item := items[2]
+
+	thumbnailURL, srcset := instance.URLs(item)
+	if !strings.HasPrefix(thumbnailURL, "photo%200.png?thumbnail&t=") || !strings.HasSuffix(srcset, " 2x") {
+		t.Fatalf("URLs() = (`%s`, `%s`), want a cache key and a srcset", thumbnailURL, srcset)
+	}
+
+	writer := httptest.NewRecorder()
+	instance.Serve(writer, httptest.NewRequest(http.MethodGet, "/"+thumbnailURL, nil), item)
+
+	etag := writer.Header().Get("ETag")
+
+	var cases = []struct {
+		intention        string
+		target           string
+		ifNoneMatch      string
+		want             int
+		wantCacheControl string
+	}{
+		{
+			"cache key",
+			"/" + thumbnailURL,
+			"",
+			http.StatusOK,
+			immutableCacheControl,
+		},
+		{
+			"no cache key",
+			"/photo%200.png?thumbnail",
+			"",
+			http.StatusOK,
+			"no-cache",
+		},
+		{
+			"outdated cache key",
+			"/photo%200.png?thumbnail&t=outdated",
+			"",
+			http.StatusOK,
+			"no-cache",
+		},
+		{
+			"not modified",
+			"/photo%200.png?thumbnail",
+			etag,
+			http.StatusNotModified,
+			"no-cache",
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.intention, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, testCase.target, nil)
+			if len(testCase.ifNoneMatch) != 0 {
+				r.Header.Set("If-None-Match", testCase.ifNoneMatch)
+			}
+
+			writer := httptest.NewRecorder()
+			instance.Serve(writer, r, item)
+
+			if writer.Code != testCase.want || writer.Header().Get("Cache-Control") != testCase.wantCacheControl || writer.Header().Get("ETag") != etag {
+				t.Errorf("Serve() = (%d, `%s`, `%s`), want (%d, `%s`, `%s`)", writer.Code, writer.Header().Get("Cache-Control"), writer.Header().Get("ETag"), testCase.want, testCase.wantCacheControl, etag)
+			}
+		})
+	}
+}
+
+func TestList(t *testing.T) {
+	instance, items := newListApp(t, 5)
+
+	var cases = []struct {
+		intention string
+		target    string
+		want      int
+		wantNames []string
+		wantTotal uint
+	}{
+		{
+			"default",
+			"/?thumbnail",
+			http.StatusOK,
+			[]string{"photo 0.png", "photo 1.png", "photo 2.png", "photo 3.png", "photo 4.png"},
+			5,
+		},
+		{
+			"page",
+			"/?thumbnail&page=2&pageSize=2",
+			http.StatusOK,
+			[]string{"photo 2.png", "photo 3.png"},
+			5,
+		},
+		{
+			"after last page",
+			"/?thumbnail&page=4&pageSize=2",
+			http.StatusOK,
+			[]string{},
+			5,
+		},
+		{
+			"invalid page",
+			"/?thumbnail&page=0",
+			http.StatusBadRequest,
+			nil,
+			0,
+		},
+		{
+			"page size too large",
+			"/?thumbnail&pageSize=1000",
+			http.StatusBadRequest,
+			nil,
+			0,
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.intention, func(t *testing.T) {
+			writer := httptest.NewRecorder()
+			instance.List(writer, httptest.NewRequest(http.MethodGet, testCase.target, nil), items)
+
+			if writer.Code != testCase.want {
+				t.Fatalf("List() = %d, want %d", writer.Code, testCase.want)
+			}
+
+			if testCase.want != http.StatusOK {
+				return
+			}
+
+			var result struct {
+				Results []apiThumbnail `json:"results"`
+				Total   uint           `json:"total"`
+			}
+
+			if err := json.Unmarshal(writer.Body.Bytes(), &result); err != nil {
+				t.Fatalf("json.Unmarshal() = %s", err)
+			}
+
+			names := make([]string, len(result.Results))
+			for index, thumbnail := range result.Results {
+				names[index] = thumbnail.Name
+
+				if !strings.Contains(thumbnail.URL, "&t=") || !strings.HasSuffix(thumbnail.Srcset, " 2x") {
+					t.Errorf("List() = %+v, want cacheable URL and srcset", thumbnail)
+				}
+			}
+
+			if strings.Join(names, ",") != strings.Join(testCase.wantNames, ",") || result.Total != testCase.wantTotal {
+				t.Errorf("List() = (%v, %d), want (%v, %d)", names, result.Total, testCase.wantNames, testCase.wantTotal)
+			}
+		})
+	}
+}
@@ -1,13 +1,16 @@
 package thumbnail
 
 import (
-	"fmt"
+	"net/http"
 	"path"
+	"strconv"
 	"strings"
 
 	"github.com/ViBiOh/fibr/pkg/provider"
 )
 
+var thumbnailsDirname = path.Join(provider.MetadataDirectoryName, "thumbnails")
+
 // CanHaveThumbnail determine if thumbnail can be generated for given pathname
 func CanHaveThumbnail(item provider.StorageItem) bool {
 	return item.IsImage() || item.IsPdf() || item.IsVideo()
@@ -19,15 +22,72 @@ func (a app) HasThumbnail(item provider.StorageItem) bool {
 		return false
 	}
 
-	_, err := a.storage.Info(getThumbnailPath(item))
+	_, err := a.storage.Info(a.getDefaultThumbnailPath(item))
 	return err == nil
 }
 
-func getThumbnailPath(item provider.StorageItem) string {
-	fullPath := path.Join(provider.MetadataDirectoryName, item.Pathname)
+// HasAllSizes determine if thumbnail exist in every size and format for given pathname
+func (a app) HasAllSizes(item provider.StorageItem) bool {
+	if !a.Enabled() {
+		return false
+	}
+
+	backend := a.getBackend(item)
+	if backend == nil {
+		return false
+	}
+
+	for _, variant := range a.getVariants(backend) {
+		if _, err := a.storage.Info(a.getThumbnailPath(item, variant.Size, variant.Format)); err != nil {
+			return false
+		}
+	}
+
+	return true
+}
+
+// IsRequested determine if request asks for thumbnail, with `thumbnail` query param being empty, true or a size name
+func IsRequested(r *http.Request) bool {
+	values, ok := r.URL.Query()["thumbnail"]
+	if !ok {
+		return false
+	}
+
+	value := strings.Join(values, "")
+	if requested, err := strconv.ParseBool(value); err == nil {
+		return requested
+	}
+
+	return true
+}
+
+// getRequestedSize gives name of size asked by `thumbnail` query param, empty for default one
+func getRequestedSize(r *http.Request) string {
+	value := r.URL.Query().Get("thumbnail")
+	if _, err := strconv.ParseBool(value); err == nil {
+		return ""
+	}
+
+	return value
+}
+
+// getThumbnailPath gives path of thumbnail in given size and format, each size having its own directory
+// and the full name of item being kept, so files differing only by extension have distinct thumbnails
+func (a app) getThumbnailPath(item provider.StorageItem, size Size, format Format) string {
+	fullPath := path.Join(thumbnailsDirname, size.Name, item.Pathname)
 	if item.IsDir {
 		return fullPath
 	}
 
-	return fmt.Sprintf("%s.jpg", strings.TrimSuffix(fullPath, path.Ext(fullPath)))
+	return fullPath + format.Extension()
+}
+
+// getDefaultThumbnailPath gives path of JPEG thumbnail in default size
+func (a app) getDefaultThumbnailPath(item provider.StorageItem) string {
+	var size Size
+	if len(a.sizes) != 0 {
+		size = a.sizes[0]
+	}
+
+	return a.getThumbnailPath(item, size, JPEG)
 }
@@ -67,8 +67,7 @@ func TestHasThumbnail(t *testing.T) {
 			"not found",
 			app{
 				storage:  providertest.Storage{},
-				imageURL: "http://localhost",
-				videoURL: "http://localhost",
+				backends: []backend{newBackend(builtinGenerator{}, 1)},
 			},
 			provider.StorageItem{
 				Pathname: "path/to/error",
@@ -80,8 +79,7 @@ func TestHasThumbnail(t *testing.T) {
 			"found",
 			app{
 				storage:  providertest.Storage{},
-				imageURL: "http://localhost",
-				videoURL: "http://localhost",
+				backends: []backend{newBackend(builtinGenerator{}, 1)},
 			},
 			provider.StorageItem{
 				Pathname: "path/to/valid",
@@ -100,9 +98,12 @@ func TestHasThumbnail(t *testing.T) {
 }
 
 func TestGetThumbnailPath(t *testing.T) {
+	instance := app{sizes: []Size{{Name: "small"}, {Name: "large"}}}
+
 	var cases = []struct {
 		intention string
 		input     provider.StorageItem
+		size      Size
 		want      string
 	}{
 		{
@@ -110,7 +111,32 @@ func TestGetThumbnailPath(t *testing.T) {
 			provider.StorageItem{
 				Pathname: "/path/to/file.png",
 			},
-			".fibr/path/to/file.jpg",
+			Size{Name: "small"},
+			".fibr/thumbnails/small/path/to/file.png.jpg",
+		},
+		{
+			"other size",
+			provider.StorageItem{
+				Pathname: "/path/to/file.png",
+			},
+			Size{Name: "large"},
+			".fibr/thumbnails/large/path/to/file.png.jpg",
+		},
+		{
+			"name looking like a size",
+			provider.StorageItem{
+				Pathname: "/path/to/file@large.png",
+			},
+			Size{Name: "small"},
+			".fibr/thumbnails/small/path/to/file@large.png.jpg",
+		},
+		{
+			"same name with other extension",
+			provider.StorageItem{
+				Pathname: "/path/to/file.jpg",
+			},
+			Size{Name: "small"},
+			".fibr/thumbnails/small/path/to/file.jpg.jpg",
 		},
 		{
 			"directory",
@@ -118,13 +144,14 @@ func TestGetThumbnailPath(t *testing.T) {
 				Pathname: "/path/to/file/",
 				IsDir:    true,
 			},
-			".fibr/path/to/file",
+			Size{Name: "small"},
+			".fibr/thumbnails/small/path/to/file",
 		},
 	}
 
 	for _, testCase := range cases {
 		t.Run(testCase.intention, func(t *testing.T) {
-			if result := getThumbnailPath(testCase.input); result != testCase.want {
+			if result := instance.getThumbnailPath(testCase.input, testCase.size, JPEG); result != testCase.want {
 				t.Errorf("getThumbnailPath() = %s, want %s", result, testCase.want)
 			}
 		})
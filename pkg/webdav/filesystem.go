@@ -0,0 +1,395 @@
+package webdav
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/ViBiOh/fibr/pkg/crud"
+	"github.com/ViBiOh/fibr/pkg/provider"
+	"github.com/ViBiOh/fibr/pkg/thumbnail"
+	"github.com/ViBiOh/httputils/v3/pkg/logger"
+	netWebdav "golang.org/x/net/webdav"
+)
+
+var (
+	_ netWebdav.FileSystem = fileSystem{}
+
+	errReadOnly  = errors.New("file is opened in read-only mode")
+	errWriteOnly = errors.New("file is opened in write-only mode")
+)
+
+// fileSystem exposes a subtree of a storage as a webdav.FileSystem
+type fileSystem struct {
+	root      string
+	request   provider.Request
+	storage   provider.Storage
+	crud      crud.App
+	thumbnail thumbnail.App
+}
+
+func (f fileSystem) getPathname(name string) (string, error) {
+	pathname := path.Join(f.root, path.Clean(fmt.Sprintf("/%s", name)))
+
+	for _, part := range strings.Split(pathname, "/") {
+		if part == provider.MetadataDirectoryName {
+			return "", os.ErrNotExist
+		}
+	}
+
+	if !f.request.IsVisible(pathname) {
+		return "", os.ErrNotExist
+	}
+
+	return pathname, nil
+}
+
+// checkPermission checks that access control list allows given action on pathname and its content
+func (f fileSystem) checkPermission(pathname string, permission provider.Permission) error {
+	if !f.request.PermissionOn(pathname).Has(permission) || f.request.ACL.Restricts(pathname, permission) {
+		return os.ErrPermission
+	}
+
+	return nil
+}
+
+func (f fileSystem) info(name string) (provider.StorageItem, error) {
+	pathname, err := f.getPathname(name)
+	if err != nil {
+		return provider.StorageItem{}, err
+	}
+
+	item, err := f.storage.Info(pathname)
+	return item, convertError(err)
+}
+
+func (f fileSystem) Mkdir(_ context.Context, name string, _ os.FileMode) error {
+	pathname, err := f.getPathname(name)
+	if err != nil {
+		return err
+	}
+
+	if err := f.checkPermission(pathname, provider.PermissionCreate); err != nil {
+		return err
+	}
+
+	if _, err := f.storage.Info(pathname); err == nil {
+		return os.ErrExist
+	}
+
+	if _, err := f.info(path.Dir(name)); err != nil {
+		return err
+	}
+
+	return convertError(f.storage.CreateDir(pathname))
+}
+
+func (f fileSystem) OpenFile(_ context.Context, name string, flag int, _ os.FileMode) (netWebdav.File, error) {
+	pathname, err := f.getPathname(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC) != 0 {
+		if err := f.checkPermission(pathname, provider.PermissionUpload); err != nil {
+			return nil, err
+		}
+
+		item, err := f.storage.Info(pathname)
+		exists := err == nil
+
+		if exists && item.IsDir {
+			return nil, os.ErrExist
+		}
+
+		var writer provider.WriteAborter
+		if exists {
+			writer, err = f.crud.OverwriteItem(f.request, item)
+		} else {
+			writer, err = f.storage.WriterTo(pathname)
+		}
+
+		if err != nil {
+			return nil, convertError(err)
+		}
+
+		return &writeFile{
+			WriteAborter: writer,
+			fileSystem:   f,
+			pathname:     pathname,
+		}, nil
+	}
+
+	item, err := f.storage.Info(pathname)
+	if err != nil {
+		return nil, convertError(err)
+	}
+
+	if item.IsDir {
+		return &dirFile{
+			fileSystem: f,
+			item:       item,
+		}, nil
+	}
+
+	if err := f.checkPermission(pathname, provider.PermissionDownload); err != nil {
+		return nil, err
+	}
+
+	reader, err := f.storage.ReaderFrom(pathname)
+	if err != nil {
+		return nil, convertError(err)
+	}
+
+	return &readFile{
+		ReadSeekerCloser: reader,
+		item:             item,
+	}, nil
+}
+
+func (f fileSystem) RemoveAll(_ context.Context, name string) error {
+	item, err := f.info(name)
+	if err != nil {
+		return err
+	}
+
+	if err := f.checkPermission(item.Pathname, provider.PermissionDelete); err != nil {
+		return err
+	}
+
+	return convertError(f.crud.RemoveItem(item))
+}
+
+func (f fileSystem) Rename(_ context.Context, oldName, newName string) error {
+	oldItem, err := f.info(oldName)
+	if err != nil {
+		return err
+	}
+
+	newPathname, err := f.getPathname(newName)
+	if err != nil {
+		return err
+	}
+
+	for _, pathname := range []string{oldItem.Pathname, newPathname} {
+		if err := f.checkPermission(pathname, provider.PermissionRename); err != nil {
+			return err
+		}
+	}
+
+	_, err = f.crud.RenameItem(oldItem, newPathname)
+	return convertError(err)
+}
+
+func (f fileSystem) Stat(_ context.Context, name string) (os.FileInfo, error) {
+	item, err := f.info(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return fileInfo{item}, nil
+}
+
+type fileInfo struct {
+	item provider.StorageItem
+}
+
+func (f fileInfo) Name() string {
+	return f.item.Name
+}
+
+func (f fileInfo) Size() int64 {
+	if f.item.IsDir {
+		return 0
+	}
+
+	return f.item.Size
+}
+
+func (f fileInfo) Mode() os.FileMode {
+	if f.item.IsDir {
+		return os.ModeDir | 0700
+	}
+
+	return 0600
+}
+
+func (f fileInfo) ModTime() time.Time {
+	return f.item.Date
+}
+
+func (f fileInfo) IsDir() bool {
+	return f.item.IsDir
+}
+
+func (f fileInfo) Sys() interface{} {
+	return nil
+}
+
+type readFile struct {
+	provider.ReadSeekerCloser
+	item provider.StorageItem
+}
+
+func (r *readFile) Write([]byte) (int, error) {
+	return 0, errReadOnly
+}
+
+func (r *readFile) Readdir(int) ([]os.FileInfo, error) {
+	return nil, os.ErrInvalid
+}
+
+func (r *readFile) Stat() (os.FileInfo, error) {
+	return fileInfo{r.item}, nil
+}
+
+type dirFile struct {
+	fileSystem fileSystem
+	item       provider.StorageItem
+	children   []os.FileInfo
+	listed     bool
+}
+
+func (d *dirFile) Close() error {
+	return nil
+}
+
+func (d *dirFile) Read([]byte) (int, error) {
+	return 0, os.ErrInvalid
+}
+
+func (d *dirFile) Seek(int64, int) (int64, error) {
+	return 0, os.ErrInvalid
+}
+
+func (d *dirFile) Write([]byte) (int, error) {
+	return 0, errReadOnly
+}
+
+func (d *dirFile) Readdir(count int) ([]os.FileInfo, error) {
+	if !d.listed {
+		items, err := d.fileSystem.storage.List(d.item.Pathname)
+		if err != nil {
+			return nil, convertError(err)
+		}
+
+		items = d.fileSystem.request.FilterVisible(items)
+
+		d.children = make([]os.FileInfo, len(items))
+		for index, item := range items {
+			d.children[index] = fileInfo{item}
+		}
+		d.listed = true
+	}
+
+	if count <= 0 {
+		children := d.children
+		d.children = nil
+
+		return children, nil
+	}
+
+	if len(d.children) == 0 {
+		return nil, io.EOF
+	}
+
+	if count > len(d.children) {
+		count = len(d.children)
+	}
+
+	children := d.children[:count]
+	d.children = d.children[count:]
+
+	return children, nil
+}
+
+func (d *dirFile) Stat() (os.FileInfo, error) {
+	return fileInfo{d.item}, nil
+}
+
+type writeFile struct {
+	provider.WriteAborter
+	fileSystem fileSystem
+	pathname   string
+	size       int64
+	aborted    bool
+}
+
+func (w *writeFile) Write(content []byte) (int, error) {
+	n, err := w.WriteAborter.Write(content)
+	w.size += int64(n)
+
+	return n, err
+}
+
+// ReadFrom copies request body, discarding content if it fails so an interrupted upload never replaces the file
+func (w *writeFile) ReadFrom(reader io.Reader) (int64, error) {
+	n, err := io.Copy(w.WriteAborter, reader)
+	w.size += n
+
+	if err != nil {
+		w.aborted = true
+
+		if abortErr := w.WriteAborter.Abort(); abortErr != nil {
+			logger.Error("unable to abort write of %s: %s", w.pathname, abortErr)
+		}
+	}
+
+	return n, err
+}
+
+// Close commits written content, an overwritten file being replaced by crud like any upload
+func (w *writeFile) Close() error {
+	if w.aborted {
+		return nil
+	}
+
+	if err := w.WriteAborter.Close(); err != nil {
+		return convertError(err)
+	}
+
+	item, err := w.fileSystem.storage.Info(w.pathname)
+	if err != nil {
+		logger.Error("unable to get info of %s: %s", w.pathname, err)
+		return nil
+	}
+
+	if thumbnail.CanHaveThumbnail(item) {
+		w.fileSystem.thumbnail.GenerateThumbnail(item)
+	}
+
+	return nil
+}
+
+func (w *writeFile) Read([]byte) (int, error) {
+	return 0, errWriteOnly
+}
+
+func (w *writeFile) Seek(int64, int) (int64, error) {
+	return 0, errWriteOnly
+}
+
+func (w *writeFile) Readdir(int) ([]os.FileInfo, error) {
+	return nil, os.ErrInvalid
+}
+
+func (w *writeFile) Stat() (os.FileInfo, error) {
+	return fileInfo{provider.StorageItem{
+		Name: path.Base(w.pathname),
+		Date: time.Now(),
+		Size: w.size,
+	}}, nil
+}
+
+func convertError(err error) error {
+	if provider.IsNotExist(err) {
+		return os.ErrNotExist
+	}
+
+	return err
+}
@@ -0,0 +1,155 @@
+package webdav
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/ViBiOh/fibr/pkg/crud"
+	"github.com/ViBiOh/fibr/pkg/provider"
+	"github.com/ViBiOh/fibr/pkg/thumbnail"
+	"github.com/ViBiOh/httputils/v3/pkg/flags"
+	"github.com/ViBiOh/httputils/v3/pkg/logger"
+	netWebdav "golang.org/x/net/webdav"
+)
+
+var (
+	// ErrFileShare occurs when a share of a single file is mounted
+	ErrFileShare = errors.New("share of a single file can't be mounted as a network drive")
+)
+
+// App of package
+type App interface {
+	Enabled() bool
+	Prefix() string
+	Handle(http.ResponseWriter, *http.Request, provider.Request)
+}
+
+// Config of package
+type Config struct {
+	prefix *string
+}
+
+type app struct {
+	prefix    string
+	storage   provider.Storage
+	crud      crud.App
+	thumbnail thumbnail.App
+
+	locks     map[string]netWebdav.LockSystem
+	locksLock sync.Mutex
+}
+
+// Flags adds flags for configuring package
+func Flags(fs *flag.FlagSet, prefix string) Config {
+	return Config{
+		prefix: flags.New(prefix, "webdav").Name("Prefix").Default("").Label("URL prefix for mounting as WebDAV network drive, disabled if empty (e.g. /webdav)").ToString(fs),
+	}
+}
+
+// New creates new App from Config
+func New(config Config, storage provider.Storage, crudApp crud.App, thumbnailApp thumbnail.App) App {
+	prefix := strings.TrimSpace(*config.prefix)
+	if len(prefix) != 0 {
+		prefix = path.Join("/", prefix)
+		logger.Info("WebDAV served under %s", prefix)
+	}
+
+	return &app{
+		prefix:    prefix,
+		storage:   storage,
+		crud:      crudApp,
+		thumbnail: thumbnailApp,
+		locks:     make(map[string]netWebdav.LockSystem),
+	}
+}
+
+// Enabled checks if app is enabled
+func (a *app) Enabled() bool {
+	return len(a.prefix) != 0 && a.prefix != "/"
+}
+
+// Prefix of WebDAV urls
+func (a *app) Prefix() string {
+	return a.prefix
+}
+
+func (a *app) getLockSystem(root string) netWebdav.LockSystem {
+	a.locksLock.Lock()
+	defer a.locksLock.Unlock()
+
+	lockSystem, ok := a.locks[root]
+	if !ok {
+		lockSystem = netWebdav.NewMemLS()
+		a.locks[root] = lockSystem
+	}
+
+	return lockSystem
+}
+
+// Handle WebDAV request for given parsed request
+func (a *app) Handle(w http.ResponseWriter, r *http.Request, request provider.Request) {
+	if !request.Can(getMethodPermission(r.Method)) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	prefix := a.prefix
+	if request.Share != nil {
+		if request.Share.File {
+			http.Error(w, ErrFileShare.Error(), http.StatusBadRequest)
+			return
+		}
+
+		prefix = fmt.Sprintf("%s/%s", prefix, request.Share.ID)
+	}
+
+	rootRequest := request
+	rootRequest.Path = "/"
+	root := rootRequest.GetFilepath("")
+
+	handler := netWebdav.Handler{
+		Prefix: prefix,
+		FileSystem: fileSystem{
+			root:      root,
+			request:   request,
+			storage:   a.storage,
+			crud:      a.crud,
+			thumbnail: a.thumbnail,
+		},
+		LockSystem: a.getLockSystem(root),
+		Logger: func(r *http.Request, err error) {
+			if err != nil {
+				logger.Error("webdav %s %s: %s", r.Method, r.URL.Path, err)
+			}
+		},
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// getMethodPermission gives permission needed for performing given WebDAV method
+func getMethodPermission(method string) provider.Permission {
+	switch method {
+	case http.MethodOptions:
+		return 0
+	case "PROPFIND":
+		return provider.PermissionList
+	case http.MethodGet, http.MethodHead:
+		return provider.PermissionDownload
+	case http.MethodPut, "COPY", "PROPPATCH", "LOCK", "UNLOCK":
+		return provider.PermissionUpload
+	case "MKCOL":
+		return provider.PermissionCreate
+	case "MOVE":
+		return provider.PermissionRename
+	case http.MethodDelete:
+		return provider.PermissionDelete
+	default:
+		return provider.PermissionAll
+	}
+}
@@ -0,0 +1,266 @@
+package webdav
+
+import (
+	"errors"
+	"flag"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ViBiOh/fibr/pkg/crud"
+	"github.com/ViBiOh/fibr/pkg/memory"
+	"github.com/ViBiOh/fibr/pkg/provider"
+	"github.com/ViBiOh/fibr/pkg/thumbnail"
+)
+
+func newTestApp(t *testing.T, args ...string) (App, provider.Storage) {
+	fs := flag.NewFlagSet("webdav-test", flag.ContinueOnError)
+	webdavConfig := Flags(fs, "webdav")
+	thumbnailConfig := thumbnail.Flags(fs, "thumbnail")
+	crudConfig := crud.Flags(fs, "crud")
+
+	if err := fs.Parse(append([]string{"-webdavPrefix", "/webdav", "-thumbnailDisable"}, args...)); err != nil {
+		t.Fatalf("unable to parse flags: %s", err)
+	}
+
+	storage := memory.New()
+
+	thumbnailApp, err := thumbnail.New(thumbnailConfig, storage)
+	if err != nil {
+		t.Fatalf("unable to create thumbnail: %s", err)
+	}
+
+	crudApp, err := crud.New(crudConfig, storage, nil, thumbnailApp)
+	if err != nil {
+		t.Fatalf("unable to create crud: %s", err)
+	}
+
+	return New(webdavConfig, storage, crudApp, thumbnailApp), storage
+}
+
+func serve(instance App, method, target string, body string, headers map[string]string, request provider.Request) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	for key, value := range headers {
+		r.Header.Set(key, value)
+	}
+
+	writer := httptest.NewRecorder()
+	instance.Handle(writer, r, request)
+
+	return writer
+}
+
+func TestHandle(t *testing.T) {
+	instance, storage := newTestApp(t)
+	admin := provider.Request{Path: "/", Permissions: provider.PermissionAll}
+
+	if !instance.Enabled() || instance.Prefix() != "/webdav" {
+		t.Fatalf("New() = (%t, `%s`), want enabled on /webdav", instance.Enabled(), instance.Prefix())
+	}
+
+	if writer := serve(instance, "MKCOL", "/webdav/photos", "", nil, admin); writer.Code != http.StatusCreated {
+		t.Errorf("MKCOL = %d, want %d", writer.Code, http.StatusCreated)
+	}
+
+	if writer := serve(instance, http.MethodPut, "/webdav/photos/beach.txt", "sunny", nil, admin); writer.Code != http.StatusCreated {
+		t.Errorf("PUT = %d, want %d", writer.Code, http.StatusCreated)
+	}
+
+	if writer := serve(instance, http.MethodPut, "/webdav/photos/beach.txt", "sunny", nil, admin); writer.Code != http.StatusCreated {
+		t.Errorf("PUT = %d, want %d", writer.Code, http.StatusCreated)
+	}
+
+	if writer := serve(instance, http.MethodGet, "/webdav/photos/beach.txt", "", nil, admin); writer.Code != http.StatusOK || writer.Body.String() != "sunny" {
+		t.Errorf("GET = (%d, `%s`), want (%d, `sunny`)", writer.Code, writer.Body.String(), http.StatusOK)
+	}
+
+	if versions, err := storage.List("/.fibr/versions/photos/beach.txt"); err != nil || len(versions) != 1 {
+		t.Errorf("PUT = (%+v, %s), want overwritten content kept as a version", versions, err)
+	}
+
+	writer := serve(instance, "PROPFIND", "/webdav/photos/", "", map[string]string{"Depth": "1"}, admin)
+	if writer.Code != http.StatusMultiStatus || !strings.Contains(writer.Body.String(), "/webdav/photos/beach.txt") {
+		t.Errorf("PROPFIND = (%d, `%s`), want listing of beach.txt", writer.Code, writer.Body.String())
+	}
+
+	if writer := serve(instance, "MOVE", "/webdav/photos/beach.txt", "", map[string]string{"Destination": "http://example.com/webdav/photos/sea.txt"}, admin); writer.Code != http.StatusCreated {
+		t.Errorf("MOVE = %d, want %d", writer.Code, http.StatusCreated)
+	}
+
+	if _, err := storage.Info("/photos/sea.txt"); err != nil {
+		t.Errorf("MOVE did not rename file: %s", err)
+	}
+
+	if versions, err := storage.List("/.fibr/versions/photos/sea.txt"); err != nil || len(versions) != 1 {
+		t.Errorf("MOVE = (%+v, %s), want versions moved along", versions, err)
+	}
+
+	if writer := serve(instance, http.MethodDelete, "/webdav/photos", "", nil, admin); writer.Code != http.StatusNoContent {
+		t.Errorf("DELETE = %d, want %d", writer.Code, http.StatusNoContent)
+	}
+
+	if _, err := storage.Info("/photos"); !provider.IsNotExist(err) {
+		t.Errorf("DELETE did not remove directory: %s", err)
+	}
+
+	if items, err := storage.List("/.fibr/trash"); err != nil || len(items) != 3 {
+		t.Errorf("DELETE = (%+v, %s), want directory and its versions moved to trash", items, err)
+	}
+
+	if _, err := storage.Info("/.fibr/versions/photos"); !provider.IsNotExist(err) {
+		t.Errorf("DELETE = `%v`, want versions moved away", err)
+	}
+}
+
+type failingReader struct{}
+
+func (failingReader) Read(p []byte) (int, error) {
+	return copy(p, "partial"), errors.New("connection reset")
+}
+
+func TestHandleInterruptedPut(t *testing.T) {
+	instance, storage := newTestApp(t)
+
+	if err := storage.Store("/report.txt", ioutil.NopCloser(strings.NewReader("first version"))); err != nil {
+		t.Fatalf("Store() = %s", err)
+	}
+
+	writer := httptest.NewRecorder()
+	instance.Handle(writer, httptest.NewRequest(http.MethodPut, "/webdav/report.txt", failingReader{}), provider.Request{Path: "/", Permissions: provider.PermissionAll})
+
+	if writer.Code == http.StatusCreated {
+		t.Errorf("PUT = %d, want failure", writer.Code)
+	}
+
+	if writer := serve(instance, http.MethodGet, "/webdav/report.txt", "", nil, provider.Request{Path: "/", Permissions: provider.PermissionAll}); writer.Body.String() != "first version" {
+		t.Errorf("GET = `%s`, want previous version kept", writer.Body.String())
+	}
+
+	if _, err := storage.Info("/.fibr/versions/report.txt"); !provider.IsNotExist(err) {
+		t.Errorf("PUT = `%v`, want no version saved for an interrupted write", err)
+	}
+}
+
+func TestHandleOverwriteTrash(t *testing.T) {
+	instance, storage := newTestApp(t, "-crudVersionCount", "0")
+	admin := provider.Request{Path: "/", Permissions: provider.PermissionAll}
+
+	if err := storage.Store("/report.txt", ioutil.NopCloser(strings.NewReader("first version"))); err != nil {
+		t.Fatalf("Store() = %s", err)
+	}
+
+	if writer := serve(instance, http.MethodPut, "/webdav/report.txt", "second version", nil, admin); writer.Code != http.StatusCreated {
+		t.Errorf("PUT = %d, want %d", writer.Code, http.StatusCreated)
+	}
+
+	if content := readStorage(t, storage, "/report.txt"); content != "second version" {
+		t.Errorf("PUT = `%s`, want `second version`", content)
+	}
+
+	if items, err := storage.List("/.fibr/trash"); err != nil || len(items) != 2 {
+		t.Errorf("PUT = (%+v, %s), want previous content moved to trash", items, err)
+	}
+
+	if items, err := storage.List("/.fibr/uploads"); err != nil || len(items) != 0 {
+		t.Errorf("PUT = (%+v, %s), want staged content cleaned up", items, err)
+	}
+}
+
+func readStorage(t *testing.T, storage provider.Storage, pathname string) string {
+	t.Helper()
+
+	reader, err := storage.ReaderFrom(pathname)
+	if err != nil {
+		t.Fatalf("ReaderFrom() = %s", err)
+	}
+	defer func() { _ = reader.Close() }()
+
+	content, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("ReadAll() = %s", err)
+	}
+
+	return string(content)
+}
+
+func TestHandleShare(t *testing.T) {
+	instance, storage := newTestApp(t)
+
+	if err := storage.CreateDir("/private/shared"); err != nil {
+		t.Fatalf("CreateDir() = %s", err)
+	}
+
+	if err := storage.Store("/private/secret.txt", ioutil.NopCloser(strings.NewReader("secret"))); err != nil {
+		t.Fatalf("Store() = %s", err)
+	}
+
+	readOnly := provider.Request{
+		Path:        "/",
+		Permissions: provider.PermissionRead,
+		Share: &provider.Share{
+			ID:   "abcdef",
+			Path: "/private/shared",
+		},
+	}
+
+	if writer := serve(instance, http.MethodPut, "/webdav/abcdef/file.txt", "content", nil, readOnly); writer.Code != http.StatusForbidden {
+		t.Errorf("PUT = %d, want %d", writer.Code, http.StatusForbidden)
+	}
+
+	if writer := serve(instance, http.MethodGet, "/webdav/abcdef/../secret.txt", "", nil, readOnly); writer.Code == http.StatusOK {
+		t.Errorf("GET = %d, want share to be jailed", writer.Code)
+	}
+
+	editable := readOnly
+	editable.Permissions = provider.PermissionEdit
+
+	if writer := serve(instance, http.MethodPut, "/webdav/abcdef/file.txt", "content", nil, editable); writer.Code != http.StatusCreated {
+		t.Errorf("PUT = %d, want %d", writer.Code, http.StatusCreated)
+	}
+
+	if _, err := storage.Info("/private/shared/file.txt"); err != nil {
+		t.Errorf("PUT did not store file in share: %s", err)
+	}
+
+	if writer := serve(instance, "PROPFIND", "/webdav/abcdef/.fibr", "", map[string]string{"Depth": "0"}, editable); writer.Code != http.StatusNotFound {
+		t.Errorf("PROPFIND = %d, want metadata to be hidden", writer.Code)
+	}
+}
+
+func TestHandleACL(t *testing.T) {
+	instance, storage := newTestApp(t)
+
+	for _, pathname := range []string{"/public", "/finance"} {
+		if err := storage.CreateDir(pathname); err != nil {
+			t.Fatalf("CreateDir() = %s", err)
+		}
+	}
+
+	if err := storage.Store("/finance/salaries.csv", ioutil.NopCloser(strings.NewReader("secret"))); err != nil {
+		t.Fatalf("Store() = %s", err)
+	}
+
+	request := provider.Request{
+		Path:        "/",
+		Permissions: provider.PermissionEdit,
+		ACL: provider.ACL{
+			{Path: "/", Permissions: provider.PermissionEdit},
+			{Path: "/finance"},
+		},
+	}
+
+	writer := serve(instance, "PROPFIND", "/webdav/", "", map[string]string{"Depth": "1"}, request)
+	if writer.Code != http.StatusMultiStatus || !strings.Contains(writer.Body.String(), "/webdav/public/") || strings.Contains(writer.Body.String(), "finance") {
+		t.Errorf("PROPFIND = (%d, `%s`), want finance hidden", writer.Code, writer.Body.String())
+	}
+
+	if writer := serve(instance, http.MethodGet, "/webdav/finance/salaries.csv", "", nil, request); writer.Code != http.StatusNotFound {
+		t.Errorf("GET = %d, want %d", writer.Code, http.StatusNotFound)
+	}
+
+	if writer := serve(instance, http.MethodPut, "/webdav/finance/salaries.csv", "leaked", nil, request); writer.Code == http.StatusCreated {
+		t.Errorf("PUT = %d, want hidden file untouched", writer.Code)
+	}
+}